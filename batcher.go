@@ -10,11 +10,10 @@ package newhope
 func batcher84(x []uint16) {
 	// In theory this should probably be inlined.
 	compareAndSwap := func(x []uint16, i, j int) {
-		const _5q = 5 * paramQ
 		var c int32
 		var t uint16
 
-		c = _5q - 1 - int32(x[16*i])
+		c = uniformBound - 1 - int32(x[16*i])
 		c >>= 31
 		t = x[16*i] ^ x[16*j]
 		t &= uint16(c)
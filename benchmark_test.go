@@ -9,7 +9,10 @@ package newhope
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"fmt"
+	"runtime"
 	"testing"
 )
 
@@ -79,17 +82,67 @@ func benchKeyExchangeBob(b *testing.B) {
 	}
 }
 
+// benchPoly benchmarks the polynomial arithmetic, which is done in assembly
+// where available.
+func benchPoly(b *testing.B, name string, fn func(p, a, b *poly)) {
+	var p, x, y poly
+	for i := range x.coeffs {
+		x.coeffs[i] = uint16(i) * 11 % paramQ
+		y.coeffs[i] = uint16(i) * 13 % paramQ
+	}
+
+	b.Run(name, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fn(&p, &x, &y)
+		}
+	})
+}
+
+func benchNewHopePoly(b *testing.B) {
+	var s sampler
+	var seed [SeedBytes]byte
+
+	benchPoly(b, "ntt", func(p, a, _ *poly) { *p = *a; p.ntt() })
+	benchPoly(b, "invNtt", func(p, a, _ *poly) { *p = *a; p.invNtt() })
+	benchPoly(b, "pointwise", (*poly).pointwise)
+	benchPoly(b, "add", (*poly).add)
+	benchPoly(b, "getNoise", func(p, _, _ *poly) { p.getNoise(&s, &seed, 0) })
+}
+
 func BenchmarkNewHope(b *testing.B) {
 	TorSampling = false
+	b.Run("Poly", benchNewHopePoly)
 	b.Run("GenerateKeyPairAlice", benchGenerateKeyPairAlice)
 	b.Run("KeyExchangeAlice", benchKeyExchangeAlice)
 	b.Run("KeyExchangeBob", benchKeyExchangeBob)
 }
 
+func BenchmarkReconcilers(b *testing.B) {
+	var s sampler
+	var seed [SeedBytes]byte
+	var key [maxKeyBytes]byte
+
+	for _, v := range []struct {
+		name string
+		rc   Reconciler
+	}{
+		{"D4", ReconcilerD4},
+		{"Peikert", ReconcilerPeikert},
+		{"Ding", ReconcilerDing},
+	} {
+		rc := v.rc
+		b.Run(v.name, func(b *testing.B) {
+			benchPoly(b, "helpRec", func(p, a, _ *poly) { _ = rc.helpRec(&s, p, a, &seed, 3) })
+			benchPoly(b, "rec", func(_, a, c *poly) { rc.rec(key[:], a, c) })
+		})
+	}
+}
+
 func BenchmarkNewHopeTor(b *testing.B) {
 	if testing.Short() {
 		b.SkipNow()
 	}
+	skipTorSampling(b)
 	TorSampling = true
 	b.Run("GenerateKeyPairAlice", benchGenerateKeyPairAlice)
 	b.Run("KeyExchangeAlice", benchKeyExchangeAlice)
@@ -162,9 +215,54 @@ func benchKeyExchangeSimpleBob(b *testing.B) {
 	}
 }
 
+func benchNewHopeSimplePoly(b *testing.B) {
+	var c [compressedBytes]byte
+
+	benchPoly(b, "compress", func(_, a, _ *poly) { a.compress(c[:]) })
+	benchPoly(b, "decompress", func(p, _, _ *poly) { p.decompress(c[:]) })
+}
+
 func BenchmarkNewHopeSimple(b *testing.B) {
 	TorSampling = false
+	b.Run("Poly", benchNewHopeSimplePoly)
 	b.Run("GenerateKeyPairSimpleAlice", benchGenerateKeyPairSimpleAlice)
 	b.Run("KeyExchangeSimpleAlice", benchKeyExchangeSimpleAlice)
 	b.Run("KeyExchangeSimpleBob", benchKeyExchangeSimpleBob)
 }
+
+func BenchmarkPool(b *testing.B) {
+	const batchSize = 256
+
+	TorSampling = false
+	_, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+	if err != nil {
+		b.Fatalf("GenerateKeyPairAlice failed: %v", err)
+	}
+	alicePubs := make([]*PublicKeyAlice, batchSize)
+	for i := range alicePubs {
+		alicePubs[i] = alicePub
+	}
+
+	workers := []int{1, 2, 4}
+	if n := runtime.GOMAXPROCS(0); n > 4 {
+		workers = append(workers, n)
+	}
+	for _, n := range workers {
+		p := NewPool(n, 0)
+		b.Run(fmt.Sprintf("GenerateKeyPairsAlice/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i += batchSize {
+				if _, _, err := p.GenerateKeyPairsAlice(context.Background(), rand.Reader, batchSize, nil); err != nil {
+					b.Fatalf("GenerateKeyPairsAlice failed: %v", err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("KeyExchangesBob/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i += batchSize {
+				if _, _, err := p.KeyExchangesBob(context.Background(), rand.Reader, alicePubs); err != nil {
+					b.Fatalf("KeyExchangesBob failed: %v", err)
+				}
+			}
+		})
+		p.Close()
+	}
+}
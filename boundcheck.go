@@ -0,0 +1,15 @@
+// boundcheck.go - NewHope bound checking (enabled).
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build newhope_boundcheck
+// +build newhope_boundcheck
+
+package newhope
+
+// boundCheck enables assertions that no lazily reduced intermediate value
+// overflows, for testing with `go test -tags newhope_boundcheck`.
+const boundCheck = true
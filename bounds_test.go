@@ -0,0 +1,252 @@
+// bounds_test.go - NewHope coefficient bound analysis.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"fmt"
+	"testing"
+)
+
+// bounds is an interval analysis of the generic polynomial arithmetic.  It
+// follows the same steps as ntt(), invNtt(), pointwise(), add() and sub(),
+// but on an upper bound of each value instead of the value, using the
+// bounds on the reductions checked by reduce_test.go, and fails if a value
+// could overflow its type, or a reduction be given an input it does not
+// handle.
+//
+// As every value is unsigned, all of the lower bounds are 0, and each
+// difference is computed as x + c - y, which is safe iff y <= c.
+type bounds struct {
+	t  *testing.T
+	op string
+}
+
+func (b *bounds) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		b.t.Fatalf("%s: %s", b.op, fmt.Sprintf(format, args...))
+	}
+}
+
+func (b *bounds) u16(x uint64) uint64 {
+	b.check(x <= 0xffff, "%d overflows uint16", x)
+	return x
+}
+
+func (b *bounds) u32(x uint64) uint64 {
+	b.check(x <= 0xffffffff, "%d overflows uint32", x)
+	return x
+}
+
+func (b *bounds) sub(x, c, y uint64) uint64 {
+	b.check(y <= c, "%d + %d - %d underflows", x, c, y)
+	return x + c
+}
+
+func (b *bounds) montgomeryReduce(x uint64) uint64 {
+	b.check(int64(x) < montgomeryLimit, "montgomeryReduce input %d out of range", x)
+	return montgomeryMax(x)
+}
+
+func (b *bounds) barrettReduce(x uint64) uint64 {
+	b.u16(x)
+	return barrettMax
+}
+
+func (b *bounds) barrettReduce32(x uint64) uint64 {
+	b.check(x < 1<<17, "barrettReduce32 input %d out of range", x)
+	return barrett32Max
+}
+
+func (b *bounds) ctButterfly(x, y uint64, w uint16) (uint64, uint64) {
+	t := b.montgomeryReduce(b.u32(uint64(w) * y))
+	return b.u32(x + t), b.u32(b.sub(x, 2*paramQ, t))
+}
+
+func (b *bounds) gsButterfly(x, y uint64, w uint16) (uint64, uint64) {
+	return b.u32(x + y), b.montgomeryReduce(b.u32(uint64(w) * b.sub(x, 3*paramQ, y)))
+}
+
+// ntt follows nttGeneric.
+func (b *bounds) ntt(a *[paramN]uint64) {
+	for _, v := range a {
+		b.check(v < 1<<14, "ntt input %d out of range", v)
+	}
+
+	w := nttTwiddlesMontgomery[:]
+	distance := uint(1)
+	for ; distance*8 <= paramN; distance *= 8 {
+		for k := uint(0); k < distance; k++ {
+			for j := k; j < paramN; j += 8 * distance {
+				var x [8]uint64
+				for i := range x {
+					x[i] = a[j+uint(i)*distance]
+				}
+
+				for i := 0; i < 8; i += 2 {
+					x[i], x[i+1] = b.ctButterfly(x[i], x[i+1], w[k])
+				}
+				for i := 0; i < 8; i += 4 {
+					x[i], x[i+2] = b.ctButterfly(x[i], x[i+2], w[distance+k])
+					x[i+1], x[i+3] = b.ctButterfly(x[i+1], x[i+3], w[2*distance+k])
+				}
+				for i := 0; i < 4; i++ {
+					x[i], x[i+4] = b.ctButterfly(x[i], x[i+4], w[(3+uint(i))*distance+k])
+				}
+
+				for i := range x {
+					a[j+uint(i)*distance] = b.barrettReduce32(x[i])
+				}
+			}
+		}
+		w = w[7*distance:]
+	}
+
+	for j := uint(0); j < distance; j++ {
+		x, y := b.ctButterfly(a[j], a[j+distance], w[j])
+		a[j], a[j+distance] = b.u16(x), b.u16(y)
+	}
+}
+
+// invNtt follows invNttGeneric, except that it is done in place, as the
+// order of the output does not matter.
+func (b *bounds) invNtt(a *[paramN]uint64) {
+	for _, v := range a {
+		b.check(v < 1<<14, "invNtt input %d out of range", v)
+	}
+
+	w := invNttTwiddlesMontgomery[:]
+	level := uint(9)
+	for distance := uint(paramN / 4); distance > 1; distance /= 4 {
+		for block := uint(0); block < paramN/(4*distance); block++ {
+			reduceFirst := invNttReduce(level, block)
+
+			for k := uint(0); k < distance; k++ {
+				j := 4*distance*block + k
+				x0, x1 := a[j], a[j+distance]
+				x2, x3 := a[j+2*distance], a[j+3*distance]
+
+				x0, x2 = b.gsButterfly(x0, x2, w[k])
+				x1, x3 = b.gsButterfly(x1, x3, w[distance+k])
+				if reduceFirst {
+					x0, x1 = b.barrettReduce(x0), b.barrettReduce(x1)
+				}
+
+				x0, x1 = b.gsButterfly(x0, x1, w[2*distance+k])
+				x2, x3 = b.gsButterfly(x2, x3, w[2*distance+k])
+				if !reduceFirst {
+					x0 = b.barrettReduce(x0)
+				}
+
+				a[j], a[j+distance] = b.u16(x0), b.u16(x1)
+				a[j+2*distance], a[j+3*distance] = b.u16(x2), b.u16(x3)
+			}
+		}
+		w = w[3*distance:]
+		level -= 2
+	}
+
+	for block := uint(0); block < paramN/4; block++ {
+		j := 4 * block
+		x0, x1, x2, x3 := a[j], a[j+1], a[j+2], a[j+3]
+
+		x0, x2 = b.gsButterfly(x0, x2, w[0])
+		x1, x3 = b.gsButterfly(x1, x3, w[1])
+		if invNttReduce(1, block) {
+			x0, x1 = b.barrettReduce(x0), b.barrettReduce(x1)
+		}
+
+		x0, x1 = b.gsButterfly(x0, x1, w[2])
+		x2, x3 = b.gsButterfly(x2, x3, w[2])
+
+		a[j] = b.montgomeryReduce(b.u32(nInvMontgomery * x0))
+		a[j+1] = b.u16(x1)
+		a[j+2] = b.montgomeryReduce(b.u32(nInvMontgomery * x2))
+		a[j+3] = b.u16(x3)
+	}
+}
+
+func (b *bounds) pointwise(x, y uint64) uint64 {
+	t := b.montgomeryReduce(montgomeryR2 * b.u16(y))
+	return b.montgomeryReduce(b.u32(b.u16(x) * t))
+}
+
+func (b *bounds) add(x, y uint64) uint64 {
+	return b.u16(x + y)
+}
+
+func (b *bounds) polySub(x, y uint64) uint64 {
+	return b.barrettReduce(b.u16(b.sub(x, 3*paramQ, y)))
+}
+
+// transform returns the bound on the output of fn for inputs bounded by x.
+func (b *bounds) transform(fn func(*[paramN]uint64), x uint64) uint64 {
+	var a [paramN]uint64
+	for i := range a {
+		a[i] = x
+	}
+	fn(&a)
+
+	max := uint64(0)
+	for _, v := range a {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+func TestBounds(t *testing.T) {
+	if !lazyReduction {
+		t.Skip("the arithmetic is fully reduced for q >= 2^14")
+	}
+	b := &bounds{t: t}
+
+	const (
+		noiseMax      = paramQ + paramK                                  // getNoise().
+		uniformMax    = uniformBound - 1                                 // uniform().
+		canonicalMax  = paramQ - 1                                       // A peer's key, after isCanonical().
+		msgMax        = paramQ / 2                                       // fromMsg().
+		decompressMax = ((1<<paramD-1)*paramQ + 1<<(paramD-1)) >> paramD // decompress().
+	)
+
+	// The transforms, with their documented output bounds.
+	b.op = "ntt"
+	nttMax := b.transform(b.ntt, 1<<14-1)
+	b.check(nttMax < 3*paramQ+4, "output bound %d", nttMax)
+	b.op = "invNtt"
+	invNttMax := b.transform(b.invNtt, 1<<14-1)
+	b.check(invNttMax < 1<<14, "output bound %d", invNttMax)
+
+	// Key generation (both variants): b <- as + e, encoded with toBytes().
+	b.op = "GenerateKeyPairAlice"
+	b.check(noiseMax < 1<<14, "ntt input %d out of range", noiseMax)
+	b.barrettReduce(b.add(nttMax, b.pointwise(nttMax, uniformMax)))
+
+	// NewHope Bob: u <- as' + e', and v <- bs' + e'', with v passed to
+	// f() as 8v + 4r by helpRec(), and to g() as at most 16q + 8v by rec().
+	b.op = "KeyExchangeBob"
+	b.barrettReduce(b.add(b.pointwise(uniformMax, nttMax), nttMax))
+	v := b.add(b.transform(b.invNtt, b.pointwise(canonicalMax, nttMax)), noiseMax)
+	b.check(8*v+4 <= fMax, "f input %d out of range", 8*v+4)
+	b.check(16*paramQ+8*v <= gMax, "g input %d out of range", 16*paramQ+8*v)
+
+	// NewHope Alice: v' <- us, passed to g() by rec().
+	b.op = "KeyExchangeAlice"
+	v = b.transform(b.invNtt, b.pointwise(nttMax, canonicalMax))
+	b.check(16*paramQ+8*v <= gMax, "g input %d out of range", 16*paramQ+8*v)
+
+	// NewHope-Simple Bob: u <- as' + e', and v <- bs' + e'' + m, which is
+	// compressed with coeffFreeze(), taking any uint16.
+	b.op = "KeyExchangeSimpleBob"
+	b.barrettReduce(b.add(b.pointwise(uniformMax, nttMax), nttMax))
+	b.add(b.add(b.transform(b.invNtt, b.pointwise(canonicalMax, nttMax)), noiseMax), msgMax)
+
+	// NewHope-Simple Alice: k <- v - us.
+	b.op = "KeyExchangeSimpleAlice"
+	b.polySub(b.transform(b.invNtt, b.pointwise(nttMax, canonicalMax)), decompressMax)
+}
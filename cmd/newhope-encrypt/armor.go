@@ -0,0 +1,178 @@
+// armor.go - PEM-like ASCII armor.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// encoding/pem requires the entire block to be in memory, so the armor is
+// implemented here as a streaming encoder/decoder with the same layout:
+// a header line, base64 in 64 column lines, and a footer line.
+const (
+	armorHeader  = "-----BEGIN NEWHOPE ENCRYPTED FILE-----"
+	armorFooter  = "-----END NEWHOPE ENCRYPTED FILE-----"
+	armorColumns = 64
+)
+
+var errArmor = errors.New("armor: malformed armored file")
+
+type lineWrapper struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := armorColumns - l.col
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		l.col += n
+		p = p[n:]
+
+		if l.col == armorColumns {
+			if _, err := l.w.Write([]byte{'\n'}); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+	}
+
+	return written, nil
+}
+
+type armorWriter struct {
+	w   io.Writer
+	lw  *lineWrapper
+	enc io.WriteCloser
+}
+
+func (a *armorWriter) Write(p []byte) (int, error) {
+	return a.enc.Write(p)
+}
+
+// Close flushes the final base64 quantum and writes the footer.  It does
+// not close the underlying writer.
+func (a *armorWriter) Close() error {
+	if err := a.enc.Close(); err != nil {
+		return err
+	}
+	if a.lw.col != 0 {
+		if _, err := io.WriteString(a.w, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(a.w, armorFooter+"\n")
+	return err
+}
+
+func newArmorWriter(w io.Writer) (*armorWriter, error) {
+	if _, err := io.WriteString(w, armorHeader+"\n"); err != nil {
+		return nil, err
+	}
+
+	lw := &lineWrapper{w: w}
+	return &armorWriter{
+		w:   w,
+		lw:  lw,
+		enc: base64.NewEncoder(base64.StdEncoding, lw),
+	}, nil
+}
+
+type armorBodyReader struct {
+	r    *bufio.Reader
+	line []byte
+	done bool
+}
+
+func (a *armorBodyReader) Read(p []byte) (int, error) {
+	for len(a.line) == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+
+		// Lines are bounded by the bufio.Reader's buffer, so that a
+		// malicious input can't make this consume unbounded memory.
+		line, err := a.r.ReadSlice('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF || err == bufio.ErrBufferFull {
+				err = errArmor // Missing footer, or absurdly long line.
+			}
+			return 0, err
+		}
+		line = bytes.TrimSpace(line)
+		if bytes.HasPrefix(line, []byte("-----")) {
+			if string(line) != armorFooter {
+				return 0, errArmor
+			}
+			a.done = true
+
+			// Only whitespace may follow the footer.
+			if err = skipWhitespace(a.r); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if len(line) > armorColumns {
+			return 0, errArmor
+		}
+		a.line = line
+	}
+
+	n := copy(p, a.line)
+	a.line = a.line[n:]
+
+	return n, nil
+}
+
+func skipWhitespace(r io.Reader) error {
+	var buf [512]byte
+	for {
+		n, err := r.Read(buf[:])
+		if len(bytes.TrimSpace(buf[:n])) != 0 {
+			return errArmor
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// isArmored returns true iff the buffered input starts with the armor
+// header.
+func isArmored(r *bufio.Reader) bool {
+	b, _ := r.Peek(len(armorHeader))
+	return string(b) == armorHeader
+}
+
+func newArmorReader(r *bufio.Reader) (io.Reader, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = errArmor
+		}
+		return nil, err
+	}
+	if string(bytes.TrimSpace(line)) != armorHeader {
+		return nil, errArmor
+	}
+
+	return base64.NewDecoder(base64.StdEncoding, &armorBodyReader{r: r}), nil
+}
@@ -0,0 +1,200 @@
+// format.go - Encrypted file format.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"gitlab.com/yawning/newhope.git"
+	"golang.org/x/crypto/hkdf"
+)
+
+// An encrypted file consists of:
+//
+//	magic         "newhope-encrypt/v1\n"
+//	count         uint16 big endian number of recipients
+//	stanzas       count * newhope.Seal(recipient, fileKey)
+//	nonce         16 random bytes
+//	mac           HMAC-SHA256 over all of the above
+//	payload       STREAM chunks
+//
+// The file key is random and wraps nothing but the payload, so the header
+// MAC and payload keys are derived from it with HKDF-SHA256.  Seal is built
+// on the CCA secure NewHope-Simple KEM, so a long-lived identity can try
+// every stanza without acting as a decryption oracle.
+const (
+	magic = "newhope-encrypt/v1\n"
+
+	fileKeySize   = 32
+	nonceSize     = 16
+	macSize       = sha256.Size
+	stanzaSize    = newhope.SealOverhead + fileKeySize
+	maxRecipients = 32
+
+	stanzaInfo  = "newhope-encrypt/v1 file key"
+	headerLabel = "header"
+	payloadInfo = "payload"
+)
+
+var (
+	errBadHeader       = errors.New("header: malformed header")
+	errHeaderMAC       = errors.New("header: MAC verification failed")
+	errNoIdentityMatch = errors.New("header: no recipient stanza matches the identity")
+)
+
+func headerMAC(fileKey, header []byte) []byte {
+	var macKey [32]byte
+	if _, err := io.ReadFull(hkdf.New(sha256.New, fileKey, nil, []byte(headerLabel)), macKey[:]); err != nil {
+		panic(err)
+	}
+
+	m := hmac.New(sha256.New, macKey[:])
+	_, _ = m.Write(header)
+	return m.Sum(nil)
+}
+
+func payloadKey(fileKey, nonce []byte) []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, fileKey, nonce, []byte(payloadInfo)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func memwipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// encrypt encrypts src to each of the recipients, and writes the result to
+// dst, armored if requested.
+func encrypt(rand io.Reader, dst io.Writer, src io.Reader, recipients []*newhope.PublicKeySimpleAlice, armor bool) error {
+	if len(recipients) == 0 || len(recipients) > maxRecipients {
+		return fmt.Errorf("encrypt: need between 1 and %d recipients", maxRecipients)
+	}
+
+	var fileKey [fileKeySize]byte
+	if _, err := io.ReadFull(rand, fileKey[:]); err != nil {
+		return err
+	}
+	defer memwipe(fileKey[:])
+
+	hdr := make([]byte, 0, len(magic)+2+len(recipients)*stanzaSize+nonceSize+macSize)
+	hdr = append(hdr, magic...)
+	hdr = append(hdr, byte(len(recipients)>>8), byte(len(recipients)))
+	for _, pub := range recipients {
+		stanza, err := newhope.Seal(rand, pub, []byte(stanzaInfo), nil, fileKey[:])
+		if err != nil {
+			return err
+		}
+		hdr = append(hdr, stanza...)
+	}
+	nonce := hdr[len(hdr) : len(hdr)+nonceSize]
+	if _, err := io.ReadFull(rand, nonce); err != nil {
+		return err
+	}
+	hdr = hdr[:len(hdr)+nonceSize]
+	hdr = append(hdr, headerMAC(fileKey[:], hdr)...)
+
+	var aw *armorWriter
+	if armor {
+		var err error
+		if aw, err = newArmorWriter(dst); err != nil {
+			return err
+		}
+		dst = aw
+	}
+	if _, err := dst.Write(hdr); err != nil {
+		return err
+	}
+
+	key := payloadKey(fileKey[:], nonce)
+	defer memwipe(key)
+	sw, err := newStreamWriter(key, dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(sw, src); err != nil {
+		return err
+	}
+	if err = sw.Close(); err != nil {
+		return err
+	}
+	if aw != nil {
+		return aw.Close()
+	}
+
+	return nil
+}
+
+// decrypt decrypts src with the identity, and writes the plaintext to dst.
+// The payload is authenticated chunk by chunk, so on failure dst may
+// already contain the authentic prefix of the plaintext.
+func decrypt(dst io.Writer, src io.Reader, identity *newhope.PrivateKeySimpleAlice) error {
+	br := bufio.NewReader(src)
+	if isArmored(br) {
+		r, err := newArmorReader(br)
+		if err != nil {
+			return err
+		}
+		br = bufio.NewReader(r)
+	}
+
+	hdr := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return errBadHeader
+	}
+	if string(hdr[:len(magic)]) != magic {
+		return errBadHeader
+	}
+	count := int(binary.BigEndian.Uint16(hdr[len(magic):]))
+	if count == 0 || count > maxRecipients {
+		return errBadHeader
+	}
+	off := len(hdr)
+	hdr = append(hdr, make([]byte, count*stanzaSize+nonceSize+macSize)...)
+	if _, err := io.ReadFull(br, hdr[off:]); err != nil {
+		return errBadHeader
+	}
+
+	// Stop at the first stanza that opens.
+	var fileKey []byte
+	for i := 0; i < count; i++ {
+		stanza := hdr[off+i*stanzaSize : off+(i+1)*stanzaSize]
+		if k, err := newhope.Open(identity, []byte(stanzaInfo), nil, stanza); err == nil {
+			fileKey = k
+			break
+		}
+	}
+	if fileKey == nil {
+		return errNoIdentityMatch
+	}
+	defer memwipe(fileKey)
+
+	macOff := len(hdr) - macSize
+	if !hmac.Equal(headerMAC(fileKey, hdr[:macOff]), hdr[macOff:]) {
+		return errHeaderMAC
+	}
+
+	key := payloadKey(fileKey, hdr[macOff-nonceSize:macOff])
+	defer memwipe(key)
+	sr, err := newStreamReader(key, br)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, sr)
+
+	return err
+}
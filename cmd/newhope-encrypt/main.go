@@ -0,0 +1,259 @@
+// main.go - NewHope-Simple file encryption tool.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+// newhope-encrypt encrypts files to one or more NewHope-Simple public keys.
+//
+// Usage:
+//
+//	newhope-encrypt keygen -o identity.pem > recipient.pem
+//	newhope-encrypt pubkey -i identity.pem > recipient.pem
+//	newhope-encrypt encrypt [-a] -r recipient.pem [-r ...] [-o out] [in]
+//	newhope-encrypt decrypt -i identity.pem [-o out] [in]
+//
+// Input defaults to stdin, and output to stdout.  Large files are processed
+// in constant memory.  An output file is only created once all of the
+// output has been written, and when decrypting, authenticated.  When
+// decrypting to stdout, the plaintext is released chunk by chunk, so on
+// failure stdout may already have received its authentic prefix.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/yawning/newhope.git"
+)
+
+const (
+	privateKeyPEMType = "NEWHOPE SIMPLE PRIVATE KEY"
+	publicKeyPEMType  = "NEWHOPE SIMPLE PUBLIC KEY"
+)
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s keygen|pubkey|encrypt|decrypt [flags]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "keygen":
+		err = runKeygen(args)
+	case "pubkey":
+		err = runPubkey(args)
+	case "encrypt":
+		err = runEncrypt(args)
+	case "decrypt":
+		err = runDecrypt(args)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	outFile := fs.String("o", "", "identity (private key) output file")
+	_ = fs.Parse(args)
+	if *outFile == "" || fs.NArg() != 0 {
+		return errors.New("keygen: -o is required")
+	}
+
+	priv, pub, err := newhope.GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		return err
+	}
+	defer priv.Reset()
+
+	b, err := priv.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	defer memwipe(b)
+
+	f, err := os.OpenFile(*outFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err = pem.Encode(f, &pem.Block{Type: privateKeyPEMType, Bytes: b}); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return pem.Encode(os.Stdout, &pem.Block{Type: publicKeyPEMType, Bytes: pub.Send[:]})
+}
+
+func runPubkey(args []string) error {
+	fs := flag.NewFlagSet("pubkey", flag.ExitOnError)
+	idFile := fs.String("i", "", "identity (private key) file")
+	_ = fs.Parse(args)
+	if *idFile == "" || fs.NArg() != 0 {
+		return errors.New("pubkey: -i is required")
+	}
+
+	priv, err := loadIdentity(*idFile)
+	if err != nil {
+		return err
+	}
+	defer priv.Reset()
+
+	return pem.Encode(os.Stdout, &pem.Block{Type: publicKeyPEMType, Bytes: priv.PublicKey().Send[:]})
+}
+
+func runEncrypt(args []string) error {
+	var recipientFiles stringsFlag
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	fs.Var(&recipientFiles, "r", "recipient (public key) file, may be repeated")
+	armor := fs.Bool("a", false, "armor the output")
+	outFile := fs.String("o", "", "output file")
+	_ = fs.Parse(args)
+	if len(recipientFiles) == 0 || fs.NArg() > 1 {
+		return errors.New("encrypt: at least one -r is required")
+	}
+
+	var recipients []*newhope.PublicKeySimpleAlice
+	for _, fn := range recipientFiles {
+		pub, err := loadRecipient(fn)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, pub)
+	}
+
+	return withFiles(fs.Arg(0), *outFile, func(dst io.Writer, src io.Reader) error {
+		return encrypt(rand.Reader, dst, src, recipients, *armor)
+	})
+}
+
+func runDecrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	idFile := fs.String("i", "", "identity (private key) file")
+	outFile := fs.String("o", "", "output file")
+	_ = fs.Parse(args)
+	if *idFile == "" || fs.NArg() > 1 {
+		return errors.New("decrypt: -i is required")
+	}
+
+	priv, err := loadIdentity(*idFile)
+	if err != nil {
+		return err
+	}
+	defer priv.Reset()
+
+	return withFiles(fs.Arg(0), *outFile, func(dst io.Writer, src io.Reader) error {
+		return decrypt(dst, src, priv)
+	})
+}
+
+func withFiles(inFile, outFile string, fn func(io.Writer, io.Reader) error) error {
+	var src io.Reader = os.Stdin
+	if inFile != "" && inFile != "-" {
+		f, err := os.Open(inFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	if outFile == "" || outFile == "-" {
+		return fn(os.Stdout, src)
+	}
+
+	// Write to a temporary file alongside the output, and only rename it
+	// into place once fn succeeds, so that a payload that fails to
+	// authenticate never leaves any of its plaintext behind in outFile.
+	f, err := ioutil.TempFile(filepath.Dir(outFile), "."+filepath.Base(outFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	if err = fn(f, src); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), outFile)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func loadPEM(fn, pemType string) ([]byte, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer memwipe(b)
+
+	blk, _ := pem.Decode(b)
+	if blk == nil || blk.Type != pemType {
+		return nil, fmt.Errorf("%s: not a %s", fn, pemType)
+	}
+	return blk.Bytes, nil
+}
+
+func loadIdentity(fn string) (*newhope.PrivateKeySimpleAlice, error) {
+	b, err := loadPEM(fn, privateKeyPEMType)
+	if err != nil {
+		return nil, err
+	}
+	defer memwipe(b)
+
+	priv := new(newhope.PrivateKeySimpleAlice)
+	if err = priv.UnmarshalBinary(b); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return priv, nil
+}
+
+func loadRecipient(fn string) (*newhope.PublicKeySimpleAlice, error) {
+	b, err := loadPEM(fn, publicKeyPEMType)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != newhope.SendASimpleSize {
+		return nil, fmt.Errorf("%s: invalid public key length", fn)
+	}
+
+	pub := new(newhope.PublicKeySimpleAlice)
+	copy(pub.Send[:], b)
+	return pub, nil
+}
@@ -0,0 +1,164 @@
+// main_test.go - NewHope-Simple file encryption tool tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/yawning/newhope.git"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	var privs []*newhope.PrivateKeySimpleAlice
+	var pubs []*newhope.PublicKeySimpleAlice
+	for i := 0; i < 3; i++ {
+		priv, pub, err := newhope.GenerateKeyPairSimpleAlice(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+		}
+		privs = append(privs, priv)
+		pubs = append(pubs, pub)
+	}
+
+	for _, armor := range []bool{false, true} {
+		for _, sz := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize} {
+			plaintext := make([]byte, sz)
+			if _, err := rand.Read(plaintext); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+
+			var ciphertext bytes.Buffer
+			if err := encrypt(rand.Reader, &ciphertext, bytes.NewReader(plaintext), pubs[:2], armor); err != nil {
+				t.Fatalf("encrypt(%d, %v) failed: %v", sz, armor, err)
+			}
+			if armor != strings.HasPrefix(ciphertext.String(), armorHeader) {
+				t.Fatalf("encrypt(%d, %v): unexpected armoring", sz, armor)
+			}
+
+			for _, priv := range privs[:2] {
+				var decrypted bytes.Buffer
+				if err := decrypt(&decrypted, bytes.NewReader(ciphertext.Bytes()), priv); err != nil {
+					t.Fatalf("decrypt(%d, %v) failed: %v", sz, armor, err)
+				}
+				if !bytes.Equal(decrypted.Bytes(), plaintext) {
+					t.Fatalf("decrypt(%d, %v): plaintext mismatched", sz, armor)
+				}
+			}
+
+			var discard bytes.Buffer
+			if err := decrypt(&discard, bytes.NewReader(ciphertext.Bytes()), privs[2]); err != errNoIdentityMatch {
+				t.Fatalf("decrypt(%d, %v) with wrong identity: %v", sz, armor, err)
+			}
+		}
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	priv, pub, err := newhope.GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	plaintext := make([]byte, 3*chunkSize+17)
+	var ciphertext bytes.Buffer
+	if err = encrypt(rand.Reader, &ciphertext, bytes.NewReader(plaintext), []*newhope.PublicKeySimpleAlice{pub}, false); err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	ct := ciphertext.Bytes()
+	hdrLen := len(ct) - (3*encChunkSize + 17 + 16)
+
+	// Corrupting the second chunk must be reported as such, after the
+	// first chunk has been released.
+	tampered := append([]byte{}, ct...)
+	tampered[hdrLen+encChunkSize+5] ^= 0x80
+	var out bytes.Buffer
+	err = decrypt(&out, bytes.NewReader(tampered), priv)
+	if ce, ok := err.(*chunkError); !ok || ce.index != 1 {
+		t.Fatalf("decrypt with corrupted chunk: %v", err)
+	}
+	if out.Len() != chunkSize {
+		t.Fatalf("decrypt with corrupted chunk released %d bytes", out.Len())
+	}
+
+	// Dropping the final chunk makes the previous one fail, as it was not
+	// sealed as the last chunk.
+	out.Reset()
+	err = decrypt(&out, bytes.NewReader(ct[:hdrLen+3*encChunkSize]), priv)
+	if ce, ok := err.(*chunkError); !ok || ce.index != 2 {
+		t.Fatalf("decrypt with truncated payload: %v", err)
+	}
+
+	// Corrupting the header is caught by the MAC.
+	tampered = append([]byte{}, ct...)
+	tampered[hdrLen-macSize-1] ^= 0x01
+	if err = decrypt(&out, bytes.NewReader(tampered), priv); err != errHeaderMAC {
+		t.Fatalf("decrypt with corrupted header: %v", err)
+	}
+}
+
+func TestDecryptOutputFile(t *testing.T) {
+	priv, pub, err := newhope.GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	plaintext := make([]byte, 3*chunkSize+17)
+	var ciphertext bytes.Buffer
+	if err = encrypt(rand.Reader, &ciphertext, bytes.NewReader(plaintext), []*newhope.PublicKeySimpleAlice{pub}, false); err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	ct := ciphertext.Bytes()
+
+	dir, err := ioutil.TempDir("", "newhope-encrypt")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inFile, outFile := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+	decryptFile := func() error {
+		return withFiles(inFile, outFile, func(dst io.Writer, src io.Reader) error {
+			return decrypt(dst, src, priv)
+		})
+	}
+
+	// Corrupting the last chunk must not leave the authentic chunks that
+	// preceded it, or anything else, behind.
+	tampered := append([]byte{}, ct...)
+	tampered[len(tampered)-1] ^= 0x01
+	if err = ioutil.WriteFile(inFile, tampered, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err = decryptFile(); err == nil {
+		t.Fatalf("decrypt with corrupted chunk succeeded")
+	}
+	if fis, _ := ioutil.ReadDir(dir); len(fis) != 1 {
+		t.Fatalf("decrypt with corrupted chunk left %d files behind", len(fis)-1)
+	}
+
+	if err = ioutil.WriteFile(inFile, ct, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err = decryptFile(); err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	decrypted, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("plaintext mismatched")
+	}
+}
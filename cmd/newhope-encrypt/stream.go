@@ -0,0 +1,208 @@
+// stream.go - STREAM chunked AEAD.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package main
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The payload is split into chunks of chunkSize bytes, each of which is
+// sealed with ChaCha20Poly1305 under a nonce consisting of an 88 bit big
+// endian chunk counter and a flag byte that is set only for the final
+// chunk, as in the STREAM construction of Hoang, Reyhanitabar, Rogaway and
+// Vizár.  This prevents chunks from being reordered, dropped or appended
+// without detection, while keeping memory usage constant.
+const (
+	chunkSize    = 64 * 1024
+	encChunkSize = chunkSize + tagSize
+
+	lastChunkFlag = 0x01
+	tagSize       = 16 // Poly1305 tag
+)
+
+var errStreamOverflow = errors.New("stream: chunk counter overflow")
+
+// chunkError is the error returned when a chunk fails to authenticate.
+type chunkError struct {
+	index uint64
+}
+
+func (e *chunkError) Error() string {
+	return fmt.Sprintf("stream: chunk %d: authentication failed", e.index)
+}
+
+type streamNonce [chacha20poly1305.NonceSize]byte
+
+func (n *streamNonce) set(counter uint64, last bool) {
+	for i := range n {
+		n[i] = 0
+	}
+	for i := 0; i < 8; i++ {
+		n[len(n)-2-i] = byte(counter >> (8 * uint(i)))
+	}
+	if last {
+		n[len(n)-1] = lastChunkFlag
+	}
+}
+
+type streamWriter struct {
+	aead    cipher.AEAD
+	w       io.Writer
+	buf     []byte
+	n       int
+	counter uint64
+	nonce   streamNonce
+	closed  bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("stream: write after close")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// Only flush a full chunk once more data arrives, so that the
+		// final chunk is always the one sealed by Close.
+		if s.n == chunkSize {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[s.n:chunkSize], p)
+		s.n += n
+		written += n
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// Close seals and writes the final chunk.  It does not close the
+// underlying writer.
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+func (s *streamWriter) flush(last bool) error {
+	if s.counter == 1<<64-1 {
+		return errStreamOverflow
+	}
+	s.nonce.set(s.counter, last)
+	ct := s.aead.Seal(s.buf[:0], s.nonce[:], s.buf[:s.n], nil)
+	if _, err := s.w.Write(ct); err != nil {
+		return err
+	}
+	s.counter++
+	s.n = 0
+
+	return nil
+}
+
+func newStreamWriter(key []byte, w io.Writer) (*streamWriter, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{
+		aead: aead,
+		w:    w,
+		buf:  make([]byte, encChunkSize),
+	}, nil
+}
+
+type streamReader struct {
+	aead    cipher.AEAD
+	r       *bufio.Reader
+	buf     []byte
+	pt      []byte
+	counter uint64
+	nonce   streamNonce
+	err     error
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.pt) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.err = s.readChunk()
+	}
+
+	n := copy(p, s.pt)
+	s.pt = s.pt[n:]
+
+	return n, nil
+}
+
+func (s *streamReader) readChunk() error {
+	n, err := io.ReadFull(s.r, s.buf)
+	switch err {
+	case nil:
+	case io.ErrUnexpectedEOF:
+	case io.EOF:
+		// The final chunk is always present, even for an empty payload.
+		return fmt.Errorf("stream: chunk %d: truncated", s.counter)
+	default:
+		return err
+	}
+
+	// A full chunk is the final one iff it is immediately followed by EOF.
+	last := n < encChunkSize
+	if !last {
+		if _, err = s.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if n < tagSize || (!last && s.counter == 1<<64-1) {
+		return &chunkError{s.counter}
+	}
+
+	s.nonce.set(s.counter, last)
+	pt, err := s.aead.Open(s.buf[:0], s.nonce[:], s.buf[:n], nil)
+	if err != nil {
+		return &chunkError{s.counter}
+	}
+	if last && len(pt) == 0 && s.counter != 0 {
+		// Only an empty payload may have an empty final chunk.
+		return &chunkError{s.counter}
+	}
+	s.counter++
+	s.pt = pt
+
+	if last {
+		return io.EOF
+	}
+	return nil
+}
+
+func newStreamReader(key []byte, r io.Reader) (*streamReader, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		aead: aead,
+		r:    bufio.NewReader(r),
+		buf:  make([]byte, encChunkSize),
+	}, nil
+}
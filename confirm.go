@@ -0,0 +1,124 @@
+// confirm.go - NewHope key confirmation.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ConfirmationTagSize is the length of a key confirmation tag in bytes.
+const ConfirmationTagSize = 32
+
+var (
+	confirmDomainNewHope = []byte("NewHope-20160815 key confirmation")
+	confirmDomainSimple  = []byte("NewHope-Simple key confirmation")
+
+	confirmRoleAlice = []byte("Alice")
+	confirmRoleBob   = []byte("Bob")
+)
+
+// KeyConfirmation binds a shared secret to the transcript of the exchange
+// that produced it, and generates and verifies confirmation tags.
+//
+// The tags are HMAC-SHA256 over the transcript, keyed with a key derived
+// from the shared secret.  After completing the exchange, Bob sends
+// BobTag() along with his public key, which lets Alice detect a failed
+// exchange via VerifyBobTag() before using the shared secret.  Alice may
+// optionally send AliceTag() back as a third message, for Bob to check with
+// VerifyAliceTag().
+type KeyConfirmation struct {
+	key        [32]byte
+	transcript [32]byte
+	reset      bool
+}
+
+// NewKeyConfirmation returns a KeyConfirmation for the shared secret
+// derived from a NewHope exchange of alicePk and bobPk.
+func NewKeyConfirmation(alicePk *PublicKeyAlice, bobPk *PublicKeyBob, sharedSecret []byte) *KeyConfirmation {
+	return newKeyConfirmation(confirmDomainNewHope, alicePk.Send[:], bobPk.Send[:], sharedSecret)
+}
+
+// NewKeyConfirmationSimple returns a KeyConfirmation for the shared secret
+// derived from a NewHope-Simple exchange of alicePk and bobPk.
+func NewKeyConfirmationSimple(alicePk *PublicKeySimpleAlice, bobPk *PublicKeySimpleBob, sharedSecret []byte) *KeyConfirmation {
+	return newKeyConfirmation(confirmDomainSimple, alicePk.Send[:], bobPk.Send[:], sharedSecret)
+}
+
+func newKeyConfirmation(domain, aliceMsg, bobMsg, sharedSecret []byte) *KeyConfirmation {
+	c := new(KeyConfirmation)
+
+	// The messages are fixed length for a given domain, so simple
+	// concatenation is unambiguous.
+	h := sha3.New256()
+	_, _ = h.Write(domain)
+	_, _ = h.Write(aliceMsg)
+	_, _ = h.Write(bobMsg)
+	h.Sum(c.transcript[:0])
+
+	m := hmac.New(sha256.New, sharedSecret)
+	_, _ = m.Write(domain)
+	m.Sum(c.key[:0])
+
+	return c
+}
+
+// BobTag returns the tag that Bob sends to Alice.  It returns
+// ErrKeyConfirmationReset once the KeyConfirmation has been Reset().
+func (c *KeyConfirmation) BobTag() ([]byte, error) {
+	return c.tag(confirmRoleBob)
+}
+
+// AliceTag returns the tag that Alice sends to Bob.  It returns
+// ErrKeyConfirmationReset once the KeyConfirmation has been Reset().
+func (c *KeyConfirmation) AliceTag() ([]byte, error) {
+	return c.tag(confirmRoleAlice)
+}
+
+// VerifyBobTag checks the tag received from Bob, in constant time.  It
+// returns ErrKeyConfirmationReset once the KeyConfirmation has been Reset().
+func (c *KeyConfirmation) VerifyBobTag(tag []byte) error {
+	return c.verify(confirmRoleBob, tag)
+}
+
+// VerifyAliceTag checks the tag received from Alice, in constant time.  It
+// returns ErrKeyConfirmationReset once the KeyConfirmation has been Reset().
+func (c *KeyConfirmation) VerifyAliceTag(tag []byte) error {
+	return c.verify(confirmRoleAlice, tag)
+}
+
+// Reset clears all sensitive information such that it no longer appears in
+// memory, after which the KeyConfirmation can no longer be used.
+func (c *KeyConfirmation) Reset() {
+	memwipe(c.key[:])
+	c.reset = true
+}
+
+func (c *KeyConfirmation) tag(role []byte) ([]byte, error) {
+	if c.reset {
+		return nil, ErrKeyConfirmationReset
+	}
+
+	m := hmac.New(sha256.New, c.key[:])
+	_, _ = m.Write(role)
+	_, _ = m.Write(c.transcript[:])
+	return m.Sum(nil), nil
+}
+
+func (c *KeyConfirmation) verify(role, tag []byte) error {
+	expected, err := c.tag(role)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, tag) {
+		return ErrKeyConfirmationFailed
+	}
+	return nil
+}
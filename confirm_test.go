@@ -0,0 +1,124 @@
+// confirm_test.go - NewHope key confirmation tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestKeyConfirmation(t *testing.T) {
+	TorSampling = false
+
+	alicePriv, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+	}
+	bobPub, bobShared, err := KeyExchangeBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeBob failed: %v", err)
+	}
+	bobKc := NewKeyConfirmation(alicePub, bobPub, bobShared)
+	bobTag, err := bobKc.BobTag()
+	if err != nil {
+		t.Fatalf("BobTag failed: %v", err)
+	}
+
+	// Tamper with the reconciliation data, before Alice sees it.
+	tamperedPub := *bobPub
+	tamperedPub.Send[PolyBytes] ^= 0x01
+	tamperedPriv := *alicePriv
+	tamperedShared, err := KeyExchangeAlice(&tamperedPub, &tamperedPriv)
+	if err != nil {
+		t.Fatalf("KeyExchangeAlice failed: %v", err)
+	}
+	if err = NewKeyConfirmation(alicePub, &tamperedPub, tamperedShared).VerifyBobTag(bobTag); err != ErrKeyConfirmationFailed {
+		t.Fatalf("VerifyBobTag with tampered message: %v", err)
+	}
+
+	aliceShared, err := KeyExchangeAlice(bobPub, alicePriv)
+	if err != nil {
+		t.Fatalf("KeyExchangeAlice failed: %v", err)
+	}
+	aliceKc := NewKeyConfirmation(alicePub, bobPub, aliceShared)
+	if err = aliceKc.VerifyBobTag(bobTag); err != nil {
+		t.Fatalf("VerifyBobTag failed: %v", err)
+	}
+	if err = aliceKc.VerifyAliceTag(bobTag); err != ErrKeyConfirmationFailed {
+		t.Fatalf("VerifyAliceTag with reflected tag: %v", err)
+	}
+
+	// Optional third message.
+	aliceTag, err := aliceKc.AliceTag()
+	if err != nil {
+		t.Fatalf("AliceTag failed: %v", err)
+	}
+	if err = bobKc.VerifyAliceTag(aliceTag); err != nil {
+		t.Fatalf("VerifyAliceTag failed: %v", err)
+	}
+	if err = bobKc.VerifyAliceTag(aliceTag[1:]); err != ErrKeyConfirmationFailed {
+		t.Fatalf("VerifyAliceTag with truncated tag: %v", err)
+	}
+
+	// A cleared KeyConfirmation can neither generate nor verify tags.
+	aliceKc.Reset()
+	if _, err = aliceKc.AliceTag(); err != ErrKeyConfirmationReset {
+		t.Fatalf("AliceTag after Reset: %v", err)
+	}
+	if _, err = aliceKc.BobTag(); err != ErrKeyConfirmationReset {
+		t.Fatalf("BobTag after Reset: %v", err)
+	}
+	if err = aliceKc.VerifyBobTag(bobTag); err != ErrKeyConfirmationReset {
+		t.Fatalf("VerifyBobTag after Reset: %v", err)
+	}
+	if err = aliceKc.VerifyAliceTag(aliceTag); err != ErrKeyConfirmationReset {
+		t.Fatalf("VerifyAliceTag after Reset: %v", err)
+	}
+}
+
+func TestKeyConfirmationSimple(t *testing.T) {
+	for _, mismatch := range []bool{false, true} {
+		// Alice and Bob disagreeing on the sampling method makes the
+		// exchange fail, which must be caught.
+		if mismatch && !torSamplingSupported {
+			continue
+		}
+		TorSampling = mismatch
+		alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+		}
+
+		TorSampling = false
+		bobPub, bobShared, err := KeyExchangeSimpleBob(rand.Reader, alicePub)
+		if err != nil {
+			t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+		}
+		bobTag, err := NewKeyConfirmationSimple(alicePub, bobPub, bobShared).BobTag()
+		if err != nil {
+			t.Fatalf("BobTag failed: %v", err)
+		}
+		if len(bobTag) != ConfirmationTagSize {
+			t.Fatalf("tag length %d, expected %d", len(bobTag), ConfirmationTagSize)
+		}
+
+		aliceShared, err := KeyExchangeSimpleAlice(bobPub, alicePriv)
+		if err != nil {
+			t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+		}
+		aliceKc := NewKeyConfirmationSimple(alicePub, bobPub, aliceShared)
+		err = aliceKc.VerifyBobTag(bobTag)
+		switch {
+		case mismatch && err != ErrKeyConfirmationFailed:
+			t.Fatalf("VerifyBobTag with sampling mismatch: %v", err)
+		case !mismatch && err != nil:
+			t.Fatalf("VerifyBobTag failed: %v", err)
+		}
+		aliceKc.Reset()
+	}
+}
@@ -0,0 +1,154 @@
+// context_test.go - NewHope cancellation tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowReader sleeps before each Read, for longer than the deadlines the
+// tests use, until it is released.
+type slowReader struct {
+	released int32
+	reads    int32
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	atomic.AddInt32(&r.reads, 1)
+	if atomic.LoadInt32(&r.released) == 0 {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return rand.Read(p)
+}
+
+func TestContext(t *testing.T) {
+	TorSampling = false
+
+	_, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+	}
+	_, aliceSimplePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	ops := []struct {
+		name string
+		fn   func(context.Context, *slowReader) error
+	}{
+		{"GenerateKeyPairAliceContext", func(ctx context.Context, r *slowReader) error {
+			_, _, err := GenerateKeyPairAliceContext(ctx, r)
+			return err
+		}},
+		{"KeyExchangeBobContext", func(ctx context.Context, r *slowReader) error {
+			_, _, err := KeyExchangeBobContext(ctx, r, alicePub)
+			return err
+		}},
+		{"GenerateKeyPairSimpleAliceContext", func(ctx context.Context, r *slowReader) error {
+			_, _, err := GenerateKeyPairSimpleAliceContext(ctx, r)
+			return err
+		}},
+		{"KeyExchangeSimpleBobContext", func(ctx context.Context, r *slowReader) error {
+			_, _, err := KeyExchangeSimpleBobContext(ctx, r, aliceSimplePub)
+			return err
+		}},
+	}
+
+	for _, op := range ops {
+		r := new(slowReader)
+
+		// Already canceled.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err = op.fn(ctx, r); err != context.Canceled {
+			t.Fatalf("%s with a canceled context: %v", op.name, err)
+		}
+
+		// Deadline expires while reading from the entropy source, which is
+		// not read from again.
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if err = op.fn(ctx, r); err != context.DeadlineExceeded {
+			t.Fatalf("%s with a slow reader: %v", op.name, err)
+		}
+		cancel()
+		reads := atomic.LoadInt32(&r.reads)
+		time.Sleep(10 * time.Millisecond)
+		if atomic.LoadInt32(&r.reads) != reads {
+			t.Fatalf("%s read in the background after returning", op.name)
+		}
+
+		// Completes normally.
+		atomic.StoreInt32(&r.released, 1)
+		ctx, cancel = context.WithCancel(context.Background())
+		if err = op.fn(ctx, r); err != nil {
+			t.Fatalf("%s failed: %v", op.name, err)
+		}
+		cancel()
+	}
+}
+
+func TestReadRandom(t *testing.T) {
+	var b [SeedBytes]byte
+	src := bytes.Repeat([]byte{0xa5}, len(b))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := readRandom(ctx, bytes.NewReader(src), b[:]); err != nil {
+		t.Fatalf("readRandom failed: %v", err)
+	}
+	if !bytes.Equal(b[:], src) {
+		t.Fatalf("readRandom returned the wrong data")
+	}
+
+	if err := readRandom(ctx, bytes.NewReader(src[1:]), b[:]); err == nil {
+		t.Fatalf("readRandom with a short reader succeeded")
+	}
+}
+
+func TestReadRandomCancel(t *testing.T) {
+	// A context that is canceled during a read fails the call once the
+	// seed has been read in full.
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &cancelingReader{cancel: cancel}
+	var b [SeedBytes]byte
+	if err := readRandom(ctx, r, b[:]); err != context.Canceled {
+		t.Fatalf("readRandom with a canceled context: %v", err)
+	}
+	if r.reads != 1 || r.n != len(b) {
+		t.Fatalf("readRandom read %d bytes in %d calls", r.n, r.reads)
+	}
+
+	// Without a cancelable context, the seed is still read in one call.
+	r = &cancelingReader{cancel: func() {}}
+	if err := readRandom(context.Background(), r, b[:]); err != nil {
+		t.Fatalf("readRandom failed: %v", err)
+	}
+	if r.reads != 1 || r.n != len(b) {
+		t.Fatalf("readRandom read %d bytes in %d calls", r.n, r.reads)
+	}
+}
+
+// cancelingReader cancels the context on every Read.
+type cancelingReader struct {
+	cancel context.CancelFunc
+	reads  int
+	n      int
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	r.reads++
+	r.n += len(p)
+	return len(p), nil
+}
@@ -0,0 +1,37 @@
+// entropy.go - NewHope entropy source handling.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"context"
+	"io"
+)
+
+// readRandom fills b from the reader, in a single call to io.ReadFull, so
+// that a seed is never split across reads.  If the context can be
+// canceled, it is checked before and after the read, so a sequence of
+// calls returns ctx.Err() between seeds.  A Read that blocks can not be
+// interrupted, but no read is ever left running in the background, so no
+// entropy is lost to an abandoned read, and the reader need not be safe for
+// concurrent use.
+func readRandom(ctx context.Context, rand io.Reader, b []byte) error {
+	if ctx.Done() == nil {
+		if _, err := io.ReadFull(rand, b); err != nil {
+			return wrapError(ErrShortRandom, err)
+		}
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return wrapError(ErrShortRandom, err)
+	}
+	return ctx.Err()
+}
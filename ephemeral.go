@@ -0,0 +1,273 @@
+// ephemeral.go - NewHope precomputed ephemeral keys.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The background refill retries failed key generations after a delay,
+// which doubles with every consecutive failure, between these bounds.
+const (
+	ephemeralMinBackoff = 10 * time.Millisecond
+	ephemeralMaxBackoff = time.Second
+)
+
+// EphemeralPoolStats are the counters of an EphemeralPool.  Each field is
+// tracked separately for the NewHope and NewHope-Simple key pairs.
+type EphemeralPoolStats struct {
+	// Ready is the number of key pairs currently queued.
+	Ready int
+
+	// Generated is the number of key pairs generated in the background.
+	Generated uint64
+
+	// Served is the number of key pairs handed out.
+	Served uint64
+
+	// Misses is the number of requests that found the queue empty, and had
+	// to wait for a key pair to be generated.
+	Misses uint64
+
+	// Wiped is the number of key pairs obliterated without being handed
+	// out, because the pool was closed.
+	Wiped uint64
+
+	// Failures is the number of background key generations that failed.
+	Failures uint64
+
+	// Err is the error of the most recent background key generation, if it
+	// failed, and nil once one succeeds again.
+	Err error
+}
+
+// EphemeralPool keeps bounded queues of freshly generated key pairs for
+// Alice, which are refilled in the background, taking key generation off
+// the critical path of an exchange.  Each key pair is handed out exactly
+// once, and the ones remaining when the pool is closed are obliterated.
+//
+// A failed key generation, for example due to a transient error from the
+// entropy source, is reported to a request that is waiting for a key pair,
+// and retried with an exponential backoff, so the pool recovers once the
+// cause goes away.
+//
+// All methods are safe for concurrent use.
+type EphemeralPool struct {
+	alice  ephemeralQueue
+	simple ephemeralQueue
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	closeOnce sync.Once
+}
+
+type ephemeralKeyPair struct {
+	priv interface{ Reset() }
+	pub  interface{}
+}
+
+type ephemeralQueue struct {
+	// 64 bit alignment for atomic access on 32 bit targets.
+	generated uint64
+	served    uint64
+	misses    uint64
+	wiped     uint64
+	failures  uint64
+
+	ch   chan ephemeralKeyPair
+	errs chan error
+
+	l   sync.Mutex
+	err error
+}
+
+// NewEphemeralPool creates an EphemeralPool that keeps up to aliceCapacity
+// NewHope and simpleCapacity NewHope-Simple key pairs ready, generated with
+// entropy from rand.  Capacities below one are treated as one.  The reader
+// is serialized internally, so it need not be safe for concurrent use.
+//
+// The options, which may be nil, and the value of TorSampling when the pool
+// is created apply to every key pair it generates.
+func NewEphemeralPool(rand io.Reader, aliceCapacity, simpleCapacity int, opts *KeyOptions) *EphemeralPool {
+	p := new(EphemeralPool)
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	torSampling, rc, enc := TorSampling, opts.reconciler(), opts.encoding()
+	rand = &lockedReader{r: rand}
+	p.alice.start(p, aliceCapacity, func(s *scratch) (ephemeralKeyPair, error) {
+		priv, pub, err := s.generateKeyPairAlice(p.ctx, rand, torSampling, rc)
+		return ephemeralKeyPair{priv, pub}, err
+	})
+	p.simple.start(p, simpleCapacity, func(s *scratch) (ephemeralKeyPair, error) {
+		priv, pub, err := s.generateKeyPairSimpleAlice(p.ctx, rand, torSampling, enc)
+		return ephemeralKeyPair{priv, pub}, err
+	})
+
+	return p
+}
+
+// GetAlice returns a NewHope key pair from the pool, waiting for one to be
+// generated if none is ready.  It returns ErrPoolClosed if the pool is
+// closed, or the error of a background key generation that failed while it
+// was waiting.
+func (p *EphemeralPool) GetAlice(ctx context.Context) (*PrivateKeyAlice, *PublicKeyAlice, error) {
+	kp, err := p.alice.get(ctx, p.ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kp.priv.(*PrivateKeyAlice), kp.pub.(*PublicKeyAlice), nil
+}
+
+// GetSimpleAlice returns a NewHope-Simple key pair from the pool, and
+// behaves as GetAlice.
+func (p *EphemeralPool) GetSimpleAlice(ctx context.Context) (*PrivateKeySimpleAlice, *PublicKeySimpleAlice, error) {
+	kp, err := p.simple.get(ctx, p.ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kp.priv.(*PrivateKeySimpleAlice), kp.pub.(*PublicKeySimpleAlice), nil
+}
+
+// Stats returns the counters for the NewHope and NewHope-Simple key pairs.
+func (p *EphemeralPool) Stats() (alice, simple EphemeralPoolStats) {
+	return p.alice.stats(), p.simple.stats()
+}
+
+// Close stops the background refill, and obliterates all of the key pairs
+// that were not handed out.
+func (p *EphemeralPool) Close() {
+	p.closeOnce.Do(func() {
+		p.cancel()
+		p.wg.Wait()
+		p.alice.drain()
+		p.simple.drain()
+	})
+}
+
+func (q *ephemeralQueue) start(p *EphemeralPool, capacity int, generate func(*scratch) (ephemeralKeyPair, error)) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	q.ch = make(chan ephemeralKeyPair, capacity)
+	q.errs = make(chan error)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		var s scratch
+		defer s.release()
+		backoff := ephemeralMinBackoff
+		for {
+			kp, err := generate(&s)
+			s.reset()
+			if err != nil {
+				if p.ctx.Err() != nil {
+					return
+				}
+				atomic.AddUint64(&q.failures, 1)
+				q.setErr(err)
+
+				// Hand the error to a request that is waiting, if any,
+				// and retry after the backoff.
+				select {
+				case q.errs <- err:
+				default:
+				}
+				t := time.NewTimer(backoff)
+				select {
+				case <-t.C:
+				case <-p.ctx.Done():
+					t.Stop()
+					return
+				}
+				if backoff *= 2; backoff > ephemeralMaxBackoff {
+					backoff = ephemeralMaxBackoff
+				}
+				continue
+			}
+			q.setErr(nil)
+			backoff = ephemeralMinBackoff
+			atomic.AddUint64(&q.generated, 1)
+
+			select {
+			case q.ch <- kp:
+			case <-p.ctx.Done():
+				kp.priv.Reset()
+				atomic.AddUint64(&q.wiped, 1)
+				return
+			}
+		}
+	}()
+}
+
+func (q *ephemeralQueue) setErr(err error) {
+	q.l.Lock()
+	defer q.l.Unlock()
+	q.err = err
+}
+
+func (q *ephemeralQueue) get(ctx, poolCtx context.Context) (ephemeralKeyPair, error) {
+	if poolCtx.Err() != nil {
+		return ephemeralKeyPair{}, ErrPoolClosed
+	}
+
+	// Always prefer a ready key pair, even if the refill is failing.
+	select {
+	case kp := <-q.ch:
+		atomic.AddUint64(&q.served, 1)
+		return kp, nil
+	default:
+	}
+	atomic.AddUint64(&q.misses, 1)
+
+	select {
+	case kp := <-q.ch:
+		atomic.AddUint64(&q.served, 1)
+		return kp, nil
+	case err := <-q.errs:
+		return ephemeralKeyPair{}, err
+	case <-poolCtx.Done():
+		return ephemeralKeyPair{}, ErrPoolClosed
+	case <-ctx.Done():
+		return ephemeralKeyPair{}, ctx.Err()
+	}
+}
+
+func (q *ephemeralQueue) drain() {
+	for {
+		select {
+		case kp := <-q.ch:
+			kp.priv.Reset()
+			atomic.AddUint64(&q.wiped, 1)
+		default:
+			return
+		}
+	}
+}
+
+func (q *ephemeralQueue) stats() EphemeralPoolStats {
+	st := EphemeralPoolStats{
+		Ready:     len(q.ch),
+		Generated: atomic.LoadUint64(&q.generated),
+		Served:    atomic.LoadUint64(&q.served),
+		Misses:    atomic.LoadUint64(&q.misses),
+		Wiped:     atomic.LoadUint64(&q.wiped),
+		Failures:  atomic.LoadUint64(&q.failures),
+	}
+	q.l.Lock()
+	st.Err = q.err
+	q.l.Unlock()
+	return st
+}
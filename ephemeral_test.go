@@ -0,0 +1,183 @@
+// ephemeral_test.go - NewHope precomputed ephemeral key tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEphemeralPool(t *testing.T) {
+	const (
+		capacity = 4
+		nCallers = 4
+		nGets    = 8
+	)
+
+	TorSampling = false
+	p := NewEphemeralPool(rand.Reader, capacity, capacity, nil)
+	ctx := context.Background()
+
+	// Each key pair must be handed out exactly once, even to concurrent
+	// callers.
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < nCallers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < nGets; j++ {
+				_, alicePub, err := p.GetAlice(ctx)
+				if err != nil {
+					t.Errorf("GetAlice failed: %v", err)
+					return
+				}
+				_, aliceSimplePub, err := p.GetSimpleAlice(ctx)
+				if err != nil {
+					t.Errorf("GetSimpleAlice failed: %v", err)
+					return
+				}
+
+				mu.Lock()
+				for _, k := range []string{string(alicePub.Send[:]), string(aliceSimplePub.Send[:])} {
+					if seen[k] {
+						t.Errorf("key pair handed out twice")
+					}
+					seen[k] = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The key pairs must be usable.
+	alicePriv, alicePub, err := p.GetAlice(ctx)
+	if err != nil {
+		t.Fatalf("GetAlice failed: %v", err)
+	}
+	bobPub, bobShared, err := KeyExchangeBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeBob failed: %v", err)
+	}
+	aliceShared, err := KeyExchangeAlice(bobPub, alicePriv)
+	if err != nil {
+		t.Fatalf("KeyExchangeAlice failed: %v", err)
+	}
+	if !bytes.Equal(aliceShared, bobShared) {
+		t.Fatalf("shared secrets mismatched")
+	}
+
+	// Wait for the queues to fill, and check that closing the pool wipes
+	// every key pair that is left.
+	for {
+		alice, simple := p.Stats()
+		if alice.Ready == capacity && simple.Ready == capacity {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	p.Close()
+
+	alice, simple := p.Stats()
+	if alice.Ready != 0 || simple.Ready != 0 {
+		t.Fatalf("unexpected Ready after Close: %d, %d", alice.Ready, simple.Ready)
+	}
+	if alice.Served != nCallers*nGets+1 || simple.Served != nCallers*nGets {
+		t.Fatalf("unexpected Served: %d, %d", alice.Served, simple.Served)
+	}
+	if alice.Wiped < capacity || simple.Wiped < capacity {
+		t.Fatalf("unexpected Wiped: %d, %d", alice.Wiped, simple.Wiped)
+	}
+	if alice.Generated != alice.Served+alice.Wiped || simple.Generated != simple.Served+simple.Wiped {
+		t.Fatalf("key pairs unaccounted for: %+v, %+v", alice, simple)
+	}
+	if alice.Failures != 0 || alice.Err != nil || simple.Failures != 0 || simple.Err != nil {
+		t.Fatalf("unexpected failures: %+v, %+v", alice, simple)
+	}
+	if _, _, err = p.GetAlice(ctx); err != ErrPoolClosed {
+		t.Fatalf("GetAlice on closed pool: %v", err)
+	}
+}
+
+// flakyReader fails while failing is set, and reads from crypto/rand
+// otherwise.
+type flakyReader struct {
+	failing int32
+	err     error
+}
+
+func (r *flakyReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&r.failing) != 0 {
+		return 0, r.err
+	}
+	return rand.Read(p)
+}
+
+func TestEphemeralPoolError(t *testing.T) {
+	errRand := errors.New("entropy source failed")
+
+	TorSampling = false
+	r := &flakyReader{failing: 1, err: errRand}
+	p := NewEphemeralPool(r, 1, 1, nil)
+	defer p.Close()
+
+	// Each request that waits during a failure gets the error.
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, _, err := p.GetAlice(ctx); !errors.Is(err, errRand) || !errors.Is(err, ErrShortRandom) {
+			t.Fatalf("GetAlice with failing reader: %v", err)
+		}
+		if _, _, err := p.GetSimpleAlice(ctx); !errors.Is(err, errRand) || !errors.Is(err, ErrShortRandom) {
+			t.Fatalf("GetSimpleAlice with failing reader: %v", err)
+		}
+	}
+	alice, simple := p.Stats()
+	for _, st := range []EphemeralPoolStats{alice, simple} {
+		if !errors.Is(st.Err, errRand) || st.Failures < 2 || st.Misses != 2 || st.Generated != 0 {
+			t.Fatalf("unexpected stats: %+v", st)
+		}
+	}
+
+	// The refill recovers once the reader does.  A generation that was
+	// already failing may still report its error to the first request.
+	atomic.StoreInt32(&r.failing, 0)
+	for _, get := range []func(context.Context) error{
+		func(ctx context.Context) error {
+			_, _, err := p.GetAlice(ctx)
+			return err
+		},
+		func(ctx context.Context) error {
+			_, _, err := p.GetSimpleAlice(ctx)
+			return err
+		},
+	} {
+		err := get(ctx)
+		if errors.Is(err, errRand) {
+			err = get(ctx)
+		}
+		if err != nil {
+			t.Fatalf("Get after the reader recovered: %v", err)
+		}
+	}
+	alice, simple = p.Stats()
+	for _, st := range []EphemeralPoolStats{alice, simple} {
+		if st.Err != nil || st.Served != 1 {
+			t.Fatalf("unexpected stats: %+v", st)
+		}
+	}
+}
@@ -7,19 +7,23 @@
 
 package newhope
 
-import "gitlab.com/yawning/chacha20.git"
-
 func abs(v int32) int32 {
 	mask := v >> 31
 	return (v ^ mask) - mask
 }
 
+// fMul is floor(2^25 / q), which f() and g() use to estimate x/q and
+// x/4q, to within 1.
+const fMul = (1 << 25) / paramQ
+
+// f sets v0 to round(x/2q) and v1 to round((x - q)/2q), rounding halves up,
+// and returns |x - 2q v0|, for 0 <= x <= 8(2^16 - 1) + 4.
 func f(v0, v1 *int32, x int32) int32 {
 	// The`ref` code uses uint32 for x, but none of the values ever get large
 	// enough for that, and that would be cast-tastic due to Go being Go.
 
 	// Next 6 lines compute t = x/PARAM_Q
-	b := x * 2730
+	b := x * fMul
 	t := b >> 25
 	b = x - t*paramQ
 	b = (paramQ - 1) - b
@@ -37,9 +41,11 @@ func f(v0, v1 *int32, x int32) int32 {
 	return abs(x - ((*v0) * 2 * paramQ))
 }
 
+// g returns the distance from x to the nearest multiple of 8q, for
+// 0 <= x <= 16q + 8(2^16 - 1).
 func g(x int32) int32 {
 	// Next 6 lines compute t = x/(4 *PARAMQ)
-	b := x * 2730
+	b := x * fMul
 	t := b >> 27
 	b = x - t*(paramQ*4)
 	b = (paramQ * 4) - b
@@ -65,7 +71,7 @@ func llDecode(xi0, xi1, xi2, xi3 int32) int16 {
 	return int16(t & 1)
 }
 
-func (c *poly) helpRec(v *poly, seed *[SeedBytes]byte, nonce byte) {
+func (c *poly) helpRec(s *sampler, v *poly, seed *[SeedBytes]byte, nonce byte) error {
 	var v0, v1, vTmp [4]int32
 	var k int32
 	var rand [32]byte
@@ -73,9 +79,9 @@ func (c *poly) helpRec(v *poly, seed *[SeedBytes]byte, nonce byte) {
 
 	n[7] = nonce
 
-	stream, err := chacha20.New(seed[:], n[:])
+	stream, err := s.chacha20(seed[:], n[:])
 	if err != nil {
-		panic(err)
+		return err
 	}
 	stream.KeyStream(rand[:])
 	stream.Reset()
@@ -108,6 +114,8 @@ func (c *poly) helpRec(v *poly, seed *[SeedBytes]byte, nonce byte) {
 	for i := range vTmp {
 		vTmp[i] = 0
 	}
+
+	return nil
 }
 
 func rec(key *[32]byte, v, c *poly) {
@@ -0,0 +1,112 @@
+// errors.go - NewHope errors.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import "errors"
+
+var (
+	// ErrInvalidPublicKey is the error returned when a peer's public key is
+	// missing, or does not hold a well formed polynomial.
+	ErrInvalidPublicKey = errors.New("newhope: invalid public key")
+
+	// ErrInvalidPrivateKey is the error returned when deserializing a malformed
+	// private key.
+	ErrInvalidPrivateKey = errors.New("newhope: invalid private key")
+
+	// ErrKeyConsumed is the error returned when a private key is used after
+	// it has been consumed by an exchange, or cleared by Reset(), or if it
+	// was never generated at all.
+	ErrKeyConsumed = errors.New("newhope: private key already consumed")
+
+	// ErrInvalidReconciler is the error returned when a key pair is generated
+	// with, or a public key carries, an unknown Reconciler.
+	ErrInvalidReconciler = errors.New("newhope: invalid reconciler")
+
+	// ErrInvalidMessageEncoding is the error returned when a key pair is
+	// generated with, or a public key carries, an unknown MessageEncoding.
+	ErrInvalidMessageEncoding = errors.New("newhope: invalid message encoding")
+
+	// ErrShortRandom is the error returned when the entropy source fails to
+	// provide enough random data.  The returned error matches both it and the
+	// reader's own error with errors.Is().
+	ErrShortRandom = errors.New("newhope: failed to read random data")
+
+	// ErrSamplingMismatch is the error returned when one of the deterministic
+	// samplers can not be set up, in which case its output would not match the
+	// peer's.  The returned error matches both it and the underlying error with
+	// errors.Is().
+	ErrSamplingMismatch = errors.New("newhope: sampler failure")
+
+	// ErrSecureMemory is the error returned when secure memory is enabled, and
+	// the locked memory can not be allocated, or released.  The returned error
+	// matches both it and the underlying error with errors.Is().
+	ErrSecureMemory = errors.New("newhope: secure memory failure")
+
+	// ErrSelfTestFailed is the error returned by every operation once a
+	// self-test or a pairwise consistency check has failed.  The returned error
+	// matches both it and the cause of the failure with errors.Is().
+	ErrSelfTestFailed = errors.New("newhope: self-test failed")
+
+	// ErrPoolClosed is the error returned when submitting work to a closed Pool.
+	ErrPoolClosed = errors.New("newhope: pool closed")
+
+	// ErrKeyConfirmationFailed is the error returned when a key confirmation
+	// tag does not match, indicating that the peers derived different shared
+	// secrets or saw different messages.
+	ErrKeyConfirmationFailed = errors.New("newhope: key confirmation failed")
+
+	// ErrKeyConfirmationReset is the error returned when a KeyConfirmation
+	// is used after it has been cleared by Reset().
+	ErrKeyConfirmationReset = errors.New("newhope: key confirmation already reset")
+
+	// ErrInvalidKDF is the error returned when a KeySchedule is requested
+	// with an unknown KDF.
+	ErrInvalidKDF = errors.New("newhope: invalid KDF")
+
+	// ErrInvalidOutputLength is the error returned when a KeySchedule is
+	// asked for an output length the KDF can't provide.
+	ErrInvalidOutputLength = errors.New("newhope: invalid key schedule output length")
+
+	// ErrKeyScheduleReset is the error returned when a KeySchedule is used
+	// after it has been cleared by Reset().
+	ErrKeyScheduleReset = errors.New("newhope: key schedule already reset")
+
+	// ErrInvalidCiphertext is the error returned by Open when the ciphertext
+	// is truncated, or has an unsupported version or cipher suite.
+	ErrInvalidCiphertext = errors.New("newhope: invalid ciphertext")
+
+	// ErrOpenFailed is the error returned by Open when the ciphertext fails
+	// to authenticate.
+	ErrOpenFailed = errors.New("newhope: message authentication failed")
+)
+
+// wrappedError is one of the package's sentinel errors, annotated with the
+// underlying error that caused it.  errors.Is() matches either of them.
+type wrappedError struct {
+	sentinel error
+	err      error
+}
+
+func wrapError(sentinel, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &wrappedError{sentinel, err}
+}
+
+func (e *wrappedError) Error() string {
+	return e.sentinel.Error() + ": " + e.err.Error()
+}
+
+func (e *wrappedError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
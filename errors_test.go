@@ -0,0 +1,297 @@
+// errors_test.go - NewHope error tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// shortReader returns a reader that runs dry after n random bytes.
+func shortReader(t *testing.T, n int) io.Reader {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+	return bytes.NewReader(b)
+}
+
+func TestShortRandom(t *testing.T) {
+	TorSampling = false
+	_, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+	}
+	_, alicePubSimple, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	// Each operation reads SeedBytes at a time, so check running dry at
+	// the start, partway through and at the end of each read.
+	for _, op := range []struct {
+		name string
+		need int
+		fn   func(io.Reader) error
+	}{
+		{"GenerateKeyPairAlice", 2 * SeedBytes, func(r io.Reader) error {
+			_, _, err := GenerateKeyPairAlice(r)
+			return err
+		}},
+		{"KeyExchangeBob", SeedBytes, func(r io.Reader) error {
+			_, _, err := KeyExchangeBob(r, alicePub)
+			return err
+		}},
+		{"GenerateKeyPairSimpleAlice", 2 * SeedBytes, func(r io.Reader) error {
+			_, _, err := GenerateKeyPairSimpleAlice(r)
+			return err
+		}},
+		{"KeyExchangeSimpleBob", 2 * SeedBytes, func(r io.Reader) error {
+			_, _, err := KeyExchangeSimpleBob(r, alicePubSimple)
+			return err
+		}},
+		{"Seal", SharedSecretSize, func(r io.Reader) error {
+			_, err := Seal(r, alicePubSimple, nil, nil, nil)
+			return err
+		}},
+	} {
+		for n := 0; n < op.need; n += SeedBytes / 2 {
+			for _, m := range []int{n, n + 1} {
+				err := op.fn(shortReader(t, m))
+				if !errors.Is(err, ErrShortRandom) {
+					t.Fatalf("%s with %d bytes: %v, expected ErrShortRandom", op.name, m, err)
+				}
+				if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Fatalf("%s with %d bytes: %v does not wrap the reader's error", op.name, m, err)
+				}
+			}
+		}
+		if err := op.fn(shortReader(t, op.need)); err != nil {
+			t.Fatalf("%s with %d bytes failed: %v", op.name, op.need, err)
+		}
+	}
+}
+
+func TestInvalidPublicKey(t *testing.T) {
+	TorSampling = false
+	alicePriv, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+	}
+	bobPub, _, err := KeyExchangeBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeBob failed: %v", err)
+	}
+	alicePrivSimple, alicePubSimple, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	bobPubSimple, _, err := KeyExchangeSimpleBob(rand.Reader, alicePubSimple)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+	}
+
+	// Every encoded polynomial starts with a paramQBits bit coefficient,
+	// which must be less than q.
+	badAlicePub, badBobPub := *alicePub, *bobPub
+	badAlicePubSimple, badBobPubSimple := *alicePubSimple, *bobPubSimple
+	for _, b := range [][]byte{badAlicePub.Send[:], badBobPub.Send[:], badAlicePubSimple.Send[:], badBobPubSimple.Send[:]} {
+		setFirstCoeff(b, paramQ)
+	}
+
+	for _, op := range []struct {
+		name string
+		fn   func() error
+	}{
+		{"KeyExchangeBob(nil)", func() error {
+			_, _, err := KeyExchangeBob(rand.Reader, nil)
+			return err
+		}},
+		{"KeyExchangeBob", func() error {
+			_, _, err := KeyExchangeBob(rand.Reader, &badAlicePub)
+			return err
+		}},
+		{"KeyExchangeSimpleBob(nil)", func() error {
+			_, _, err := KeyExchangeSimpleBob(rand.Reader, nil)
+			return err
+		}},
+		{"KeyExchangeSimpleBob", func() error {
+			_, _, err := KeyExchangeSimpleBob(rand.Reader, &badAlicePubSimple)
+			return err
+		}},
+		{"Seal", func() error {
+			_, err := Seal(rand.Reader, &badAlicePubSimple, nil, nil, nil)
+			return err
+		}},
+		{"KeyExchangeAlice", func() error {
+			sk := *alicePriv
+			_, err := KeyExchangeAlice(&badBobPub, &sk)
+			return err
+		}},
+		{"KeyExchangeAlice(nil)", func() error {
+			sk := *alicePriv
+			_, err := KeyExchangeAlice(nil, &sk)
+			return err
+		}},
+		{"KeyExchangeSimpleAlice", func() error {
+			sk := *alicePrivSimple
+			_, err := KeyExchangeSimpleAlice(&badBobPubSimple, &sk)
+			return err
+		}},
+		{"KeyExchangeSimpleAlice(nil)", func() error {
+			sk := *alicePrivSimple
+			_, err := KeyExchangeSimpleAlice(nil, &sk)
+			return err
+		}},
+	} {
+		if err := op.fn(); err != ErrInvalidPublicKey {
+			t.Fatalf("%s: %v, expected ErrInvalidPublicKey", op.name, err)
+		}
+	}
+
+	// Open reports a malformed encapsulated key as a bad ciphertext.
+	ciphertext, err := Seal(rand.Reader, alicePubSimple, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	copy(ciphertext[SealHeaderSize:], badBobPubSimple.Send[:2])
+	if _, err = Open(alicePrivSimple, nil, nil, ciphertext); err != ErrInvalidCiphertext {
+		t.Fatalf("Open: %v, expected ErrInvalidCiphertext", err)
+	}
+}
+
+func TestSamplingMismatch(t *testing.T) {
+	var s sampler
+	var key [SeedBytes]byte
+	var nonce [8]byte
+
+	// Fail both when creating the ChaCha20 instance, and when rekeying it.
+	if _, err := s.chacha20(key[:1], nonce[:]); !errors.Is(err, ErrSamplingMismatch) {
+		t.Fatalf("chacha20 with a short key: %v, expected ErrSamplingMismatch", err)
+	}
+	if _, err := s.chacha20(key[:], nonce[:]); err != nil {
+		t.Fatalf("chacha20 failed: %v", err)
+	}
+	if _, err := s.chacha20(key[:], nonce[:1]); !errors.Is(err, ErrSamplingMismatch) {
+		t.Fatalf("chacha20 with a short nonce: %v, expected ErrSamplingMismatch", err)
+	}
+}
+
+func TestKeyConsumed(t *testing.T) {
+	TorSampling = false
+	alicePriv, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+	}
+	bobPub, _, err := KeyExchangeBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeBob failed: %v", err)
+	}
+	alicePrivSimple, alicePubSimple, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	bobPubSimple, _, err := KeyExchangeSimpleBob(rand.Reader, alicePubSimple)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+	}
+	ciphertext, err := Seal(rand.Reader, alicePubSimple, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	b, err := alicePrivSimple.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var decoded PrivateKeySimpleAlice
+	if err = decoded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	// The first use succeeds, and every later one fails.
+	if _, err = KeyExchangeAlice(bobPub, alicePriv); err != nil {
+		t.Fatalf("KeyExchangeAlice failed: %v", err)
+	}
+	if _, err = KeyExchangeAlice(bobPub, alicePriv); err != ErrKeyConsumed {
+		t.Fatalf("KeyExchangeAlice with a consumed key: %v", err)
+	}
+	if _, err = Open(alicePrivSimple, nil, nil, ciphertext); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err = KeyExchangeSimpleAlice(bobPubSimple, alicePrivSimple); err != nil {
+		t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+	}
+	if _, err = KeyExchangeSimpleAlice(bobPubSimple, alicePrivSimple); err != ErrKeyConsumed {
+		t.Fatalf("KeyExchangeSimpleAlice with a consumed key: %v", err)
+	}
+	if _, err = Open(alicePrivSimple, nil, nil, ciphertext); err != ErrKeyConsumed {
+		t.Fatalf("Open with a consumed key: %v", err)
+	}
+	if _, err = alicePrivSimple.MarshalBinary(); err != ErrKeyConsumed {
+		t.Fatalf("MarshalBinary with a consumed key: %v", err)
+	}
+
+	// A deserialized key is independent of the original, until Reset().
+	if _, err = Open(&decoded, nil, nil, ciphertext); err != nil {
+		t.Fatalf("Open with a deserialized key failed: %v", err)
+	}
+	decoded.Reset()
+	if _, err = Open(&decoded, nil, nil, ciphertext); err != ErrKeyConsumed {
+		t.Fatalf("Open after Reset: %v", err)
+	}
+
+	// Keys that were never generated are rejected as well.
+	if _, err = KeyExchangeAlice(bobPub, new(PrivateKeyAlice)); err != ErrKeyConsumed {
+		t.Fatalf("KeyExchangeAlice with a zero key: %v", err)
+	}
+	if _, err = KeyExchangeSimpleAlice(bobPubSimple, nil); err != ErrKeyConsumed {
+		t.Fatalf("KeyExchangeSimpleAlice with a nil key: %v", err)
+	}
+}
+
+// testError is an underlying error with a concrete type, for errors.As().
+type testError struct{ msg string }
+
+func (e *testError) Error() string { return e.msg }
+
+func TestWrapError(t *testing.T) {
+	sentinels := []error{ErrShortRandom, ErrSamplingMismatch, ErrSecureMemory, ErrSelfTestFailed}
+	for _, sentinel := range sentinels {
+		cause := &testError{"cause"}
+		err := wrapError(sentinel, cause)
+		if err.Error() != sentinel.Error()+": cause" {
+			t.Fatalf("unexpected message: %q", err.Error())
+		}
+		if !errors.Is(err, sentinel) || !errors.Is(err, cause) {
+			t.Fatalf("%v does not match its sentinel and cause", err)
+		}
+		for _, other := range sentinels {
+			if other != sentinel && errors.Is(err, other) {
+				t.Fatalf("%v matches %v", err, other)
+			}
+		}
+		var target *testError
+		if !errors.As(err, &target) || target != cause {
+			t.Fatalf("errors.As(%v) failed", err)
+		}
+		if wrapError(sentinel, nil) != nil {
+			t.Fatalf("wrapError(%v, nil) is not nil", sentinel)
+		}
+	}
+
+	// Through a real failure.
+	cause := &testError{"entropy source failed"}
+	_, _, err := GenerateKeyPairSimpleAlice(&flakyReader{failing: 1, err: cause})
+	var target *testError
+	if !errors.Is(err, ErrShortRandom) || !errors.As(err, &target) || target != cause {
+		t.Fatalf("GenerateKeyPairSimpleAlice with a failing reader: %v", err)
+	}
+}
@@ -1,8 +1,9 @@
 module gitlab.com/yawning/newhope.git
 
-go 1.12
+go 1.13
 
 require (
 	gitlab.com/yawning/chacha20.git v0.0.0-20190902183103-644b09ac4e6e
 	golang.org/x/crypto v0.0.0-20190829043050-9756ffdc2472
+	golang.org/x/sys v0.0.0-20190902133755-9109b7679e13
 )
@@ -0,0 +1,182 @@
+// hpke.go - NewHope-Simple hybrid public key encryption.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// SealVersion is the version of the ciphertext format produced by Seal.
+	SealVersion = 1
+
+	// SealHeaderSize is the length of the ciphertext header in bytes,
+	// consisting of the version and the KEM/KDF/AEAD identifiers.
+	SealHeaderSize = 1 + 2 + 2 + 2
+
+	// SealOverhead is the number of bytes Seal adds to a plaintext.
+	SealOverhead = SealHeaderSize + SendBSimpleSize + hpkeTagSize
+
+	// The KEM identifier is not IANA assigned, and is picked from the
+	// unassigned range so that it can never collide with a real suite.
+	hpkeKEMID  = 0xfe01 // NewHope-Simple CCA KEM, HKDF-SHA256
+	hpkeKDFID  = 0x0001 // HKDF-SHA256
+	hpkeAEADID = 0x0003 // ChaCha20Poly1305
+
+	hpkeModeBase = 0x00
+	hpkeTagSize  = 16 // Poly1305 tag
+)
+
+var hpkeVersionLabel = []byte("HPKE-v1")
+
+// Seal encrypts and authenticates plaintext and authenticates aad to the
+// NewHope-Simple public key pub, using the given reader, which must return
+// random data.  The construction is modeled on the RFC 9180 base mode, with
+// EncapsulateSimple serving as the KEM, HKDF-SHA256 as the KDF, and
+// ChaCha20Poly1305 as the AEAD, but it is not an interoperable HPKE suite.
+// The info parameter is bound to the derived key, and must be supplied
+// unaltered to Open.
+//
+// The sender and the recipient *MUST* agree on TorSampling.
+func Seal(rand io.Reader, pub *PublicKeySimpleAlice, info, aad, plaintext []byte) ([]byte, error) {
+	ct, ss, err := EncapsulateSimple(rand, pub)
+	if err != nil {
+		return nil, err
+	}
+	defer memwipe(ss)
+
+	aead, nonce := hpkeKeySchedule(ss, ct, pub, info)
+
+	out := make([]byte, SealHeaderSize+SendBSimpleSize, SealOverhead+len(plaintext))
+	hpkeEncodeHeader(out)
+	copy(out[SealHeaderSize:], ct.Send[:])
+
+	return aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+// Open authenticates and decrypts a ciphertext produced by Seal, with the
+// NewHope-Simple private key priv.  The private key is left intact so that
+// it may be used to open further ciphertexts, until it is Reset().  As the
+// KEM uses implicit rejection, every ciphertext that was tampered with
+// fails with ErrOpenFailed, so repeated calls to Open reveal nothing about
+// the private key.
+func Open(priv *PrivateKeySimpleAlice, info, aad, ciphertext []byte) ([]byte, error) {
+	if priv == nil || priv.key() == nil {
+		return nil, ErrKeyConsumed
+	}
+	if len(ciphertext) < SealOverhead {
+		return nil, ErrInvalidCiphertext
+	}
+
+	var hdr [SealHeaderSize]byte
+	hpkeEncodeHeader(hdr[:])
+	if subtle.ConstantTimeCompare(hdr[:], ciphertext[:SealHeaderSize]) != 1 {
+		return nil, ErrInvalidCiphertext
+	}
+
+	ct := new(PublicKeySimpleBob)
+	copy(ct.Send[:], ciphertext[SealHeaderSize:])
+	ss, err := DecapsulateSimple(priv, ct)
+	if err != nil {
+		return nil, err
+	}
+	defer memwipe(ss)
+
+	aead, nonce := hpkeKeySchedule(ss, ct, &priv.pub, info)
+	plaintext, err := aead.Open(nil, nonce, ciphertext[SealHeaderSize+SendBSimpleSize:], aad)
+	if err != nil {
+		return nil, ErrOpenFailed
+	}
+
+	return plaintext, nil
+}
+
+func hpkeEncodeHeader(b []byte) {
+	b[0] = SealVersion
+	binary.BigEndian.PutUint16(b[1:], hpkeKEMID)
+	binary.BigEndian.PutUint16(b[3:], hpkeKDFID)
+	binary.BigEndian.PutUint16(b[5:], hpkeAEADID)
+}
+
+func hpkeKeySchedule(ss []byte, ct *PublicKeySimpleBob, pub *PublicKeySimpleAlice, info []byte) (cipher.AEAD, []byte) {
+	var kemSuiteID [5]byte
+	copy(kemSuiteID[:], "KEM")
+	binary.BigEndian.PutUint16(kemSuiteID[3:], hpkeKEMID)
+
+	var suiteID [10]byte
+	copy(suiteID[:], "HPKE")
+	binary.BigEndian.PutUint16(suiteID[4:], hpkeKEMID)
+	binary.BigEndian.PutUint16(suiteID[6:], hpkeKDFID)
+	binary.BigEndian.PutUint16(suiteID[8:], hpkeAEADID)
+
+	// shared_secret <- ExtractAndExpand(ss, enc || pkR)
+	kemContext := make([]byte, 0, SendBSimpleSize+SendASimpleSize)
+	kemContext = append(kemContext, ct.Send[:]...)
+	kemContext = append(kemContext, pub.Send[:]...)
+	eaePrk := hpkeLabeledExtract(kemSuiteID[:], nil, "eae_prk", ss)
+	sharedSecret := hpkeLabeledExpand(kemSuiteID[:], eaePrk, "shared_secret", kemContext, SharedSecretSize)
+	defer memwipe(eaePrk)
+	defer memwipe(sharedSecret)
+
+	// KeySchedule(mode_base, shared_secret, info, default_psk, default_psk_id)
+	pskIDHash := hpkeLabeledExtract(suiteID[:], nil, "psk_id_hash", nil)
+	infoHash := hpkeLabeledExtract(suiteID[:], nil, "info_hash", info)
+	ksContext := make([]byte, 0, 1+len(pskIDHash)+len(infoHash))
+	ksContext = append(ksContext, hpkeModeBase)
+	ksContext = append(ksContext, pskIDHash...)
+	ksContext = append(ksContext, infoHash...)
+
+	secret := hpkeLabeledExtract(suiteID[:], sharedSecret, "secret", nil)
+	defer memwipe(secret)
+	key := hpkeLabeledExpand(suiteID[:], secret, "key", ksContext, chacha20poly1305.KeySize)
+	defer memwipe(key)
+	nonce := hpkeLabeledExpand(suiteID[:], secret, "base_nonce", ksContext, chacha20poly1305.NonceSize)
+
+	// The nonce is base_nonce XOR I2OSP(0, Nn), as only a single message is
+	// ever sealed under each key.
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		panic(err) // The key size is fixed, so this should never happen.
+	}
+
+	return aead, nonce
+}
+
+func hpkeLabeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := make([]byte, 0, len(hpkeVersionLabel)+len(suiteID)+len(label)+len(ikm))
+	labeledIKM = append(labeledIKM, hpkeVersionLabel...)
+	labeledIKM = append(labeledIKM, suiteID...)
+	labeledIKM = append(labeledIKM, label...)
+	labeledIKM = append(labeledIKM, ikm...)
+	defer memwipe(labeledIKM)
+
+	return hkdf.Extract(sha256.New, labeledIKM, salt)
+}
+
+func hpkeLabeledExpand(suiteID, prk []byte, label string, info []byte, l int) []byte {
+	labeledInfo := make([]byte, 2, 2+len(hpkeVersionLabel)+len(suiteID)+len(label)+len(info))
+	binary.BigEndian.PutUint16(labeledInfo, uint16(l))
+	labeledInfo = append(labeledInfo, hpkeVersionLabel...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+
+	out := make([]byte, l)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, labeledInfo), out); err != nil {
+		panic(err) // The lengths are fixed, so this should never happen.
+	}
+
+	return out
+}
@@ -0,0 +1,156 @@
+// hpke_test.go - NewHope-Simple hybrid public key encryption tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	TorSampling = false
+
+	priv, pub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	info := []byte("newhope hpke test")
+	aad := []byte("additional data")
+	for _, sz := range []int{0, 1, 32, 4096} {
+		plaintext := make([]byte, sz)
+		if _, err = rand.Read(plaintext); err != nil {
+			t.Fatalf("rand.Read failed: %v", err)
+		}
+
+		ciphertext, err := Seal(rand.Reader, pub, info, aad, plaintext)
+		if err != nil {
+			t.Fatalf("Seal failed: %v", err)
+		}
+		if len(ciphertext) != len(plaintext)+SealOverhead {
+			t.Fatalf("ciphertext length %d, expected %d", len(ciphertext), len(plaintext)+SealOverhead)
+		}
+		if ciphertext[0] != SealVersion {
+			t.Fatalf("ciphertext version %d, expected %d", ciphertext[0], SealVersion)
+		}
+
+		// The private key is not consumed, so it can open repeatedly.
+		for i := 0; i < 2; i++ {
+			opened, err := Open(priv, info, aad, ciphertext)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Fatalf("plaintext mismatched")
+			}
+		}
+
+		if _, err = Open(priv, []byte("wrong info"), aad, ciphertext); err != ErrOpenFailed {
+			t.Fatalf("Open with wrong info: %v", err)
+		}
+		if _, err = Open(priv, info, []byte("wrong aad"), ciphertext); err != ErrOpenFailed {
+			t.Fatalf("Open with wrong aad: %v", err)
+		}
+
+		tampered := append([]byte{}, ciphertext...)
+		tampered[len(tampered)-1] ^= 0x01
+		if _, err = Open(priv, info, aad, tampered); err != ErrOpenFailed {
+			t.Fatalf("Open with tampered ciphertext: %v", err)
+		}
+
+		tampered = append([]byte{}, ciphertext...)
+		tampered[0] = SealVersion + 1
+		if _, err = Open(priv, info, aad, tampered); err != ErrInvalidCiphertext {
+			t.Fatalf("Open with bad version: %v", err)
+		}
+
+		if _, err = Open(priv, info, aad, ciphertext[:SealOverhead-1]); err != ErrInvalidCiphertext {
+			t.Fatalf("Open with truncated ciphertext: %v", err)
+		}
+	}
+
+	// A different recipient can't open the ciphertext.
+	otherPriv, _, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	ciphertext, err := Seal(rand.Reader, pub, info, aad, []byte("attack at dawn"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if _, err = Open(otherPriv, info, aad, ciphertext); err != ErrOpenFailed {
+		t.Fatalf("Open with wrong key: %v", err)
+	}
+}
+
+func TestOpenNoOracle(t *testing.T) {
+	TorSampling = false
+
+	priv, pub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	info := []byte("newhope hpke test")
+	plaintext := []byte("attack at dawn")
+	ciphertext, err := Seal(rand.Reader, pub, info, nil, plaintext)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	// Shifting a single coefficient of v usually leaves the decrypted
+	// message, and so the CPA secure shared secret, unchanged, and whether it
+	// does is what a key mismatch attack probes for.  Every such ciphertext
+	// must fail to open, in the same way, under the same long-lived key.
+	var s scratch
+	defer s.release()
+	var orig PublicKeySimpleBob
+	copy(orig.Send[:], ciphertext[SealHeaderSize:])
+	want, err := s.keyExchangeSimpleAlice(&orig, priv.key(), pub.Encoding)
+	if err != nil {
+		t.Fatalf("keyExchangeSimpleAlice failed: %v", err)
+	}
+
+	const nrTampered = 64
+	unchanged := 0
+	for i := 0; i < nrTampered; i++ {
+		// Shift coefficient 8i of the compressed v by q/2^d.
+		tampered := append([]byte{}, ciphertext...)
+		tampered[SealHeaderSize+PolyBytes+i*paramD] ^= 0x01
+
+		if _, err = Open(priv, info, nil, tampered); err != ErrOpenFailed {
+			t.Fatalf("Open with tampered coefficient %d: %v", 8*i, err)
+		}
+
+		var bobPk PublicKeySimpleBob
+		copy(bobPk.Send[:], tampered[SealHeaderSize:])
+		got, err := s.keyExchangeSimpleAlice(&bobPk, priv.key(), pub.Encoding)
+		if err != nil {
+			t.Fatalf("keyExchangeSimpleAlice failed: %v", err)
+		}
+		if bytes.Equal(got[:], want[:]) {
+			unchanged++
+		}
+	}
+
+	// Sanity check that the tampering is mostly invisible to the CPA secure
+	// exchange, which is what would have made Open an oracle.
+	if unchanged < nrTampered/2 {
+		t.Fatalf("only %d/%d tampered ciphertexts kept the CPA shared secret", unchanged, nrTampered)
+	}
+
+	// The key is still intact after all of the failures.
+	opened, err := Open(priv, info, nil, ciphertext)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Fatalf("plaintext mismatched")
+	}
+}
@@ -0,0 +1,232 @@
+// main.go - NewHope precomputed table generator.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+// gentables generates precomp.go, the NTT tables, from the ring dimension n,
+// the modulus q and the Montgomery radix 2^r.  It is run by go generate.
+//
+// Usage:
+//
+//	go run ./internal/gentables [-n 1024] [-q 12289] [-r 18] [-o precomp.go]
+//
+// All of the roots of unity are powers of psi, the smallest primitive 2n-th
+// root of unity mod q, and the tables hold them in Montgomery form.
+// The parameters must match paramN, paramQ and rlog, which the package
+// checks at compile time.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"math/bits"
+	"os"
+)
+
+// Default parameters, matching params.go and reduce.go.
+const (
+	defaultN    = 1024
+	defaultQ    = 12289
+	defaultRLog = 18
+)
+
+type params struct {
+	n, q uint64
+	rLog uint
+	logN uint
+	psi  uint64
+}
+
+func newParams(n, q uint64, rLog uint) (*params, error) {
+	if n < 4 || n&(n-1) != 0 {
+		return nil, errors.New("n must be a power of 2, and at least 4")
+	}
+	if !new(big.Int).SetUint64(q).ProbablyPrime(20) {
+		return nil, errors.New("q must be prime")
+	}
+	if q%(2*n) != 1 {
+		return nil, errors.New("q must be 1 mod 2n")
+	}
+	if q >= 1<<16 {
+		return nil, errors.New("q must fit in 16 bits")
+	}
+	if rLog < 16 || rLog > 32 {
+		return nil, errors.New("r must be between 16 and 32")
+	}
+
+	p := &params{
+		n:    n,
+		q:    q,
+		rLog: rLog,
+		logN: uint(bits.TrailingZeros64(n)),
+	}
+
+	// For n a power of 2, psi^n = -1 iff psi is a primitive 2n-th root.
+	for p.psi = 2; p.psi < q; p.psi++ {
+		if p.exp(p.psi, n) == q-1 {
+			break
+		}
+	}
+
+	return p, nil
+}
+
+func (p *params) exp(x, e uint64) uint64 {
+	r := uint64(1)
+	for x %= p.q; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = r * x % p.q
+		}
+		x = x * x % p.q
+	}
+	return r
+}
+
+func (p *params) inv(x uint64) uint64 {
+	return p.exp(x, p.q-2)
+}
+
+func (p *params) montgomery(x uint64) uint16 {
+	return uint16((x << p.rLog) % p.q)
+}
+
+// psiPow returns psi^e, for any (possibly negative) e.
+func (p *params) psiPow(e int64) uint64 {
+	e %= int64(2 * p.n)
+	if e < 0 {
+		e += int64(2 * p.n)
+	}
+	return p.exp(p.psi, uint64(e))
+}
+
+func bitrev(x uint64, nBits uint) uint64 {
+	return bits.Reverse64(x) >> (64 - nBits)
+}
+
+// twiddle returns the Montgomery form of the k-th twiddle factor of the
+// level of the negacyclic transform with butterflies spanning h: a power of
+// the primitive 4h-th root of unity psi^(n/2h), or its inverse.
+func (p *params) twiddle(h, k uint64, inverse bool) uint16 {
+	e := int64(p.n / (2 * h) * (2*k + 1))
+	if inverse {
+		e = -e
+	}
+	return p.montgomery(p.psiPow(e))
+}
+
+// nttTwiddles returns the twiddle factors of poly.ntt(), a Cooley-Tukey
+// transform, level by level.  The butterflies spanning h use the h factors
+// starting at offset h - 1, so that they can be loaded in order.
+func (p *params) nttTwiddles() []uint16 {
+	var w []uint16
+	for h := uint64(1); h < p.n; h *= 2 {
+		for k := uint64(0); k < h; k++ {
+			w = append(w, p.twiddle(h, k, false))
+		}
+	}
+	return w
+}
+
+// invNttTwiddles returns the twiddle factors of poly.invNtt(), a
+// Gentleman-Sande transform, level by level.  The scaling by n^-1 is folded
+// into the last level.
+func (p *params) invNttTwiddles() []uint16 {
+	var w []uint16
+	for h := p.n / 2; h > 1; h /= 2 {
+		for k := uint64(0); k < h; k++ {
+			w = append(w, p.twiddle(h, k, true))
+		}
+	}
+	return append(w, p.montgomery(p.psiPow(-int64(p.n/2))*p.inv(p.n)%p.q))
+}
+
+func (p *params) bitrevTable() []uint16 {
+	t := make([]uint16, p.n)
+	for i := range t {
+		t[i] = uint16(bitrev(uint64(i), p.logN))
+	}
+	return t
+}
+
+const header = `// precomp.go - NewHope precomputed tables.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+// Code generated by internal/gentables. DO NOT EDIT.
+
+package newhope
+`
+
+func writeTable(b *bytes.Buffer, comment, name, size string, table []uint16) {
+	fmt.Fprintf(b, "\n%svar %s = [%s]uint16{\n", comment, name, size)
+
+	// Wrap at 80 columns, with 8 column tabs.
+	line := ""
+	for _, v := range table {
+		s := fmt.Sprintf("%d,", v)
+		if line != "" && 8+len(line)+1+len(s) > 80 {
+			fmt.Fprintf(b, "\t%s\n", line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += s
+	}
+	fmt.Fprintf(b, "\t%s\n}\n", line)
+}
+
+func (p *params) generate() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(header)
+
+	fmt.Fprintf(&b, "\n// The tables are for n = %d, q = %d and the Montgomery radix 2^%d, and hold\n// powers of psi = %d, the smallest primitive 2n-th root of unity mod q, in\n// Montgomery form.\n", p.n, p.q, p.rLog, p.psi)
+	fmt.Fprintf(&b, "\n// precompN is the ring dimension the tables are for, which must be paramN.\nconst precompN = %d\n", p.n)
+	fmt.Fprintf(&b, "\n// precompQ is the modulus the tables are for, which must be paramQ.\nconst precompQ = %d\n", p.q)
+	fmt.Fprintf(&b, "\n// precompRLog is log2 of the Montgomery radix the tables are for, which\n// must be rlog.\nconst precompRLog = %d\n", p.rLog)
+	fmt.Fprintf(&b, "\n// nInvMontgomery is n^-1, in Montgomery form.\nconst nInvMontgomery = %d\n", p.montgomery(p.inv(p.n)))
+
+	writeTable(&b, "// nttTwiddlesMontgomery are the twiddle factors of poly.ntt().\n", "nttTwiddlesMontgomery", "paramN - 1", p.nttTwiddles())
+	writeTable(&b, "// invNttTwiddlesMontgomery are the twiddle factors of poly.invNtt().\n", "invNttTwiddlesMontgomery", "paramN - 1", p.invNttTwiddles())
+
+	writeTable(&b, "// bitrevTable maps each index to its log2(n) bit reversal.\n", "bitrevTable", "paramN", p.bitrevTable())
+
+	return format.Source(b.Bytes())
+}
+
+func main() {
+	n := flag.Uint64("n", defaultN, "ring dimension")
+	q := flag.Uint64("q", defaultQ, "modulus")
+	rLog := flag.Uint("r", defaultRLog, "log2 of the Montgomery radix")
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	p, err := newParams(*n, *q, *rLog)
+	if err != nil {
+		log.Fatalf("gentables: invalid parameters: %v", err)
+	}
+	src, err := p.generate()
+	if err != nil {
+		log.Fatalf("gentables: failed to format output: %v", err)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(*out, src, 0644)
+	}
+	if err != nil {
+		log.Fatalf("gentables: failed to write output: %v", err)
+	}
+}
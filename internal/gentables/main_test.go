@@ -0,0 +1,73 @@
+// main_test.go - NewHope precomputed table generator tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestPrecompUpToDate(t *testing.T) {
+	committed, err := ioutil.ReadFile("../../precomp.go")
+	if err != nil {
+		t.Fatalf("failed to read precomp.go: %v", err)
+	}
+
+	// The tables may be for any parameters.
+	constant := func(name string) uint64 {
+		m := regexp.MustCompile(`(?m)^const ` + name + ` = ([0-9]+)$`).FindSubmatch(committed)
+		if m == nil {
+			t.Fatalf("precomp.go does not define %s", name)
+		}
+		v, err := strconv.ParseUint(string(m[1]), 10, 64)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", name, err)
+		}
+		return v
+	}
+
+	p, err := newParams(constant("precompN"), constant("precompQ"), uint(constant("precompRLog")))
+	if err != nil {
+		t.Fatalf("newParams failed: %v", err)
+	}
+	src, err := p.generate()
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if !bytes.Equal(src, committed) {
+		t.Fatalf("precomp.go does not match the generator output, run go generate")
+	}
+}
+
+func TestNewParams(t *testing.T) {
+	p, err := newParams(defaultN, defaultQ, defaultRLog)
+	if err != nil {
+		t.Fatalf("newParams failed: %v", err)
+	}
+	if p.psi != 7 {
+		t.Fatalf("psi = %d, expected 7", p.psi)
+	}
+
+	for _, v := range []struct {
+		n, q uint64
+		rLog uint
+	}{
+		{1000, defaultQ, defaultRLog},            // n not a power of 2.
+		{defaultN, 12288, defaultRLog},           // q not prime.
+		{defaultN, 7681, defaultRLog},            // q != 1 mod 2n.
+		{defaultN, 65537 + 2048*32, defaultRLog}, // q too large.
+		{defaultN, defaultQ, 8},                  // Radix too small.
+	} {
+		if _, err := newParams(v.n, v.q, v.rLog); err == nil {
+			t.Fatalf("newParams(%d, %d, %d) succeeded", v.n, v.q, v.rLog)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// negacyclic.go - Reference multiplication in Z_q[x]/(x^n + 1).
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+// Package negacyclic implements multiplication in Z_q[x]/(x^n + 1), without
+// any of the tricks used by the NTT, for testing the NTT against.  It is
+// only used by tests, and favors being obviously correct over being fast.
+//
+// Polynomials are slices of n coefficients, lowest degree first, and every
+// input coefficient may be any value (it is reduced mod q).  The outputs
+// are fully reduced.
+package negacyclic
+
+// Schoolbook returns a * b mod (x^n + 1, q), computed term by term.
+func Schoolbook(a, b []uint16, q uint16) []uint16 {
+	n := checkLengths(a, b)
+
+	// x^n = -1, so the terms of degree n and up wrap around negated.
+	c := make([]uint64, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			t := uint64(a[i]) % uint64(q) * (uint64(b[j]) % uint64(q)) % uint64(q)
+			if k := i + j; k < n {
+				c[k] += t
+			} else {
+				c[k-n] += uint64(q) - t
+			}
+		}
+	}
+
+	return reduce(c, q)
+}
+
+// Karatsuba returns a * b mod (x^n + 1, q), computing the full product with
+// Karatsuba's algorithm, and then reducing it mod x^n + 1.
+func Karatsuba(a, b []uint16, q uint16) []uint16 {
+	n := checkLengths(a, b)
+
+	x, y := make([]int64, n), make([]int64, n)
+	for i := range a {
+		x[i], y[i] = int64(a[i]%q), int64(b[i]%q)
+	}
+	prod := karatsuba(x, y, int64(q))
+
+	c := make([]uint64, n)
+	for i := range c {
+		t := prod[i]
+		if i+n < len(prod) {
+			t -= prod[i+n]
+		}
+		c[i] = uint64(mod(t, int64(q)))
+	}
+
+	return reduce(c, q)
+}
+
+// karatsubaThreshold is the size below which karatsuba() falls back to the
+// schoolbook method.
+const karatsubaThreshold = 16
+
+// karatsuba returns the full 2n - 1 coefficient product of x and y, which
+// have the same length n, reduced mod q.
+func karatsuba(x, y []int64, q int64) []int64 {
+	n := len(x)
+	prod := make([]int64, 2*n-1)
+	if n <= karatsubaThreshold {
+		for i := range x {
+			for j := range y {
+				prod[i+j] = mod(prod[i+j]+x[i]*y[j], q)
+			}
+		}
+		return prod
+	}
+
+	// x = x0 + x1 t, y = y0 + y1 t, with t = X^h, and
+	// x y = z0 + ((x0 + x1)(y0 + y1) - z0 - z2) t + z2 t^2.
+	h := n / 2
+	x0, x1, y0, y1 := x[:h], x[h:], y[:h], y[h:]
+	z0 := karatsuba(x0, y0, q)
+	z2 := karatsuba(pad(x1, h), pad(y1, h), q)
+
+	xs, ys := make([]int64, n-h), make([]int64, n-h)
+	for i := range xs {
+		if i < h {
+			xs[i], ys[i] = x0[i], y0[i]
+		}
+		xs[i] = mod(xs[i]+x1[i], q)
+		ys[i] = mod(ys[i]+y1[i], q)
+	}
+	z1 := karatsuba(xs, ys, q)
+
+	for i, v := range z0 {
+		prod[i] += v
+		prod[i+h] -= v
+	}
+	for i, v := range z1 {
+		prod[i+h] += v
+	}
+	for i, v := range z2 {
+		if i+2*h < len(prod) {
+			prod[i+2*h] += v
+		}
+		prod[i+h] -= v
+	}
+	for i := range prod {
+		prod[i] = mod(prod[i], q)
+	}
+
+	return prod
+}
+
+// pad returns x, extended with zeros to at least n coefficients.
+func pad(x []int64, n int) []int64 {
+	if len(x) >= n {
+		return x
+	}
+	return append(append([]int64{}, x...), make([]int64, n-len(x))...)
+}
+
+func mod(x, q int64) int64 {
+	x %= q
+	if x < 0 {
+		x += q
+	}
+	return x
+}
+
+func reduce(c []uint64, q uint16) []uint16 {
+	r := make([]uint16, len(c))
+	for i, v := range c {
+		r[i] = uint16(v % uint64(q))
+	}
+	return r
+}
+
+func checkLengths(a, b []uint16) int {
+	if len(a) != len(b) {
+		panic("negacyclic: mismatched polynomial lengths")
+	}
+	return len(a)
+}
@@ -0,0 +1,62 @@
+// negacyclic_test.go - Reference multiplication tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package negacyclic
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+const testQ = 12289
+
+func TestSmall(t *testing.T) {
+	// (1 + 2x + 3x^2 + 4x^3)(5 + 6x + 7x^2 + 8x^3) mod (x^4 + 1), with the
+	// terms of x^4, x^5 and x^6 (61, 52 and 32) wrapping around negated.
+	a := []uint16{1, 2, 3, 4}
+	b := []uint16{5, 6, 7, 8}
+	expected := []uint16{5 - 61 + testQ, 16 - 52 + testQ, 34 - 32, 60}
+	for _, fn := range []func(a, b []uint16, q uint16) []uint16{Schoolbook, Karatsuba} {
+		if c := fn(a, b, testQ); !reflect.DeepEqual(c, expected) {
+			t.Fatalf("product = %v, expected %v", c, expected)
+		}
+	}
+
+	// x^(n-1) * x = -1, and unreduced inputs are reduced.
+	for _, n := range []int{1, 2, 3, 17, 64, 1024} {
+		a, b := make([]uint16, n), make([]uint16, n)
+		a[n-1] = testQ + 1
+		b[1%n] = 1
+		expected := make([]uint16, n)
+		expected[(n-1+1%n)%n] = testQ - 1
+		if n == 1 {
+			// x^0 * x^0 does not wrap around.
+			expected[0] = 1
+		}
+		for _, fn := range []func(a, b []uint16, q uint16) []uint16{Schoolbook, Karatsuba} {
+			if c := fn(a, b, testQ); !reflect.DeepEqual(c, expected) {
+				t.Fatalf("n = %d: product = %v, expected %v", n, c, expected)
+			}
+		}
+	}
+}
+
+func TestKaratsuba(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, n := range []int{1, 15, 16, 17, 33, 100, 256, 1024} {
+		for _, max := range []int{2, testQ, 1 << 16} {
+			a, b := make([]uint16, n), make([]uint16, n)
+			for i := range a {
+				a[i], b[i] = uint16(rng.Intn(max)), uint16(rng.Intn(max))
+			}
+			if c, expected := Karatsuba(a, b, testQ), Schoolbook(a, b, testQ); !reflect.DeepEqual(c, expected) {
+				t.Fatalf("n = %d: Karatsuba and Schoolbook mismatch", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// kem.go - NewHope-Simple CCA secure key encapsulation.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"context"
+	"crypto/subtle"
+	"io"
+	"runtime"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// The KEM is the Fujisaki-Okamoto transform of the NewHope-Simple
+// encryption, with implicit rejection, as in Kyber:
+//
+//	(K', coins) <- G(m || H(pk))
+//	c <- Enc(pk, m; coins)
+//	K <- KDF(K' || H(c))
+//
+// Decapsulation re-encrypts the decrypted message, and if the result
+// differs from c, returns KDF(z || H(c)) instead, where z is a secret
+// derived from the private key.  G, KDF and the derivation of z are all
+// SHAKE-256, with a distinct prefix.
+var (
+	kemPrefixG      = []byte("newhope-simple-cca G")
+	kemPrefixKDF    = []byte("newhope-simple-cca KDF")
+	kemPrefixReject = []byte("newhope-simple-cca reject")
+)
+
+// EncapsulateSimple is the CCA secure counterpart of KeyExchangeSimpleBob.
+// It returns a ciphertext, in the same format as Bob's NewHope-Simple
+// public key, and the shared secret, using the given reader, which must
+// return random data.
+//
+// The sender and the recipient *MUST* agree on TorSampling.
+func EncapsulateSimple(rand io.Reader, alicePk *PublicKeySimpleAlice) (*PublicKeySimpleBob, []byte, error) {
+	if alicePk == nil {
+		return nil, nil, ErrInvalidPublicKey
+	}
+
+	var m [SharedSecretSize]byte
+	if err := readRandom(context.Background(), rand, m[:]); err != nil {
+		return nil, nil, err
+	}
+	defer memwipe(m[:])
+	m = sha3.Sum256(m[:]) // Don't send output of system RNG.
+
+	var s scratch
+	defer s.release()
+	kBar, coins := kemG(&m, alicePk)
+	defer memwipe(kBar[:])
+	defer memwipe(coins[:])
+	ct, err := s.encryptSimple(context.Background(), alicePk, &m, &coins, TorSampling, alicePk.Encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ct, kemKDF(&kBar, ct), nil
+}
+
+// DecapsulateSimple is the CCA secure counterpart of
+// KeyExchangeSimpleAlice, returning the shared secret encapsulated by
+// EncapsulateSimple.  A ciphertext that is well formed, but was not
+// produced by EncapsulateSimple, yields a pseudorandom shared secret that
+// the sender can not compute, rather than an error.  Only a ciphertext that
+// does not hold a well formed polynomial is rejected, with
+// ErrInvalidCiphertext.
+//
+// Unlike KeyExchangeSimpleAlice, the private key is left intact so that it
+// may be used to decapsulate further ciphertexts, until it is Reset().
+func DecapsulateSimple(aliceSk *PrivateKeySimpleAlice, ct *PublicKeySimpleBob) ([]byte, error) {
+	if aliceSk == nil || aliceSk.key() == nil {
+		return nil, ErrKeyConsumed
+	}
+	if ct == nil {
+		return nil, ErrInvalidCiphertext
+	}
+	sk := aliceSk.key()
+
+	var s scratch
+	defer s.release()
+	var m [SharedSecretSize]byte
+	defer memwipe(m[:])
+	if err := s.decryptSimple(ct, sk, &m, aliceSk.pub.Encoding); err != nil {
+		if err == ErrInvalidPublicKey {
+			err = ErrInvalidCiphertext
+		}
+		return nil, err
+	}
+
+	kBar, coins := kemG(&m, &aliceSk.pub)
+	defer memwipe(kBar[:])
+	defer memwipe(coins[:])
+	ct2, err := s.encryptSimple(context.Background(), &aliceSk.pub, &m, &coins, TorSampling, aliceSk.pub.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace K' with z, iff the ciphertexts differ, in constant time.
+	z := kemReject(sk)
+	runtime.KeepAlive(aliceSk)
+	defer memwipe(z[:])
+	reject := 1 - subtle.ConstantTimeCompare(ct.Send[:], ct2.Send[:])
+	subtle.ConstantTimeCopy(reject, kBar[:], z[:])
+
+	return kemKDF(&kBar, ct), nil
+}
+
+// kemG returns (K', coins) <- G(m || H(pk)).
+func kemG(m *[SharedSecretSize]byte, pk *PublicKeySimpleAlice) (kBar [SharedSecretSize]byte, coins [SeedBytes]byte) {
+	hpk := sha3.Sum256(pk.Send[:])
+
+	h := sha3.NewShake256()
+	_, _ = h.Write(kemPrefixG)
+	_, _ = h.Write(m[:])
+	_, _ = h.Write(hpk[:])
+	_, _ = h.Read(kBar[:])
+	_, _ = h.Read(coins[:])
+	h.Reset()
+
+	return
+}
+
+// kemKDF returns KDF(kBar || H(ct)).
+func kemKDF(kBar *[SharedSecretSize]byte, ct *PublicKeySimpleBob) []byte {
+	hct := sha3.Sum256(ct.Send[:])
+
+	h := sha3.NewShake256()
+	_, _ = h.Write(kemPrefixKDF)
+	_, _ = h.Write(kBar[:])
+	_, _ = h.Write(hct[:])
+	k := make([]byte, SharedSecretSize)
+	_, _ = h.Read(k)
+	h.Reset()
+
+	return k
+}
+
+// kemReject returns z, the implicit rejection secret, derived from the
+// encoded private key.
+func kemReject(sk *poly) (z [SharedSecretSize]byte) {
+	var b [PolyBytes]byte
+	sk.toBytes(b[:])
+	defer memwipe(b[:])
+
+	h := sha3.NewShake256()
+	_, _ = h.Write(kemPrefixReject)
+	_, _ = h.Write(b[:])
+	_, _ = h.Read(z[:])
+	h.Reset()
+
+	return
+}
@@ -0,0 +1,117 @@
+// kem_test.go - NewHope-Simple CCA secure key encapsulation tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKEM(t *testing.T) {
+	for _, torSampling := range []bool{false, true} {
+		if torSampling && !torSamplingSupported {
+			continue
+		}
+		TorSampling = torSampling
+		alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+		}
+
+		for i := 0; i < 16; i++ {
+			ct, bobShared, err := EncapsulateSimple(rand.Reader, alicePub)
+			if err != nil {
+				t.Fatalf("EncapsulateSimple failed: %v", err)
+			}
+			aliceShared, err := DecapsulateSimple(alicePriv, ct)
+			if err != nil {
+				t.Fatalf("DecapsulateSimple failed: %v", err)
+			}
+			if !bytes.Equal(aliceShared, bobShared) {
+				t.Fatalf("shared secrets mismatched")
+			}
+
+			// A modified ciphertext is implicitly rejected, with a secret
+			// that depends on the ciphertext and the private key.
+			bad := *ct
+			bad.Send[PolyBytes+i] ^= 1
+			rejected, err := DecapsulateSimple(alicePriv, &bad)
+			if err != nil {
+				t.Fatalf("DecapsulateSimple with a modified ciphertext failed: %v", err)
+			}
+			z := kemReject(alicePriv.key())
+			if expected := kemKDF(&z, &bad); !bytes.Equal(rejected, expected) {
+				t.Fatalf("modified ciphertext not implicitly rejected")
+			}
+			if bytes.Equal(rejected, bobShared) {
+				t.Fatalf("modified ciphertext yielded the shared secret")
+			}
+		}
+	}
+}
+
+func TestKEMErrors(t *testing.T) {
+	TorSampling = false
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	ct, _, err := EncapsulateSimple(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("EncapsulateSimple failed: %v", err)
+	}
+
+	if _, _, err = EncapsulateSimple(rand.Reader, nil); err != ErrInvalidPublicKey {
+		t.Fatalf("EncapsulateSimple(nil): %v", err)
+	}
+	if _, err = DecapsulateSimple(alicePriv, nil); err != ErrInvalidCiphertext {
+		t.Fatalf("DecapsulateSimple(nil): %v", err)
+	}
+	bad := *ct
+	setFirstCoeff(bad.Send[:], paramQ)
+	if _, err = DecapsulateSimple(alicePriv, &bad); err != ErrInvalidCiphertext {
+		t.Fatalf("DecapsulateSimple with a malformed ciphertext: %v", err)
+	}
+	alicePriv.Reset()
+	if _, err = DecapsulateSimple(alicePriv, ct); err != ErrKeyConsumed {
+		t.Fatalf("DecapsulateSimple with a consumed key: %v", err)
+	}
+}
+
+func TestKEMKAT(t *testing.T) {
+	skipKAT(t)
+	TorSampling = false
+	rand := testReader("newhope KEM KAT")
+	h := sha256.New()
+	for i := 0; i < 16; i++ {
+		alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand)
+		if err != nil {
+			t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+		}
+		ct, shared, err := EncapsulateSimple(rand, alicePub)
+		if err != nil {
+			t.Fatalf("EncapsulateSimple failed: %v", err)
+		}
+		ct.Send[PolyBytes+i] ^= 1
+		rejected, err := DecapsulateSimple(alicePriv, ct)
+		if err != nil {
+			t.Fatalf("DecapsulateSimple failed: %v", err)
+		}
+		_, _ = h.Write(ct.Send[:])
+		_, _ = h.Write(shared)
+		_, _ = h.Write(rejected)
+	}
+
+	const expected = "44b83e5f6e8aa2856f201778346f7e7da9d4bbadc61aa67c9ec4a6316e395443"
+	if digest := hex.EncodeToString(h.Sum(nil)); digest != expected {
+		t.Fatalf("KAT mismatch: %v", digest)
+	}
+}
@@ -0,0 +1,152 @@
+// keyschedule.go - NewHope transcript-bound key schedule.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/sha3"
+)
+
+// KDF is a key derivation function usable by a KeySchedule.
+type KDF int
+
+const (
+	// KDFSHAKE256 derives keys with SHAKE256, and supports outputs of any
+	// length.
+	KDFSHAKE256 KDF = iota
+
+	// KDFHKDFSHA256 derives keys with HKDF-SHA256 (RFC 5869), and supports
+	// outputs of up to 255 * 32 bytes.
+	KDFHKDFSHA256
+)
+
+const maxHKDFSHA256Output = 255 * sha256.Size
+
+var (
+	keyScheduleDomainNewHope = []byte("NewHope-20160815 key schedule")
+	keyScheduleDomainSimple  = []byte("NewHope-Simple key schedule")
+)
+
+// KeySchedule derives any number of keys of any length from a shared
+// secret, bound to both public messages of the exchange that produced it
+// and to a caller supplied context.  Keys for different labels or lengths
+// are independent.
+type KeySchedule struct {
+	kdf   KDF
+	prk   [64]byte
+	reset bool
+}
+
+// NewKeySchedule returns a KeySchedule for the shared secret derived from a
+// NewHope exchange of alicePk and bobPk.  The context, which may be nil,
+// should identify the application protocol.
+func NewKeySchedule(kdf KDF, alicePk *PublicKeyAlice, bobPk *PublicKeyBob, sharedSecret, context []byte) (*KeySchedule, error) {
+	return newKeySchedule(kdf, keyScheduleDomainNewHope, alicePk.Send[:], bobPk.Send[:], sharedSecret, context)
+}
+
+// NewKeyScheduleSimple returns a KeySchedule for the shared secret derived
+// from a NewHope-Simple exchange of alicePk and bobPk.  The context, which
+// may be nil, should identify the application protocol.
+func NewKeyScheduleSimple(kdf KDF, alicePk *PublicKeySimpleAlice, bobPk *PublicKeySimpleBob, sharedSecret, context []byte) (*KeySchedule, error) {
+	return newKeySchedule(kdf, keyScheduleDomainSimple, alicePk.Send[:], bobPk.Send[:], sharedSecret, context)
+}
+
+func newKeySchedule(kdf KDF, domain, aliceMsg, bobMsg, sharedSecret, context []byte) (*KeySchedule, error) {
+	ks := &KeySchedule{kdf: kdf}
+
+	switch kdf {
+	case KDFSHAKE256:
+		// prk <- SHAKE256(transcript || sharedSecret)
+		h := sha3.NewShake256()
+		writeTranscript(h, domain, aliceMsg, bobMsg, context)
+		writeLengthPrefixed(h, sharedSecret)
+		_, _ = h.Read(ks.prk[:])
+		h.Reset()
+	case KDFHKDFSHA256:
+		// prk <- HKDF-Extract(SHA256(transcript), sharedSecret)
+		h := sha256.New()
+		writeTranscript(h, domain, aliceMsg, bobMsg, context)
+		prk := hkdf.Extract(sha256.New, sharedSecret, h.Sum(nil))
+		copy(ks.prk[:], prk)
+		memwipe(prk)
+	default:
+		return nil, ErrInvalidKDF
+	}
+
+	return ks, nil
+}
+
+// Expand returns n bytes of key material for the given label.  It returns
+// ErrKeyScheduleReset once the KeySchedule has been Reset().
+func (ks *KeySchedule) Expand(label string, n int) ([]byte, error) {
+	if ks.reset {
+		return nil, ErrKeyScheduleReset
+	}
+	if n < 0 {
+		return nil, ErrInvalidOutputLength
+	}
+
+	// The output length is part of the input, so that shorter outputs are
+	// not prefixes of longer ones.
+	var info []byte
+	info = appendLengthPrefixed(info, []byte(label))
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(n))
+	info = append(info, l[:]...)
+
+	out := make([]byte, n)
+	switch ks.kdf {
+	case KDFSHAKE256:
+		h := sha3.NewShake256()
+		_, _ = h.Write(ks.prk[:])
+		_, _ = h.Write(info)
+		_, _ = h.Read(out)
+		h.Reset()
+	case KDFHKDFSHA256:
+		if n > maxHKDFSHA256Output {
+			return nil, ErrInvalidOutputLength
+		}
+		if _, err := io.ReadFull(hkdf.Expand(sha256.New, ks.prk[:sha256.Size], info), out); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// Reset clears all sensitive information such that it no longer appears in
+// memory, after which the KeySchedule can no longer be used.
+func (ks *KeySchedule) Reset() {
+	memwipe(ks.prk[:])
+	ks.reset = true
+}
+
+func writeTranscript(w io.Writer, domain, aliceMsg, bobMsg, context []byte) {
+	writeLengthPrefixed(w, domain)
+	writeLengthPrefixed(w, aliceMsg)
+	writeLengthPrefixed(w, bobMsg)
+	writeLengthPrefixed(w, context)
+}
+
+func writeLengthPrefixed(w io.Writer, b []byte) {
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(len(b)))
+	_, _ = w.Write(l[:])
+	_, _ = w.Write(b)
+}
+
+func appendLengthPrefixed(dst, b []byte) []byte {
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(len(b)))
+	dst = append(dst, l[:]...)
+	return append(dst, b...)
+}
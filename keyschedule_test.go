@@ -0,0 +1,206 @@
+// keyschedule_test.go - NewHope key schedule tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"testing"
+)
+
+func TestKeySchedule(t *testing.T) {
+	TorSampling = false
+
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	bobPub, bobShared, err := KeyExchangeSimpleBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+	}
+	aliceShared, err := KeyExchangeSimpleAlice(bobPub, alicePriv)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+	}
+
+	ctx := []byte("newhope key schedule test")
+	for _, kdf := range []KDF{KDFSHAKE256, KDFHKDFSHA256} {
+		aliceKs, err := NewKeyScheduleSimple(kdf, alicePub, bobPub, aliceShared, ctx)
+		if err != nil {
+			t.Fatalf("NewKeyScheduleSimple(%d) failed: %v", kdf, err)
+		}
+		bobKs, err := NewKeyScheduleSimple(kdf, alicePub, bobPub, bobShared, ctx)
+		if err != nil {
+			t.Fatalf("NewKeyScheduleSimple(%d) failed: %v", kdf, err)
+		}
+		otherKs, err := NewKeyScheduleSimple(kdf, alicePub, bobPub, bobShared, []byte("other context"))
+		if err != nil {
+			t.Fatalf("NewKeyScheduleSimple(%d) failed: %v", kdf, err)
+		}
+
+		mustExpand := func(ks *KeySchedule, label string, n int) []byte {
+			b, err := ks.Expand(label, n)
+			if err != nil {
+				t.Fatalf("Expand(%d, %s, %d) failed: %v", kdf, label, n, err)
+			}
+			if len(b) != n {
+				t.Fatalf("Expand(%d, %s, %d) returned %d bytes", kdf, label, n, len(b))
+			}
+			return b
+		}
+
+		for _, n := range []int{0, 16, 32, 100, maxHKDFSHA256Output} {
+			if !bytes.Equal(mustExpand(aliceKs, "c2s", n), mustExpand(bobKs, "c2s", n)) {
+				t.Fatalf("Expand(%d, c2s, %d) mismatched", kdf, n)
+			}
+		}
+
+		c2s := mustExpand(bobKs, "c2s", 32)
+		if bytes.Equal(c2s, mustExpand(bobKs, "s2c", 32)) {
+			t.Fatalf("Expand(%d) ignores the label", kdf)
+		}
+		if bytes.Equal(c2s, mustExpand(bobKs, "c2s", 64)[:32]) {
+			t.Fatalf("Expand(%d) output is a prefix of a longer output", kdf)
+		}
+		if bytes.Equal(c2s, mustExpand(otherKs, "c2s", 32)) {
+			t.Fatalf("Expand(%d) ignores the context", kdf)
+		}
+
+		_, err = bobKs.Expand("c2s", maxHKDFSHA256Output+1)
+		switch kdf {
+		case KDFSHAKE256:
+			if err != nil {
+				t.Fatalf("Expand(%d) with a long output failed: %v", kdf, err)
+			}
+		case KDFHKDFSHA256:
+			if err != ErrInvalidOutputLength {
+				t.Fatalf("Expand(%d) with a too long output: %v", kdf, err)
+			}
+		}
+		if _, err = bobKs.Expand("c2s", -1); err != ErrInvalidOutputLength {
+			t.Fatalf("Expand(%d) with a negative output length: %v", kdf, err)
+		}
+
+		aliceKs.Reset()
+		bobKs.Reset()
+		otherKs.Reset()
+		if _, err = bobKs.Expand("c2s", 32); err != ErrKeyScheduleReset {
+			t.Fatalf("Expand(%d) after Reset: %v", kdf, err)
+		}
+	}
+
+	if _, err = NewKeyScheduleSimple(KDF(-1), alicePub, bobPub, bobShared, nil); err != ErrInvalidKDF {
+		t.Fatalf("NewKeyScheduleSimple with an invalid KDF: %v", err)
+	}
+}
+
+func TestKeyScheduleNewHope(t *testing.T) {
+	TorSampling = false
+
+	alicePriv, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+	}
+	bobPub, bobShared, err := KeyExchangeBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeBob failed: %v", err)
+	}
+	aliceShared, err := KeyExchangeAlice(bobPub, alicePriv)
+	if err != nil {
+		t.Fatalf("KeyExchangeAlice failed: %v", err)
+	}
+
+	ctx := []byte("newhope key schedule test")
+	for _, kdf := range []KDF{KDFSHAKE256, KDFHKDFSHA256} {
+		aliceKs, err := NewKeySchedule(kdf, alicePub, bobPub, aliceShared, ctx)
+		if err != nil {
+			t.Fatalf("NewKeySchedule(%d) failed: %v", kdf, err)
+		}
+		bobKs, err := NewKeySchedule(kdf, alicePub, bobPub, bobShared, ctx)
+		if err != nil {
+			t.Fatalf("NewKeySchedule(%d) failed: %v", kdf, err)
+		}
+		a, err := aliceKs.Expand("c2s", 32)
+		if err != nil {
+			t.Fatalf("Expand(%d) failed: %v", kdf, err)
+		}
+		b, err := bobKs.Expand("c2s", 32)
+		if err != nil {
+			t.Fatalf("Expand(%d) failed: %v", kdf, err)
+		}
+		if !bytes.Equal(a, b) {
+			t.Fatalf("Expand(%d) mismatched", kdf)
+		}
+
+		// The NewHope and NewHope-Simple schedules are domain separated,
+		// even for the same messages and shared secret.
+		var alicePubSimple PublicKeySimpleAlice
+		var bobPubSimple PublicKeySimpleBob
+		copy(alicePubSimple.Send[:], alicePub.Send[:])
+		copy(bobPubSimple.Send[:], bobPub.Send[:])
+		simpleKs, err := NewKeyScheduleSimple(kdf, &alicePubSimple, &bobPubSimple, bobShared, ctx)
+		if err != nil {
+			t.Fatalf("NewKeyScheduleSimple(%d) failed: %v", kdf, err)
+		}
+		if c, _ := simpleKs.Expand("c2s", 32); bytes.Equal(b, c) {
+			t.Fatalf("Expand(%d) is not domain separated", kdf)
+		}
+	}
+}
+
+func TestKeyScheduleKAT(t *testing.T) {
+	// The messages are arbitrary, as the key schedule never parses them,
+	// but their lengths depend on the parameters.
+	skipKAT(t)
+
+	var alicePub PublicKeyAlice
+	var bobPub PublicKeyBob
+	var alicePubSimple PublicKeySimpleAlice
+	var bobPubSimple PublicKeySimpleBob
+	var sharedSecret [SharedSecretSize]byte
+	rand := testReader("newhope key schedule KAT")
+	for _, b := range [][]byte{alicePub.Send[:], bobPub.Send[:], alicePubSimple.Send[:], bobPubSimple.Send[:], sharedSecret[:]} {
+		if _, err := io.ReadFull(rand, b); err != nil {
+			t.Fatalf("ReadFull failed: %v", err)
+		}
+	}
+	ctx := []byte("newhope key schedule KAT")
+
+	for _, v := range []struct {
+		simple bool
+		kdf    KDF
+		want   string
+	}{
+		{false, KDFSHAKE256, "d0b61ffb800079881eeb36ed37fc777389c65abad5683dc8c1dd8210b74cdd172e9a620c4fecc80631023540ce0fea56"},
+		{false, KDFHKDFSHA256, "782d5e9608a92e662c2aa0601fc796034f9a9bfe9db713b44e98b1623cf9fe31653d73c676455df9e41fd008a8afdead"},
+		{true, KDFSHAKE256, "08256e07cb3f8aec92b2981d446b6258e40a8728fe90c204d4af7b82b1ed0809ba285aa623bd3a346e4502a34cc7b925"},
+		{true, KDFHKDFSHA256, "84b7f6dce88c8ae091a183f17ea480d0e1d0605f1cedea3bcf63987b1ab4cdd3d984704aa52bf8a05e7df41015346799"},
+	} {
+		var ks *KeySchedule
+		var err error
+		if v.simple {
+			ks, err = NewKeyScheduleSimple(v.kdf, &alicePubSimple, &bobPubSimple, sharedSecret[:], ctx)
+		} else {
+			ks, err = NewKeySchedule(v.kdf, &alicePub, &bobPub, sharedSecret[:], ctx)
+		}
+		if err != nil {
+			t.Fatalf("simple = %v, KDF %d: failed: %v", v.simple, v.kdf, err)
+		}
+		out, err := ks.Expand("KAT", 48)
+		if err != nil {
+			t.Fatalf("simple = %v, KDF %d: Expand failed: %v", v.simple, v.kdf, err)
+		}
+		if got := hex.EncodeToString(out); got != v.want {
+			t.Errorf("simple = %v, KDF %d: got %s, expected %s", v.simple, v.kdf, got, v.want)
+		}
+		ks.Reset()
+	}
+}
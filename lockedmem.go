@@ -0,0 +1,88 @@
+// lockedmem.go - NewHope secure memory handling.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+)
+
+var secureMemory uint32
+
+// SetSecureMemory enables or disables keeping private keys, and the secret
+// intermediary polynomials of each operation, in memory that is locked into
+// RAM so that it is never swapped out, and that is surrounded by
+// inaccessible guard pages.  It is only supported on Unix systems, and the
+// amount of memory that can be locked at once is typically limited by
+// RLIMIT_MEMLOCK.  It is safe to call concurrently with other operations,
+// and the setting when each key or operation is started applies to it for
+// its whole lifetime.
+func SetSecureMemory(enabled bool) {
+	var v uint32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreUint32(&secureMemory, v)
+}
+
+// SecureMemoryEnabled returns true iff secure memory is enabled.
+func SecureMemoryEnabled() bool {
+	return atomic.LoadUint32(&secureMemory) != 0
+}
+
+var errSecureMemoryUnsupported = errors.New("not supported on this platform")
+
+// lockedPolys is a set of polynomials in locked memory.  The memory is
+// wiped and released by destroy(), or when the lockedPolys is garbage
+// collected.
+type lockedPolys struct {
+	mapping []byte
+	data    []byte
+	p       []poly
+}
+
+// allocLocked returns n polynomials in locked memory if secure memory is
+// enabled, and nil otherwise.
+func allocLocked(n int) (*lockedPolys, error) {
+	if !SecureMemoryEnabled() {
+		return nil, nil
+	}
+
+	l, err := newLockedPolys(n)
+	if err != nil {
+		return nil, wrapError(ErrSecureMemory, err)
+	}
+	runtime.SetFinalizer(l, func(l *lockedPolys) { _ = l.destroy() })
+
+	return l, nil
+}
+
+func (l *lockedPolys) wipe() {
+	for i := range l.p {
+		l.p[i].reset()
+	}
+}
+
+// destroy wipes and releases the memory, and returns the error from
+// unlocking or unmapping it, which is wrapped in ErrSecureMemory.  The
+// memory is wiped regardless, and the callers that can not return an error
+// (Reset(), and the finalizer) ignore it.
+func (l *lockedPolys) destroy() error {
+	if l.p == nil {
+		return nil
+	}
+
+	l.wipe()
+	l.p = nil
+	runtime.SetFinalizer(l, nil)
+	if err := l.free(); err != nil {
+		return wrapError(ErrSecureMemory, err)
+	}
+	return nil
+}
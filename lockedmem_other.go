@@ -0,0 +1,19 @@
+// lockedmem_other.go - NewHope secure memory handling, unsupported platforms.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package newhope
+
+func newLockedPolys(n int) (*lockedPolys, error) {
+	return nil, errSecureMemoryUnsupported
+}
+
+func (l *lockedPolys) free() error {
+	return nil
+}
@@ -0,0 +1,260 @@
+// lockedmem_test.go - NewHope secure memory handling tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+// polyMemory returns the memory backing p.
+func polyMemory(p *poly) []byte {
+	return (*[unsafe.Sizeof(poly{})]byte)(unsafe.Pointer(p))[:]
+}
+
+func isZero(b []byte) bool {
+	return bytes.Count(b, []byte{0}) == len(b)
+}
+
+var faultSink byte
+
+// faults returns true iff reading b faults.
+func faults(b *byte) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		faulted = recover() != nil
+	}()
+	faultSink = *b
+	return false
+}
+
+func enableSecureMemory(t *testing.T) func() {
+	SetSecureMemory(true)
+	l, err := allocLocked(1)
+	if err != nil {
+		SetSecureMemory(false)
+		if errors.Is(err, errSecureMemoryUnsupported) {
+			t.Skip("secure memory is not supported on this platform")
+		}
+		t.Fatalf("allocLocked failed: %v", err)
+	}
+	if err = l.destroy(); err != nil {
+		t.Fatalf("destroy failed: %v", err)
+	}
+	return func() {
+		SetSecureMemory(false)
+	}
+}
+
+func TestLockedPolys(t *testing.T) {
+	defer enableSecureMemory(t)()
+
+	for _, n := range []int{1, 2, scratchPolys} {
+		l, err := allocLocked(n)
+		if err != nil {
+			t.Fatalf("allocLocked(%d) failed: %v", n, err)
+		}
+		for i := range l.p {
+			for j := range l.p[i].coeffs {
+				l.p[i].coeffs[j] = 0xa5a5
+			}
+		}
+
+		// The polynomials end right at the trailing guard page, and both
+		// guard pages are inaccessible.
+		pageSize := os.Getpagesize()
+		guard := &l.mapping[len(l.mapping)-pageSize]
+		end := uintptr(unsafe.Pointer(&l.p[n-1])) + unsafe.Sizeof(poly{})
+		if end != uintptr(unsafe.Pointer(guard)) {
+			t.Fatalf("allocLocked(%d): polynomials do not end at the guard page", n)
+		}
+		if !faults(&l.mapping[0]) || !faults(&l.mapping[pageSize-1]) || !faults(guard) {
+			t.Fatalf("allocLocked(%d): guard pages are accessible", n)
+		}
+		if faults(&polyMemory(&l.p[0])[0]) || faults(&polyMemory(&l.p[n-1])[unsafe.Sizeof(poly{})-1]) {
+			t.Fatalf("allocLocked(%d): polynomials are inaccessible", n)
+		}
+
+		l.wipe()
+		for i := range l.p {
+			if !isZero(polyMemory(&l.p[i])) {
+				t.Fatalf("allocLocked(%d): polynomial %d not wiped", n, i)
+			}
+		}
+		if err = l.destroy(); err != nil {
+			t.Fatalf("allocLocked(%d): destroy failed: %v", n, err)
+		}
+		if l.p != nil || l.mapping != nil || l.data != nil {
+			t.Fatalf("allocLocked(%d): not released by destroy()", n)
+		}
+		if err = l.destroy(); err != nil {
+			t.Fatalf("allocLocked(%d): second destroy failed: %v", n, err)
+		}
+	}
+}
+
+func TestSecureMemory(t *testing.T) {
+	TorSampling = false
+	for _, secure := range []bool{false, true} {
+		if secure {
+			defer enableSecureMemory(t)()
+		}
+
+		alicePriv, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+		}
+		bobPub, bobShared, err := KeyExchangeBob(rand.Reader, alicePub)
+		if err != nil {
+			t.Fatalf("KeyExchangeBob failed: %v", err)
+		}
+		alicePrivSimple, alicePubSimple, err := GenerateKeyPairSimpleAlice(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+		}
+		bobPubSimple, bobSharedSimple, err := KeyExchangeSimpleBob(rand.Reader, alicePubSimple)
+		if err != nil {
+			t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+		}
+		b, err := alicePrivSimple.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary failed: %v", err)
+		}
+		var decoded PrivateKeySimpleAlice
+		if err = decoded.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+
+		for _, k := range []*secretKey{&alicePriv.secretKey, &alicePrivSimple.secretKey, &decoded.secretKey} {
+			if (k.locked != nil) != secure {
+				t.Fatalf("secure = %v, but locked = %v", secure, k.locked != nil)
+			}
+		}
+
+		// The heap copy of the key is only used without secure memory,
+		// and is left cleared otherwise.
+		sk := polyMemory(&alicePriv.sk)
+		if isZero(sk) != secure {
+			t.Fatalf("secure = %v, but the key is in sk = %v", secure, !isZero(sk))
+		}
+		locked := alicePriv.locked
+
+		aliceShared, err := KeyExchangeAlice(bobPub, alicePriv)
+		if err != nil {
+			t.Fatalf("KeyExchangeAlice failed: %v", err)
+		}
+		if !bytes.Equal(aliceShared, bobShared) {
+			t.Fatalf("shared secrets mismatched")
+		}
+		if !isZero(sk) {
+			t.Fatalf("private key not wiped by KeyExchangeAlice")
+		}
+		if locked != nil && locked.p != nil {
+			t.Fatalf("locked private key not released by KeyExchangeAlice")
+		}
+
+		aliceSharedSimple, err := KeyExchangeSimpleAlice(bobPubSimple, &decoded)
+		if err != nil {
+			t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+		}
+		if !bytes.Equal(aliceSharedSimple, bobSharedSimple) {
+			t.Fatalf("NewHope-Simple shared secrets mismatched")
+		}
+
+		sk = polyMemory(&alicePrivSimple.sk)
+		alicePrivSimple.Reset()
+		if !isZero(sk) || alicePrivSimple.locked != nil {
+			t.Fatalf("private key not wiped by Reset")
+		}
+	}
+}
+
+func TestSecureMemoryGC(t *testing.T) {
+	TorSampling = false
+	defer enableSecureMemory(t)()
+
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	b, err := alicePriv.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	alicePriv.Reset()
+	bobPub, bobShared, err := KeyExchangeSimpleBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+	}
+	kemCt, kemShared, err := EncapsulateSimple(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("EncapsulateSimple failed: %v", err)
+	}
+	var msg [MessageSize]byte
+	if _, err = rand.Read(msg[:]); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+	ct, err := Encrypt(rand.Reader, alicePub, &msg)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	// Each call gets a new private key that is unreachable once the call
+	// is made, so that it must keep the key alive itself, as the finalizer
+	// unmaps the locked memory.
+	newKey := func() *PrivateKeySimpleAlice {
+		k := new(PrivateKeySimpleAlice)
+		if err := k.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+		return k
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+	defer wg.Wait()
+	defer close(done)
+
+	n := 5000
+	if testing.Short() {
+		n = 1000
+	}
+	for i := 0; i < n; i++ {
+		if _, err := newKey().MarshalBinary(); err != nil {
+			t.Fatalf("MarshalBinary failed: %v", err)
+		}
+		if got, err := Decrypt(newKey(), ct); err != nil || *got != msg {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if got, err := DecapsulateSimple(newKey(), kemCt); err != nil || !bytes.Equal(got, kemShared) {
+			t.Fatalf("DecapsulateSimple failed: %v", err)
+		}
+		if got, err := KeyExchangeSimpleAlice(bobPub, newKey()); err != nil || !bytes.Equal(got, bobShared) {
+			t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+		}
+	}
+}
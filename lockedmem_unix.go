@@ -0,0 +1,63 @@
+// lockedmem_unix.go - NewHope secure memory handling, Unix backend.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package newhope
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxLockedPolys bounds the size of a lockedPolys, for the conversion from
+// the mapping.
+const maxLockedPolys = 1 << 10
+
+func newLockedPolys(n int) (*lockedPolys, error) {
+	pageSize := unix.Getpagesize()
+	size := n * int(unsafe.Sizeof(poly{}))
+	dataSize := (size + pageSize - 1) / pageSize * pageSize
+
+	// The data pages are surrounded by a guard page on each side, which
+	// are never made accessible.
+	mapping, err := unix.Mmap(-1, 0, dataSize+2*pageSize, unix.PROT_NONE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+	data := mapping[pageSize : pageSize+dataSize]
+	if err = unix.Mprotect(data, unix.PROT_READ|unix.PROT_WRITE); err != nil {
+		_ = unix.Munmap(mapping)
+		return nil, err
+	}
+	if err = unix.Mlock(data); err != nil {
+		_ = unix.Munmap(mapping)
+		return nil, err
+	}
+
+	// The polynomials are placed at the end of the data pages, so that
+	// overflowing them faults on the guard page.
+	p := unsafe.Pointer(&data[dataSize-size])
+	return &lockedPolys{
+		mapping: mapping,
+		data:    data,
+		p:       (*[maxLockedPolys]poly)(p)[:n:n],
+	}, nil
+}
+
+// free unlocks exactly the data pages that were locked, and unmaps the
+// whole mapping, even if unlocking fails, returning the first error.
+func (l *lockedPolys) free() error {
+	err := unix.Munlock(l.data)
+	if uerr := unix.Munmap(l.mapping); err == nil {
+		err = uerr
+	}
+	l.mapping, l.data = nil, nil
+	return err
+}
@@ -0,0 +1,144 @@
+// message_encoding.go - NewHope-Simple message encodings.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+// MessageEncoding is an error correcting code used to encode the 256 bit
+// message carried by NewHope-Simple (the shared secret, or the plaintext of
+// Encrypt) into a polynomial.  The encodings are alternative 256 bit
+// encodings, which differ only in how many errors they correct, and every
+// one of them carries a message of exactly MessageSize bytes.
+type MessageEncoding int
+
+const (
+	// EncodingRepetition encodes each message bit to 4 coefficients, and
+	// decodes each bit by comparing the sum of their distances from q/2 to
+	// q.  It is the encoding of the NewHope-Simple paper.
+	EncodingRepetition MessageEncoding = iota
+
+	// EncodingReedMuller encodes each 5 message bits to a 16 coefficient
+	// codeword of the first order Reed-Muller code RM(1,4), which has
+	// twice the minimum distance of the repetition code, at a slightly
+	// higher rate, and decodes each codeword to the nearest one with a
+	// fast Hadamard transform.  The decryption failure probability is far
+	// lower, which leaves room for a smaller paramD, or more noise.
+	//
+	// The code has room for 320 bits, of which 256 are used, and the
+	// remaining 192 coefficients are left unused.
+	EncodingReedMuller
+)
+
+func (enc MessageEncoding) valid() bool {
+	return enc == EncodingRepetition || enc == EncodingReedMuller
+}
+
+// encodeMsg sets p to the encoding of the SharedSecretSize byte msg.
+func (p *poly) encodeMsg(msg []byte, enc MessageEncoding) {
+	switch enc {
+	case EncodingReedMuller:
+		p.fromMsgReedMuller(msg)
+	default:
+		p.fromMsg(msg)
+	}
+}
+
+// decodeMsg sets the SharedSecretSize byte msg to the message closest to p.
+func (p *poly) decodeMsg(msg []byte, enc MessageEncoding) {
+	switch enc {
+	case EncodingReedMuller:
+		p.toMsgReedMuller(msg)
+	default:
+		p.toMsg(msg)
+	}
+}
+
+const (
+	// rmBits is the number of message bits in an RM(1,4) codeword, and
+	// rmLength is the length of a codeword in coefficients.
+	rmBits   = 5
+	rmLength = 16
+
+	// rmBlocks is the number of codewords used for a message, and
+	// rmStride is the distance between the coefficients of a codeword,
+	// which are spread over the whole polynomial.
+	rmBlocks = (8*SharedSecretSize + rmBits - 1) / rmBits
+	rmStride = paramN / rmLength
+)
+
+// rmBlock returns the 5 bit block i of msg, which is zero padded to a
+// multiple of 5 bits.
+func rmBlock(msg []byte, i int) uint {
+	var b uint
+	for j := 0; j < rmBits; j++ {
+		if bit := i*rmBits + j; bit < 8*SharedSecretSize {
+			b |= uint(msg[bit>>3]>>(uint(bit)&7)&1) << uint(j)
+		}
+	}
+	return b
+}
+
+// fromMsgReedMuller encodes msg to p.  The block b is encoded as the
+// codeword c_x = b_0 ^ <b_1..b_4, x>, for x from 0 to 15, in coefficients
+// i + 64x, with each 1 bit encoded as q/2.
+func (p *poly) fromMsgReedMuller(msg []byte) {
+	p.reset()
+	for i := 0; i < rmBlocks; i++ {
+		b := rmBlock(msg, i)
+		for x := uint(0); x < rmLength; x++ {
+			t := b>>1&x ^ b&1
+			t ^= t >> 2
+			t ^= t >> 1
+			mask := -uint16(t & 1)
+			p.coeffs[i+rmStride*int(x)] = mask & (paramQ / 2)
+		}
+	}
+}
+
+// toMsgReedMuller decodes p to msg in constant time.  Each coefficient
+// gives the soft value s_x = |k_x - q/2| - q/4, which is positive for a 0
+// bit, and the Hadamard transform S(a) = sum_x s_x (-1)^<a, x> gives the
+// correlation with every codeword, as +/-S(a) for b_0 = 0 and 1.  The
+// nearest codeword maximizes |S(a)|.
+func (p *poly) toMsgReedMuller(msg []byte) {
+	var s [rmLength]int32
+
+	memwipe(msg[0:SharedSecretSize])
+
+	for i := 0; i < rmBlocks; i++ {
+		for x := range s {
+			s[x] = int32(flipAbs(p.coeffs[i+rmStride*x])) - paramQ/4
+		}
+		for h := 1; h < rmLength; h <<= 1 {
+			for j := 0; j < rmLength; j += h << 1 {
+				for k := j; k < j+h; k++ {
+					s[k], s[k+h] = s[k]+s[k+h], s[k]-s[k+h]
+				}
+			}
+		}
+
+		// Select the largest |S(a)|, without branching on the values.
+		best, a, neg := abs(s[0]), int32(0), s[0]>>31
+		for j := int32(1); j < rmLength; j++ {
+			v := abs(s[j])
+			mask := (best - v) >> 31
+			best ^= (best ^ v) & mask
+			a ^= (a ^ j) & mask
+			neg ^= (neg ^ s[j]>>31) & mask
+		}
+		b := uint(a<<1 | neg&1)
+
+		for j := 0; j < rmBits; j++ {
+			if bit := i*rmBits + j; bit < 8*SharedSecretSize {
+				msg[bit>>3] |= byte(b>>uint(j)&1) << (uint(bit) & 7)
+			}
+		}
+	}
+
+	for i := range s {
+		s[i] = 0
+	}
+}
@@ -0,0 +1,209 @@
+// message_encoding_test.go - NewHope-Simple message encoding tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"testing"
+)
+
+var messageEncodings = []MessageEncoding{EncodingRepetition, EncodingReedMuller}
+
+func TestMessageEncoding(t *testing.T) {
+	var p poly
+	for _, enc := range messageEncodings {
+		for i := 0; i < 64; i++ {
+			var msg, decoded [SharedSecretSize]byte
+			switch i {
+			case 0:
+			case 1:
+				for j := range msg {
+					msg[j] = 0xff
+				}
+			default:
+				if _, err := rand.Read(msg[:]); err != nil {
+					t.Fatalf("rand.Read failed: %v", err)
+				}
+			}
+
+			p.encodeMsg(msg[:], enc)
+			p.decodeMsg(decoded[:], enc)
+			if decoded != msg {
+				t.Fatalf("encoding %d: decode mismatch", enc)
+			}
+
+			// Flip as many coefficients as the code always corrects: 1 of
+			// the 4 copies of each bit, or 3 of the 16 coefficients of each
+			// codeword.
+			switch enc {
+			case EncodingRepetition:
+				for j := 0; j < 256; j++ {
+					k := j + 256*(i%4)
+					p.coeffs[k] = paramQ/2 - p.coeffs[k]
+				}
+			case EncodingReedMuller:
+				for j := 0; j < rmBlocks; j++ {
+					for x := 0; x < 3; x++ {
+						k := j + rmStride*((i+5*x)%rmLength)
+						p.coeffs[k] = paramQ/2 - p.coeffs[k]
+					}
+				}
+			}
+			p.decodeMsg(decoded[:], enc)
+			if decoded != msg {
+				t.Fatalf("encoding %d: decode with errors mismatch", enc)
+			}
+		}
+	}
+}
+
+func TestMessageEncodingNoise(t *testing.T) {
+	// Compress each encoded message to 2 bits, after adding uniform noise
+	// in [-q/6, q/6].  The repetition code fails to decode some bits, and
+	// the Reed-Muller code must fail far less often.
+	const (
+		d     = 2
+		noise = paramQ / 6
+	)
+
+	var errs [2]int
+	var p poly
+	var c [paramN * d / 8]byte
+	for i, enc := range messageEncodings {
+		rng := testReader("newhope message encoding noise")
+		for j := 0; j < 256; j++ {
+			var msg, decoded [SharedSecretSize]byte
+			var buf [2 * paramN]byte
+			_, _ = io.ReadFull(rng, msg[:])
+			_, _ = io.ReadFull(rng, buf[:])
+
+			p.encodeMsg(msg[:], enc)
+			for k := range p.coeffs {
+				e := int(binary.LittleEndian.Uint16(buf[2*k:]))%(2*noise+1) - noise
+				p.coeffs[k] = uint16((int(p.coeffs[k]) + e + paramQ) % paramQ)
+			}
+			p.compressBits(c[:], d)
+			p.decompressBits(c[:], d)
+			p.decodeMsg(decoded[:], enc)
+			for k := range msg {
+				errs[i] += bits.OnesCount8(msg[k] ^ decoded[k])
+			}
+		}
+	}
+	t.Logf("bit errors: repetition %d, Reed-Muller %d", errs[0], errs[1])
+	if errs[0] == 0 || errs[1] > errs[0]/4 {
+		t.Fatalf("Reed-Muller bit errors %d, repetition %d", errs[1], errs[0])
+	}
+}
+
+func TestMessageEncodingExchange(t *testing.T) {
+	TorSampling = false
+	opts := &KeyOptions{Encoding: EncodingReedMuller}
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAliceOptions(context.Background(), rand.Reader, opts)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAliceOptions failed: %v", err)
+	}
+	if alicePub.Encoding != EncodingReedMuller {
+		t.Fatalf("public key has encoding %d", alicePub.Encoding)
+	}
+
+	var msg [MessageSize]byte
+	if _, err = rand.Read(msg[:]); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+	ct, err := Encrypt(rand.Reader, alicePub, &msg)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	decrypted, err := Decrypt(alicePriv, ct)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if *decrypted != msg {
+		t.Fatalf("Decrypt mismatch")
+	}
+
+	// Encrypting with the other encoding fails to decrypt.
+	otherPub := *alicePub
+	otherPub.Encoding = EncodingRepetition
+	if ct, err = Encrypt(rand.Reader, &otherPub, &msg); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if decrypted, err = Decrypt(alicePriv, ct); err != nil || *decrypted == msg {
+		t.Fatalf("Decrypt with a mismatched encoding: %v", err)
+	}
+
+	// The encoding survives serializing the private key.
+	b, err := alicePriv.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	alicePriv = new(PrivateKeySimpleAlice)
+	if err = alicePriv.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if alicePriv.PublicKey().Encoding != EncodingReedMuller {
+		t.Fatalf("deserialized public key has encoding %d", alicePriv.PublicKey().Encoding)
+	}
+
+	ct, bobShared, err := EncapsulateSimple(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("EncapsulateSimple failed: %v", err)
+	}
+	aliceShared, err := DecapsulateSimple(alicePriv, ct)
+	if err != nil {
+		t.Fatalf("DecapsulateSimple failed: %v", err)
+	}
+	if !bytes.Equal(aliceShared, bobShared) {
+		t.Fatalf("DecapsulateSimple shared secrets mismatched")
+	}
+
+	bobPub, bobShared, err := KeyExchangeSimpleBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+	}
+	aliceShared, err = KeyExchangeSimpleAlice(bobPub, alicePriv)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+	}
+	if !bytes.Equal(aliceShared, bobShared) {
+		t.Fatalf("KeyExchangeSimple shared secrets mismatched")
+	}
+}
+
+func TestMessageEncodingInvalid(t *testing.T) {
+	TorSampling = false
+	invalid := EncodingReedMuller + 1
+	if _, _, err := GenerateKeyPairSimpleAliceOptions(context.Background(), rand.Reader, &KeyOptions{Encoding: invalid}); err != ErrInvalidMessageEncoding {
+		t.Fatalf("GenerateKeyPairSimpleAliceOptions with an invalid encoding: %v", err)
+	}
+
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	alicePub.Encoding = invalid
+	if _, _, err = KeyExchangeSimpleBob(rand.Reader, alicePub); err != ErrInvalidMessageEncoding {
+		t.Fatalf("KeyExchangeSimpleBob with an invalid encoding: %v", err)
+	}
+
+	// A serialized private key with an invalid encoding is rejected.
+	b, err := alicePriv.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	b[len(b)-1] = byte(invalid)
+	if err = new(PrivateKeySimpleAlice).UnmarshalBinary(b); err != ErrInvalidPrivateKey {
+		t.Fatalf("UnmarshalBinary with an invalid encoding: %v", err)
+	}
+}
@@ -16,7 +16,9 @@
 package newhope
 
 import (
+	"context"
 	"io"
+	"runtime"
 
 	"golang.org/x/crypto/sha3"
 )
@@ -29,13 +31,16 @@ const (
 	// implementation is compatible with.
 	UpstreamVersion = "20160815"
 
-	// RecBytes is the length of the reconciliation data in bytes.
+	// RecBytes is the length of the reconciliation data in bytes, with the
+	// default ReconcilerD4.  Other reconcilers may need less, see
+	// Reconciler.RecBytes().
 	RecBytes = 256
 
 	// SendASize is the length of Alice's public key in bytes.
 	SendASize = PolyBytes + SeedBytes
 
-	// SendBSize is the length of Bob's public key in bytes.
+	// SendBSize is the length of Bob's public key in bytes, with the
+	// default ReconcilerD4, and the most that any reconciler needs.
 	SendBSize = PolyBytes + RecBytes
 )
 
@@ -43,7 +48,9 @@ const (
 // where every successful `a` generation will take the same amount of time.
 // Most users will probably not want to enable this as it does come with a
 // performance penalty.  Alice and Bob *MUST* agree on the sampling method,
-// or the key exchange will fail.
+// or the key exchange will fail.  It is not supported with every modulus
+// (see paramQ), in which case every key generation and exchange fails with
+// ErrSamplingMismatch.
 var TorSampling = false
 
 func encodeA(r []byte, pk *poly, seed *[SeedBytes]byte) {
@@ -60,43 +67,94 @@ func decodeA(pk *poly, seed *[SeedBytes]byte, r []byte) {
 	}
 }
 
-func encodeB(r []byte, b *poly, c *poly) {
+func encodeB(r []byte, b *poly, c *poly, rc Reconciler) {
 	b.toBytes(r)
-	for i := 0; i < paramN/4; i++ {
-		r[PolyBytes+i] = byte(c.coeffs[4*i]) | byte(c.coeffs[4*i+1]<<2) | byte(c.coeffs[4*i+2]<<4) | byte(c.coeffs[4*i+3]<<6)
-	}
+	rc.encodeHint(r[PolyBytes:], c)
 }
 
-func decodeB(b *poly, c *poly, r []byte) {
+func decodeB(b *poly, c *poly, r []byte, rc Reconciler) bool {
 	b.fromBytes(r)
-	for i := 0; i < paramN/4; i++ {
-		c.coeffs[4*i+0] = uint16(r[PolyBytes+i]) & 0x03
-		c.coeffs[4*i+1] = uint16(r[PolyBytes+i]>>2) & 0x03
-		c.coeffs[4*i+2] = uint16(r[PolyBytes+i]>>4) & 0x03
-		c.coeffs[4*i+3] = uint16(r[PolyBytes+i] >> 6)
-	}
+	return rc.decodeHint(c, r[PolyBytes:])
 }
 
+// memwipe clears b.  The runtime.KeepAlive() call keeps the compiler from
+// eliding the stores, as b is otherwise dead once they are done.
 func memwipe(b []byte) {
 	for i := range b {
 		b[i] = 0
 	}
+	runtime.KeepAlive(b)
 }
 
 // PublicKeyAlice is Alice's NewHope public key.
 type PublicKeyAlice struct {
 	Send [SendASize]byte
+
+	// Reconciler is the reconciler that Bob must use, taken from the
+	// KeyOptions the key pair was generated with.  It is not part of Send,
+	// so a protocol that uses anything other than the default must convey
+	// it by other means.
+	Reconciler Reconciler
+}
+
+// secretKey is the secret polynomial of a private key, which is either
+// held in sk, or in locked memory with SetSecureMemory.
+type secretKey struct {
+	sk     poly
+	locked *lockedPolys
+
+	// valid is set when the key is generated (or deserialized), and
+	// cleared by reset().
+	valid bool
+}
+
+// alloc returns the polynomial to hold a new key.
+func (k *secretKey) alloc() (*poly, error) {
+	l, err := allocLocked(1)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return &k.sk, nil
+	}
+	k.locked = l
+	return &l.p[0], nil
 }
 
-// PrivateKeyAlice is Alice's NewHope private key.
+// key returns the key, or nil if it is not usable.
+func (k *secretKey) key() *poly {
+	switch {
+	case !k.valid:
+		return nil
+	case k.locked == nil:
+		return &k.sk
+	case k.locked.p == nil:
+		// Destroyed via a copy of the key.
+		return nil
+	}
+	return &k.locked.p[0]
+}
+
+func (k *secretKey) reset() {
+	k.sk.reset()
+	if k.locked != nil {
+		_ = k.locked.destroy()
+		k.locked = nil
+	}
+	k.valid = false
+}
+
+// PrivateKeyAlice is Alice's NewHope private key.  It can be used for a
+// single exchange.
 type PrivateKeyAlice struct {
-	sk poly
+	secretKey
+	rc Reconciler
 }
 
 // Reset clears all sensitive information such that it no longer appears in
-// memory.
+// memory, after which the key can no longer be used.
 func (k *PrivateKeyAlice) Reset() {
-	k.sk.reset()
+	k.reset()
 }
 
 // GenerateKeyPairAlice returns a private/public key pair.  The private key is
@@ -104,86 +162,182 @@ func (k *PrivateKeyAlice) Reset() {
 // receiver side of the key exchange (aka "Bob") MUST use KeyExchangeBob()
 // instead of this routine.
 func GenerateKeyPairAlice(rand io.Reader) (*PrivateKeyAlice, *PublicKeyAlice, error) {
-	var a, e, pk, r poly
+	return GenerateKeyPairAliceContext(context.Background(), rand)
+}
+
+// GenerateKeyPairAliceContext is GenerateKeyPairAlice, except that it
+// returns ctx.Err() if the context is done before the key pair is generated.
+// The context is checked between the sampling stages, while sampling `a`
+// with TorSampling, and between the short reads from the reader, which
+// can not interrupt a Read that blocks.
+func GenerateKeyPairAliceContext(ctx context.Context, rand io.Reader) (*PrivateKeyAlice, *PublicKeyAlice, error) {
+	return GenerateKeyPairAliceOptions(ctx, rand, nil)
+}
+
+// GenerateKeyPairAliceOptions is GenerateKeyPairAliceContext, with the key
+// pair bound to the given options, which may be nil.
+func GenerateKeyPairAliceOptions(ctx context.Context, rand io.Reader, opts *KeyOptions) (*PrivateKeyAlice, *PublicKeyAlice, error) {
+	var s scratch
+	defer s.release()
+
+	return s.generateKeyPairAlice(ctx, rand, TorSampling, opts.reconciler())
+}
+
+func (s *scratch) generateKeyPairAlice(ctx context.Context, rand io.Reader, torSampling bool, rc Reconciler) (*PrivateKeyAlice, *PublicKeyAlice, error) {
+	if !rc.valid() {
+		return nil, nil, ErrInvalidReconciler
+	}
+	p, err := s.polys()
+	if err != nil {
+		return nil, nil, err
+	}
+	a, e, pk, r := &p[0], &p[1], &p[2], &p[3]
 	var seed, noiseSeed [SeedBytes]byte
 
 	// seed <- Sample({0, 1}^256)
-	if _, err := io.ReadFull(rand, seed[:]); err != nil {
+	if err := readRandom(ctx, rand, seed[:]); err != nil {
 		return nil, nil, err
 	}
 	seed = sha3.Sum256(seed[:]) // Don't send output of system RNG.
 	// a <- Parse(SHAKE-128(seed))
-	a.uniform(&seed, TorSampling)
+	if err := a.uniform(ctx, &s.sampler, &seed, torSampling); err != nil {
+		return nil, nil, err
+	}
 
 	// s, e <- Sample(psi(n, 12))
-	if _, err := io.ReadFull(rand, noiseSeed[:]); err != nil {
+	if err := readRandom(ctx, rand, noiseSeed[:]); err != nil {
 		return nil, nil, err
 	}
 	defer memwipe(noiseSeed[:])
-	privKey := new(PrivateKeyAlice)
-	privKey.sk.getNoise(&noiseSeed, 0)
-	privKey.sk.ntt()
-	e.getNoise(&noiseSeed, 1)
+	privKey := &PrivateKeyAlice{rc: rc}
+	sk, err := privKey.alloc()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = sk.getNoise(&s.sampler, &noiseSeed, 0); err != nil {
+		privKey.Reset()
+		return nil, nil, err
+	}
+	sk.ntt()
+	if err = e.getNoise(&s.sampler, &noiseSeed, 1); err != nil {
+		privKey.Reset()
+		return nil, nil, err
+	}
 	e.ntt()
 
 	// b <- as + e
-	pubKey := new(PublicKeyAlice)
-	r.pointwise(&privKey.sk, &a)
-	pk.add(&e, &r)
-	encodeA(pubKey.Send[:], &pk, &seed)
+	pubKey := &PublicKeyAlice{Reconciler: rc}
+	r.pointwise(sk, a)
+	pk.add(e, r)
+	encodeA(pubKey.Send[:], pk, &seed)
+	privKey.valid = true
 
 	return privKey, pubKey, nil
 }
 
-// PublicKeyBob is Bob's NewHope public key.
+// PublicKeyBob is Bob's NewHope public key.  Send is large enough for the
+// reconciliation data of any Reconciler, and only its first
+// PolyBytes + Reconciler.RecBytes() bytes, as returned by Bytes(), need to
+// be sent, as the rest are always zero.
 type PublicKeyBob struct {
 	Send [SendBSize]byte
+
+	// Reconciler is the reconciler that Bob used, taken from Alice's public
+	// key.  Alice ignores it, and uses the reconciler of her key pair.
+	Reconciler Reconciler
+}
+
+// Bytes returns the part of Send that needs to be sent, which is
+// SendBSize bytes with the default ReconcilerD4, but shorter with the one
+// dimensional reconcilers.  The receiver copies it into the start of Send.
+func (k *PublicKeyBob) Bytes() []byte {
+	return k.Send[:PolyBytes+k.Reconciler.RecBytes()]
 }
 
 // KeyExchangeBob is the Responder side of the NewHope key exchange.  The
 // shared secret and "public key" (key + reconciliation data) are generated
-// using the given reader, which must return random data.
+// using the given reader, which must return random data, and the reconciler
+// of Alice's public key.
 func KeyExchangeBob(rand io.Reader, alicePk *PublicKeyAlice) (*PublicKeyBob, []byte, error) {
-	var pka, a, sp, ep, u, v, epp, r poly
+	return KeyExchangeBobContext(context.Background(), rand, alicePk)
+}
+
+// KeyExchangeBobContext is KeyExchangeBob, except that it returns ctx.Err()
+// if the context is done before the exchange completes.  The context is
+// checked as in GenerateKeyPairAliceContext.
+func KeyExchangeBobContext(ctx context.Context, rand io.Reader, alicePk *PublicKeyAlice) (*PublicKeyBob, []byte, error) {
+	var s scratch
+	defer s.release()
+
+	return s.keyExchangeBob(ctx, rand, alicePk, TorSampling)
+}
+
+func (s *scratch) keyExchangeBob(ctx context.Context, rand io.Reader, alicePk *PublicKeyAlice, torSampling bool) (*PublicKeyBob, []byte, error) {
 	var seed, noiseSeed [SeedBytes]byte
 
-	if _, err := io.ReadFull(rand, noiseSeed[:]); err != nil {
+	if alicePk == nil {
+		return nil, nil, ErrInvalidPublicKey
+	}
+	rc := alicePk.Reconciler
+	if !rc.valid() {
+		return nil, nil, ErrInvalidReconciler
+	}
+	p, err := s.polys()
+	if err != nil {
+		return nil, nil, err
+	}
+	pka, a, sp, ep, u, v, epp, r := &p[0], &p[1], &p[2], &p[3], &p[4], &p[5], &p[6], &p[7]
+	decodeA(pka, &seed, alicePk.Send[:])
+	if !pka.isCanonical() {
+		return nil, nil, ErrInvalidPublicKey
+	}
+
+	if err := readRandom(ctx, rand, noiseSeed[:]); err != nil {
 		return nil, nil, err
 	}
 	defer memwipe(noiseSeed[:])
 
 	// a <- Parse(SHAKE-128(seed))
-	decodeA(&pka, &seed, alicePk.Send[:])
-	a.uniform(&seed, TorSampling)
+	if err := a.uniform(ctx, &s.sampler, &seed, torSampling); err != nil {
+		return nil, nil, err
+	}
 
 	// s', e', e'' <- Sample(psi(n, 12))
-	sp.getNoise(&noiseSeed, 0)
+	if err := sp.getNoise(&s.sampler, &noiseSeed, 0); err != nil {
+		return nil, nil, err
+	}
 	sp.ntt()
-	ep.getNoise(&noiseSeed, 1)
+	if err := ep.getNoise(&s.sampler, &noiseSeed, 1); err != nil {
+		return nil, nil, err
+	}
 	ep.ntt()
-	epp.getNoise(&noiseSeed, 2)
+	if err := epp.getNoise(&s.sampler, &noiseSeed, 2); err != nil {
+		return nil, nil, err
+	}
 
 	// u <- as' + e'
-	u.pointwise(&a, &sp)
-	u.add(&u, &ep)
+	u.pointwise(a, sp)
+	u.add(u, ep)
 
 	// v <- bs' + e''
-	v.pointwise(&pka, &sp)
+	v.pointwise(pka, sp)
 	v.invNtt()
-	v.add(&v, &epp)
+	v.add(v, epp)
 
 	// r <- Sample(HelpRec(v))
-	r.helpRec(&v, &noiseSeed, 3)
+	if err := rc.helpRec(&s.sampler, r, v, &noiseSeed, 3); err != nil {
+		return nil, nil, err
+	}
 
-	pubKey := new(PublicKeyBob)
-	encodeB(pubKey.Send[:], &u, &r)
+	pubKey := &PublicKeyBob{Reconciler: rc}
+	encodeB(pubKey.Send[:], u, r, rc)
 
 	// nu <- Rec(v, r)
-	var nu [SharedSecretSize]byte
-	rec(&nu, &v, &r)
+	var nu [maxKeyBytes]byte
+	rc.rec(nu[:], v, r)
 
 	// mu <- SHA3-256(nu)
-	mu := sha3.Sum256(nu[:])
+	mu := sha3.Sum256(nu[:rc.keyBytes()])
 
 	// Scrub the sensitive stuff...
 	memwipe(nu[:])
@@ -194,27 +348,57 @@ func KeyExchangeBob(rand io.Reader, alicePk *PublicKeyAlice) (*PublicKeyBob, []b
 }
 
 // KeyExchangeAlice is the Initiaitor side of the NewHope key exchange.  The
-// provided private key is obliterated prior to returning.
+// provided private key is obliterated prior to returning, and
+// ErrKeyConsumed is returned if it is used again.
 func KeyExchangeAlice(bobPk *PublicKeyBob, aliceSk *PrivateKeyAlice) ([]byte, error) {
-	var u, r, vp poly
+	if aliceSk == nil || aliceSk.key() == nil {
+		return nil, ErrKeyConsumed
+	}
+	defer aliceSk.Reset()
+
+	var s scratch
+	defer s.release()
+	mu, err := s.keyExchangeAlice(bobPk, aliceSk.key(), aliceSk.rc)
+	runtime.KeepAlive(aliceSk)
+	if err != nil {
+		return nil, err
+	}
+
+	return mu[:], nil
+}
 
-	decodeB(&u, &r, bobPk.Send[:])
+func (s *scratch) keyExchangeAlice(bobPk *PublicKeyBob, sk *poly, rc Reconciler) ([SharedSecretSize]byte, error) {
+	var mu [SharedSecretSize]byte
+
+	if bobPk == nil {
+		return mu, ErrInvalidPublicKey
+	}
+	if !rc.valid() {
+		return mu, ErrInvalidReconciler
+	}
+	p, err := s.polys()
+	if err != nil {
+		return mu, err
+	}
+	u, r, vp := &p[0], &p[1], &p[2]
+
+	if !decodeB(u, r, bobPk.Send[:], rc) || !u.isCanonical() {
+		return mu, ErrInvalidPublicKey
+	}
 
 	// v' <- us
-	vp.pointwise(&aliceSk.sk, &u)
+	vp.pointwise(sk, u)
 	vp.invNtt()
 
 	// nu <- Rec(v', r)
-	var nu [SharedSecretSize]byte
-	rec(&nu, &vp, &r)
+	var nu [maxKeyBytes]byte
+	rc.rec(nu[:], vp, r)
 
 	// mu <- Sha3-256(nu)
-	mu := sha3.Sum256(nu[:])
+	mu = sha3.Sum256(nu[:rc.keyBytes()])
 
 	// Scrub the sensitive stuff...
 	memwipe(nu[:])
-	vp.reset()
-	aliceSk.Reset()
 
-	return mu[:], nil
+	return mu, nil
 }
@@ -8,14 +8,16 @@
 package newhope
 
 import (
+	"context"
 	"io"
+	"runtime"
 
 	"golang.org/x/crypto/sha3"
 )
 
 const (
 	// HighBytes is the length of the encoded secret in bytes.
-	HighBytes = 384
+	HighBytes = compressedBytes
 
 	// SendASimpleSize is the length of Alice's NewHope-Simple public key in
 	// bytes.
@@ -24,6 +26,10 @@ const (
 	// SendBSimpleSize is the length of Bob's NewHope-Simple public key in
 	// bytes.
 	SendBSimpleSize = PolyBytes + HighBytes
+
+	// PrivateKeySimpleAliceSize is the length of Alice's serialized
+	// NewHope-Simple private key in bytes.
+	PrivateKeySimpleAliceSize = PolyBytes + SendASimpleSize + 1
 )
 
 func encodeBSimple(r []byte, b *poly, v *poly) {
@@ -39,17 +45,80 @@ func decodeBSimple(b *poly, v *poly, r []byte) {
 // PublicKeySimpleAlice is Alice's NewHope-Simple public key.
 type PublicKeySimpleAlice struct {
 	Send [SendASimpleSize]byte
+
+	// Encoding is the message encoding that Bob must use, taken from the
+	// KeyOptions the key pair was generated with.  It is not part of Send,
+	// so a protocol that uses anything other than the default must convey
+	// it by other means.
+	Encoding MessageEncoding
 }
 
 // PrivateKeySimpleAlice is Alice's NewHope-Simple private key.
 type PrivateKeySimpleAlice struct {
-	sk poly
+	secretKey
+	pub PublicKeySimpleAlice
+}
+
+// PublicKey returns the public key corresponding to the private key.
+func (k *PrivateKeySimpleAlice) PublicKey() *PublicKeySimpleAlice {
+	pub := k.pub
+	return &pub
+}
+
+// MarshalBinary serializes the private key, along with the corresponding
+// public key and its message encoding.  The returned buffer contains
+// sensitive information, and should be scrubbed by the caller once it is no
+// longer needed.
+func (k *PrivateKeySimpleAlice) MarshalBinary() ([]byte, error) {
+	sk := k.key()
+	if sk == nil {
+		return nil, ErrKeyConsumed
+	}
+
+	b := make([]byte, PrivateKeySimpleAliceSize)
+	sk.toBytes(b)
+	runtime.KeepAlive(k)
+	copy(b[PolyBytes:], k.pub.Send[:])
+	b[PolyBytes+SendASimpleSize] = byte(k.pub.Encoding)
+
+	return b, nil
+}
+
+// UnmarshalBinary deserializes a private key produced by MarshalBinary.
+func (k *PrivateKeySimpleAlice) UnmarshalBinary(data []byte) error {
+	if len(data) != PrivateKeySimpleAliceSize {
+		return ErrInvalidPrivateKey
+	}
+	enc := MessageEncoding(data[PolyBytes+SendASimpleSize])
+	if !enc.valid() {
+		return ErrInvalidPrivateKey
+	}
+
+	var key secretKey
+	sk, err := key.alloc()
+	if err != nil {
+		return err
+	}
+	sk.fromBytes(data)
+	if !sk.isCanonical() {
+		key.reset()
+		return ErrInvalidPrivateKey
+	}
+	key.valid = true
+
+	k.Reset()
+	k.secretKey = key
+	copy(k.pub.Send[:], data[PolyBytes:])
+	k.pub.Encoding = enc
+	key.sk.reset()
+
+	return nil
 }
 
 // Reset clears all sensitive information such that it no longer appears in
-// memory.
+// memory, after which the key can no longer be used.
 func (k *PrivateKeySimpleAlice) Reset() {
-	k.sk.reset()
+	k.reset()
 }
 
 // GenerateKeyPairSimpleAlice returns a NewHope-Simple private/public key pair.
@@ -57,30 +126,78 @@ func (k *PrivateKeySimpleAlice) Reset() {
 // random data.  The receiver side of the key exchange (aka "Bob") MUST use
 // KeyExchangeSimpleBob() instead of this routine.
 func GenerateKeyPairSimpleAlice(rand io.Reader) (*PrivateKeySimpleAlice, *PublicKeySimpleAlice, error) {
-	var a, e, pk, r poly
+	return GenerateKeyPairSimpleAliceContext(context.Background(), rand)
+}
+
+// GenerateKeyPairSimpleAliceContext is GenerateKeyPairSimpleAlice, except
+// that it returns ctx.Err() if the context is done before the key pair is
+// generated.  The context is checked as in GenerateKeyPairAliceContext.
+func GenerateKeyPairSimpleAliceContext(ctx context.Context, rand io.Reader) (*PrivateKeySimpleAlice, *PublicKeySimpleAlice, error) {
+	return GenerateKeyPairSimpleAliceOptions(ctx, rand, nil)
+}
+
+// GenerateKeyPairSimpleAliceOptions is GenerateKeyPairSimpleAliceContext,
+// with the key pair bound to the given options, which may be nil.
+func GenerateKeyPairSimpleAliceOptions(ctx context.Context, rand io.Reader, opts *KeyOptions) (*PrivateKeySimpleAlice, *PublicKeySimpleAlice, error) {
+	var s scratch
+	defer s.release()
+
+	return s.generateKeyPairSimpleAlice(ctx, rand, TorSampling, opts.encoding())
+}
+
+func (s *scratch) generateKeyPairSimpleAlice(ctx context.Context, rand io.Reader, torSampling bool, enc MessageEncoding) (*PrivateKeySimpleAlice, *PublicKeySimpleAlice, error) {
+	if !enc.valid() {
+		return nil, nil, ErrInvalidMessageEncoding
+	}
+	p, err := s.polys()
+	if err != nil {
+		return nil, nil, err
+	}
+	a, e, pk, r := &p[0], &p[1], &p[2], &p[3]
 	var seed, noiseSeed [SeedBytes]byte
 
-	if _, err := io.ReadFull(rand, seed[:]); err != nil {
+	if err := readRandom(ctx, rand, seed[:]); err != nil {
 		return nil, nil, err
 	}
 	seed = sha3.Sum256(seed[:]) // Don't send output of system RNG.
-	a.uniform(&seed, TorSampling)
+	if err := a.uniform(ctx, &s.sampler, &seed, torSampling); err != nil {
+		return nil, nil, err
+	}
 
-	if _, err := io.ReadFull(rand, noiseSeed[:]); err != nil {
+	if err := readRandom(ctx, rand, noiseSeed[:]); err != nil {
 		return nil, nil, err
 	}
 	defer memwipe(noiseSeed[:])
 
 	privKey := new(PrivateKeySimpleAlice)
-	privKey.sk.getNoise(&noiseSeed, 0)
-	privKey.sk.ntt()
-	e.getNoise(&noiseSeed, 1)
+	sk, err := privKey.alloc()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = sk.getNoise(&s.sampler, &noiseSeed, 0); err != nil {
+		privKey.Reset()
+		return nil, nil, err
+	}
+	sk.ntt()
+	if err = e.getNoise(&s.sampler, &noiseSeed, 1); err != nil {
+		privKey.Reset()
+		return nil, nil, err
+	}
 	e.ntt()
 
-	pubKey := new(PublicKeySimpleAlice)
-	r.pointwise(&privKey.sk, &a)
-	pk.add(&e, &r)
-	encodeA(pubKey.Send[:], &pk, &seed)
+	pubKey := &PublicKeySimpleAlice{Encoding: enc}
+	r.pointwise(sk, a)
+	pk.add(e, r)
+	encodeA(pubKey.Send[:], pk, &seed)
+	privKey.pub = *pubKey
+	privKey.valid = true
+
+	if PairwiseConsistency {
+		if err = s.pairwiseTest(privKey, pubKey, torSampling); err != nil {
+			privKey.Reset()
+			return nil, nil, err
+		}
+	}
 
 	return privKey, pubKey, nil
 }
@@ -92,75 +209,169 @@ type PublicKeySimpleBob struct {
 
 // KeyExchangeSimpleBob is the Responder side of the NewHope-Simple key
 // exchange.  The shared secret and "public key" are generated using the
-// given reader, which must return random data.
+// given reader, which must return random data, and the message encoding of
+// Alice's public key.
 func KeyExchangeSimpleBob(rand io.Reader, alicePk *PublicKeySimpleAlice) (*PublicKeySimpleBob, []byte, error) {
-	var pka, a, sp, ep, bp, v, epp, m poly
-	var seed, noiseSeed [SeedBytes]byte
+	return KeyExchangeSimpleBobContext(context.Background(), rand, alicePk)
+}
+
+// KeyExchangeSimpleBobContext is KeyExchangeSimpleBob, except that it
+// returns ctx.Err() if the context is done before the exchange completes.
+// The context is checked as in GenerateKeyPairAliceContext.
+func KeyExchangeSimpleBobContext(ctx context.Context, rand io.Reader, alicePk *PublicKeySimpleAlice) (*PublicKeySimpleBob, []byte, error) {
+	var s scratch
+	defer s.release()
 
-	if _, err := io.ReadFull(rand, noiseSeed[:]); err != nil {
+	if alicePk == nil {
+		return nil, nil, ErrInvalidPublicKey
+	}
+
+	return s.keyExchangeSimpleBob(ctx, rand, alicePk, TorSampling, alicePk.Encoding)
+}
+
+func (s *scratch) keyExchangeSimpleBob(ctx context.Context, rand io.Reader, alicePk *PublicKeySimpleAlice, torSampling bool, enc MessageEncoding) (*PublicKeySimpleBob, []byte, error) {
+	var noiseSeed [SeedBytes]byte
+
+	if alicePk == nil {
+		return nil, nil, ErrInvalidPublicKey
+	}
+	if err := readRandom(ctx, rand, noiseSeed[:]); err != nil {
 		return nil, nil, err
 	}
 	defer memwipe(noiseSeed[:])
 
 	var sharedKey [SharedSecretSize]byte
-	if _, err := io.ReadFull(rand, sharedKey[:]); err != nil {
+	if err := readRandom(ctx, rand, sharedKey[:]); err != nil {
 		return nil, nil, err
 	}
 	defer memwipe(sharedKey[:])
 	sharedKey = sha3.Sum256(sharedKey[:])
-	m.fromMsg(sharedKey[:])
 
-	decodeA(&pka, &seed, alicePk.Send[:])
-	a.uniform(&seed, TorSampling)
+	pubKey, err := s.encryptSimple(ctx, alicePk, &sharedKey, &noiseSeed, torSampling, enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	mu := sha3.Sum256(sharedKey[:])
+
+	return pubKey, mu[:], nil
+}
+
+// encryptSimple encrypts msg to alicePk, which is the core of
+// KeyExchangeSimpleBob, with the noise derived from noiseSeed.
+func (s *scratch) encryptSimple(ctx context.Context, alicePk *PublicKeySimpleAlice, msg *[SharedSecretSize]byte, noiseSeed *[SeedBytes]byte, torSampling bool, enc MessageEncoding) (*PublicKeySimpleBob, error) {
+	var seed [SeedBytes]byte
+
+	if !enc.valid() {
+		return nil, ErrInvalidMessageEncoding
+	}
+	p, err := s.polys()
+	if err != nil {
+		return nil, err
+	}
+	pka, a, sp, ep, bp, v, epp, m := &p[0], &p[1], &p[2], &p[3], &p[4], &p[5], &p[6], &p[7]
+	decodeA(pka, &seed, alicePk.Send[:])
+	if !pka.isCanonical() {
+		return nil, ErrInvalidPublicKey
+	}
+	m.encodeMsg(msg[:], enc)
 
-	sp.getNoise(&noiseSeed, 0)
+	if err := a.uniform(ctx, &s.sampler, &seed, torSampling); err != nil {
+		return nil, err
+	}
+
+	if err := sp.getNoise(&s.sampler, noiseSeed, 0); err != nil {
+		return nil, err
+	}
 	sp.ntt()
-	ep.getNoise(&noiseSeed, 1)
+	if err := ep.getNoise(&s.sampler, noiseSeed, 1); err != nil {
+		return nil, err
+	}
 	ep.ntt()
 
-	bp.pointwise(&a, &sp)
-	bp.add(&bp, &ep)
+	bp.pointwise(a, sp)
+	bp.add(bp, ep)
 
-	v.pointwise(&pka, &sp)
+	v.pointwise(pka, sp)
 	v.invNtt()
 
-	epp.getNoise(&noiseSeed, 2)
-	v.add(&v, &epp)
-	v.add(&v, &m) // add key
+	if err := epp.getNoise(&s.sampler, noiseSeed, 2); err != nil {
+		return nil, err
+	}
+	v.add(v, epp)
+	v.add(v, m) // add key
 
 	pubKey := new(PublicKeySimpleBob)
-	encodeBSimple(pubKey.Send[:], &bp, &v)
-	mu := sha3.Sum256(sharedKey[:])
+	encodeBSimple(pubKey.Send[:], bp, v)
 
 	// Scrub the sensitive stuff...
 	sp.reset()
 	v.reset()
 	m.reset()
 
-	return pubKey, mu[:], nil
+	return pubKey, nil
 }
 
 // KeyExchangeSimpleAlice is the Initiaitor side of the NewHope-Simple key
-// exchange.  The provided private key is obliterated prior to returning.
+// exchange.  The provided private key is obliterated prior to returning, and
+// ErrKeyConsumed is returned if it is used again.
 func KeyExchangeSimpleAlice(bobPk *PublicKeySimpleBob, aliceSk *PrivateKeySimpleAlice) ([]byte, error) {
-	var v, bp, k poly
+	if aliceSk == nil || aliceSk.key() == nil {
+		return nil, ErrKeyConsumed
+	}
+	var s scratch
+	defer s.release()
+	mu, err := s.keyExchangeSimpleAlice(bobPk, aliceSk.key(), aliceSk.pub.Encoding)
+	runtime.KeepAlive(aliceSk)
+	aliceSk.Reset()
+	if err != nil {
+		return nil, err
+	}
 
-	decodeBSimple(&bp, &v, bobPk.Send[:])
-	k.pointwise(&aliceSk.sk, &bp)
-	k.invNtt()
+	return mu[:], nil
+}
 
-	k.sub(&k, &v)
+// keyExchangeSimpleAlice derives the NewHope-Simple shared secret without
+// obliterating the private key, for callers that need to reuse it.
+func (s *scratch) keyExchangeSimpleAlice(bobPk *PublicKeySimpleBob, sk *poly, enc MessageEncoding) ([SharedSecretSize]byte, error) {
+	var mu, sharedKey [SharedSecretSize]byte
 
-	var sharedKey [SharedSecretSize]byte
-	k.toMsg(sharedKey[:])
+	if err := s.decryptSimple(bobPk, sk, &sharedKey, enc); err != nil {
+		return mu, err
+	}
 
 	// mu <- Sha3-256(v')
-	mu := sha3.Sum256(sharedKey[:])
+	mu = sha3.Sum256(sharedKey[:])
 
 	// Scrub the sensitive stuff...
 	memwipe(sharedKey[:])
-	k.reset()
-	aliceSk.Reset()
 
-	return mu[:], nil
+	return mu, nil
+}
+
+// decryptSimple sets msg to the message encrypted by encryptSimple, which
+// is the core of KeyExchangeSimpleAlice.
+func (s *scratch) decryptSimple(bobPk *PublicKeySimpleBob, sk *poly, msg *[SharedSecretSize]byte, enc MessageEncoding) error {
+	if bobPk == nil {
+		return ErrInvalidPublicKey
+	}
+	if !enc.valid() {
+		return ErrInvalidMessageEncoding
+	}
+	p, err := s.polys()
+	if err != nil {
+		return err
+	}
+	v, bp, k := &p[0], &p[1], &p[2]
+
+	decodeBSimple(bp, v, bobPk.Send[:])
+	if !bp.isCanonical() {
+		return ErrInvalidPublicKey
+	}
+	k.pointwise(sk, bp)
+	k.invNtt()
+
+	k.sub(k, v)
+	k.decodeMsg(msg[:], enc)
+
+	return nil
 }
@@ -10,6 +10,7 @@ package newhope
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"testing"
 )
 
@@ -45,6 +46,89 @@ func TestSimpleIntegration(t *testing.T) {
 }
 
 func TestSimpleIntegrationTor(t *testing.T) {
+	skipTorSampling(t)
 	TorSampling = true
 	testSimpleIntegration(t)
 }
+
+func TestSimplePrivateKeyMarshal(t *testing.T) {
+	TorSampling = false
+
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	b, err := alicePriv.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	if len(b) != PrivateKeySimpleAliceSize {
+		t.Fatalf("serialized private key length %d, expected %d", len(b), PrivateKeySimpleAliceSize)
+	}
+
+	var decoded PrivateKeySimpleAlice
+	if err = decoded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if !bytes.Equal(decoded.PublicKey().Send[:], alicePub.Send[:]) {
+		t.Fatalf("public key mismatched")
+	}
+
+	bobPub, bobShared, err := KeyExchangeSimpleBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+	}
+	aliceShared, err := KeyExchangeSimpleAlice(bobPub, &decoded)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+	}
+	if !bytes.Equal(aliceShared, bobShared) {
+		t.Fatalf("shared secrets mismatched")
+	}
+
+	if err = decoded.UnmarshalBinary(b[1:]); err != ErrInvalidPrivateKey {
+		t.Fatalf("UnmarshalBinary with truncated key: %v", err)
+	}
+	for i := range b[:PolyBytes] {
+		b[i] = 0xff
+	}
+	if err = decoded.UnmarshalBinary(b); err != ErrInvalidPrivateKey {
+		t.Fatalf("UnmarshalBinary with unreduced key: %v", err)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	var buf [2 * paramN]byte
+	var a, b poly
+	var c, c2 [paramN * 6 / 8]byte
+
+	for d := uint(3); d <= 6; d++ {
+		n := paramN * d / 8
+		for i := 0; i < 64; i++ {
+			// compress() takes any 16 bit value, and the rounding error is
+			// at most q/2^(d+1).
+			if _, err := rand.Read(buf[:]); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+			for j := range a.coeffs {
+				a.coeffs[j] = binary.LittleEndian.Uint16(buf[2*j:])
+			}
+			a.compressBits(c[:n], d)
+			b.decompressBits(c[:n], d)
+			for j := range a.coeffs {
+				diff := (int(b.coeffs[j])-int(a.coeffs[j])%paramQ+paramQ+paramQ/2)%paramQ - paramQ/2
+				if diff < -(paramQ>>(d+1))-1 || diff > paramQ>>(d+1)+1 {
+					t.Fatalf("d = %d: a[%d] = %d decompressed to %d", d, j, a.coeffs[j], b.coeffs[j])
+				}
+			}
+
+			// Every encoding survives decompressing and compressing.
+			b.decompressBits(buf[:n], d)
+			b.compressBits(c2[:n], d)
+			if !bytes.Equal(buf[:n], c2[:n]) {
+				t.Fatalf("d = %d: %x recompressed to %x", d, buf[:n], c2[:n])
+			}
+		}
+	}
+}
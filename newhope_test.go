@@ -10,7 +10,12 @@ package newhope
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"testing"
+
+	"golang.org/x/crypto/sha3"
 )
 
 func testIntegration(t *testing.T) {
@@ -46,6 +51,93 @@ func TestIntegration(t *testing.T) {
 }
 
 func TestIntegrationTor(t *testing.T) {
+	skipTorSampling(t)
 	TorSampling = true
 	testIntegration(t)
 }
+
+// skipTorSampling skips t if TorSampling is not supported with paramQ.
+func skipTorSampling(t testing.TB) {
+	if !torSamplingSupported {
+		t.Skip("TorSampling is not supported with this modulus")
+	}
+}
+
+// skipKAT skips t if the parameters are not the defaults, which the known
+// answers are for.
+func skipKAT(t *testing.T) {
+	if paramQ != 12289 || paramK != 16 || paramD != 3 {
+		t.Skip("the known answers are for the default parameters")
+	}
+}
+
+// setFirstCoeff sets the first coefficient of the encoded polynomial b to v.
+func setFirstCoeff(b []byte, v uint16) {
+	const mask = 1<<(paramQBits-8) - 1
+	b[0], b[1] = byte(v), b[1]&^mask|byte(v>>8)
+}
+
+// testReader returns a deterministic entropy source, for known answer tests.
+func testReader(seed string) io.Reader {
+	h := sha3.NewShake256()
+	_, _ = h.Write([]byte(seed))
+	return h
+}
+
+// Digests of the messages and shared secrets of testKATExchanges, which pin
+// down the exact output of every step of both protocols.
+var katDigests = map[bool]string{
+	false: "bdeffc97de290522dce078b24cf388abc7d6bfe92c570a61ea9637b4c8c4203e",
+	true:  "181e55edba09f8f01759736ed8611f2ed211dbab7b5194756911bec0e6b605ce",
+}
+
+func testKATExchanges(t *testing.T, rand io.Reader) []byte {
+	h := sha256.New()
+	for i := 0; i < 16; i++ {
+		alicePriv, alicePub, err := GenerateKeyPairAlice(rand)
+		if err != nil {
+			t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+		}
+		bobPub, bobShared, err := KeyExchangeBob(rand, alicePub)
+		if err != nil {
+			t.Fatalf("KeyExchangeBob failed: %v", err)
+		}
+		aliceShared, err := KeyExchangeAlice(bobPub, alicePriv)
+		if err != nil {
+			t.Fatalf("KeyExchangeAlice failed: %v", err)
+		}
+		_, _ = h.Write(alicePub.Send[:])
+		_, _ = h.Write(bobPub.Send[:])
+		_, _ = h.Write(bobShared)
+		_, _ = h.Write(aliceShared)
+
+		aliceSimplePriv, aliceSimplePub, err := GenerateKeyPairSimpleAlice(rand)
+		if err != nil {
+			t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+		}
+		bobSimplePub, bobSimpleShared, err := KeyExchangeSimpleBob(rand, aliceSimplePub)
+		if err != nil {
+			t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+		}
+		aliceSimpleShared, err := KeyExchangeSimpleAlice(bobSimplePub, aliceSimplePriv)
+		if err != nil {
+			t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+		}
+		_, _ = h.Write(aliceSimplePub.Send[:])
+		_, _ = h.Write(bobSimplePub.Send[:])
+		_, _ = h.Write(bobSimpleShared)
+		_, _ = h.Write(aliceSimpleShared)
+	}
+	return h.Sum(nil)
+}
+
+func TestKAT(t *testing.T) {
+	skipKAT(t)
+	for _, torSampling := range []bool{false, true} {
+		TorSampling = torSampling
+		digest := hex.EncodeToString(testKATExchanges(t, testReader("newhope KAT")))
+		if digest != katDigests[torSampling] {
+			t.Fatalf("KAT mismatch (TorSampling: %v): %v", torSampling, digest)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// noise.go - NewHope noise sampler.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// noiseBytes is the amount of randomness consumed by getNoise(), 2k bits
+// per coefficient.
+const noiseBytes = paramN * paramK / 4
+
+// paramK must be between 1 and 56, so that each half of a sample fits in
+// a 64 bit load (see bitsAt()).
+const (
+	_ = uint(paramK - 1)
+	_ = uint(56 - paramK)
+)
+
+func (p *poly) getNoise(s *sampler, seed *[SeedBytes]byte, nonce byte) error {
+	// The `ref` code uses a uint32 vector instead of a byte vector,
+	// but converting between the two in Go is cumbersome.
+	var buf [noiseBytes]byte
+	var n [8]byte
+
+	n[0] = nonce
+	stream, err := s.chacha20(seed[:], n[:])
+	if err != nil {
+		return err
+	}
+	stream.KeyStream(buf[:])
+	stream.Reset()
+
+	p.binomial(&buf)
+
+	// Scrub the random bits...
+	memwipe(buf[:])
+
+	return nil
+}
+
+// binomialGeneric sets each coefficient of p to a sample from the centered
+// binomial distribution with parameter k = paramK, taken from 2k bits of buf.
+func (p *poly) binomialGeneric(buf *[noiseBytes]byte) {
+	if paramK == 16 {
+		p.binomial16(buf[:])
+	} else {
+		p.binomialK(buf[:], paramK)
+	}
+}
+
+// binomial16 is binomialGeneric() for k = 16, which is the popcount of 2
+// bytes minus the popcount of the next 2 bytes.  Two coefficients are
+// sampled at once, by counting the bits of all 8 bytes in parallel, within
+// a 64 bit word.
+func (p *poly) binomial16(buf []byte) {
+	const (
+		m1 = 0x5555555555555555
+		m2 = 0x3333333333333333
+		m4 = 0x0f0f0f0f0f0f0f0f
+		m8 = 0x00ff00ff00ff00ff
+	)
+	_ = buf[4*paramN-1]
+
+	for i := 0; i < paramN; i += 2 {
+		t := binary.LittleEndian.Uint64(buf[4*i:])
+		t -= (t >> 1) & m1
+		t = (t & m2) + ((t >> 2) & m2)
+		t = (t + (t >> 4)) & m4
+		t = (t + (t >> 8)) & m8
+
+		// The 16 bit lanes are a and b, for each coefficient.
+		p.coeffs[i] = uint16(t) + paramQ - uint16(t>>16)
+		p.coeffs[i+1] = uint16(t>>32) + paramQ - uint16(t>>48)
+	}
+}
+
+// binomialK samples with any k, from the bits of buf in little endian
+// order: the popcount of k bits, minus that of the next k bits.
+func (p *poly) binomialK(buf []byte, k uint) {
+	for i := range p.coeffs {
+		off := 2 * k * uint(i)
+		a := bits.OnesCount64(bitsAt(buf, off, k))
+		b := bits.OnesCount64(bitsAt(buf, off+k, k))
+		p.coeffs[i] = uint16(a) + paramQ - uint16(b)
+	}
+}
+
+// bitsAt returns the n <= 56 bits of buf starting at bit off.
+func bitsAt(buf []byte, off, n uint) uint64 {
+	var v uint64
+	for i := uint(0); 8*i < off%8+n; i++ {
+		v |= uint64(buf[off/8+i]) << (8 * i)
+	}
+	return (v >> (off % 8)) & (1<<n - 1)
+}
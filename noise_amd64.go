@@ -0,0 +1,26 @@
+// noise_amd64.go - NewHope noise sampler, AMD64 AVX2 backend.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build amd64 && !noasm && !newhope_boundcheck
+// +build amd64,!noasm,!newhope_boundcheck
+
+package newhope
+
+import "golang.org/x/sys/cpu"
+
+var useAVX2 = cpu.X86.HasAVX2
+
+//go:noescape
+func binomialAVX2(p *[paramN]uint16, buf *[noiseBytes]byte)
+
+func (p *poly) binomial(buf *[noiseBytes]byte) {
+	if paramK != 16 || paramQ != 12289 || !useAVX2 {
+		p.binomialGeneric(buf)
+		return
+	}
+	binomialAVX2(&p.coeffs, buf)
+}
@@ -0,0 +1,86 @@
+// noise_amd64.s - NewHope noise sampler, AMD64 AVX2 backend.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build amd64 && !noasm && !newhope_boundcheck
+// +build amd64,!noasm,!newhope_boundcheck
+
+#include "textflag.h"
+
+// The population count of each nibble.
+DATA ·popcnt4<>+0x00(SB)/8, $0x0302020102010100
+DATA ·popcnt4<>+0x08(SB)/8, $0x0403030203020201
+DATA ·popcnt4<>+0x10(SB)/8, $0x0302020102010100
+DATA ·popcnt4<>+0x18(SB)/8, $0x0403030203020201
+GLOBL ·popcnt4<>(SB), (NOPTR+RODATA), $32
+
+DATA ·nibbleMask<>+0x00(SB)/4, $0x0f0f0f0f
+GLOBL ·nibbleMask<>(SB), (NOPTR+RODATA), $4
+
+DATA ·onesB<>+0x00(SB)/4, $0x01010101
+GLOBL ·onesB<>(SB), (NOPTR+RODATA), $4
+
+// (1, -1), to compute a - b with PMADDWD.
+DATA ·plusMinus<>+0x00(SB)/4, $0xffff0001
+GLOBL ·plusMinus<>(SB), (NOPTR+RODATA), $4
+
+DATA ·paramQ<>+0x00(SB)/4, $12289
+GLOBL ·paramQ<>(SB), (NOPTR+RODATA), $4
+
+// POPCNT8 sets each byte of x to its population count, using t as scratch.
+#define POPCNT8(x, t) \
+	VPSRLW  $4, x, t;  \
+	VPAND   Y12, x, x; \
+	VPAND   Y12, t, t; \
+	VPSHUFB x, Y11, x; \
+	VPSHUFB t, Y11, t; \
+	VPADDB  t, x, x
+
+// SAMPLE8 turns the byte counts of 8 coefficients in x into a + q - b, as
+// 32 bit lanes.
+#define SAMPLE8(x)       \
+	VPMADDUBSW Y13, x, x; \
+	VPMADDWD   Y14, x, x; \
+	VPADDD     Y15, x, x
+
+// func binomialAVX2(p *[paramN]uint16, buf *[noiseBytes]byte)
+TEXT ·binomialAVX2(SB), NOSPLIT, $0-16
+	MOVQ p+0(FP), DI
+	MOVQ buf+8(FP), SI
+
+	VMOVDQU      ·popcnt4<>(SB), Y11
+	VPBROADCASTD ·nibbleMask<>(SB), Y12
+	VPBROADCASTD ·onesB<>(SB), Y13
+	VPBROADCASTD ·plusMinus<>(SB), Y14
+	VPBROADCASTD ·paramQ<>(SB), Y15
+	MOVQ         $64, CX
+
+	// Each iteration samples 16 coefficients from 64 bytes.  The byte
+	// counts are summed pairwise into a and b, and the differences are
+	// packed back into 16 bit lanes, which PACKUSDW interleaves by 128 bit
+	// lane, so they are permuted back into order.
+loop:
+	VMOVDQU   0(SI), Y0
+	VMOVDQU   32(SI), Y1
+	POPCNT8(Y0, Y2)
+	POPCNT8(Y1, Y3)
+	SAMPLE8(Y0)
+	SAMPLE8(Y1)
+	VPACKUSDW Y1, Y0, Y0
+	VPERMQ    $0xd8, Y0, Y0
+	VMOVDQU   Y0, 0(DI)
+	ADDQ      $64, SI
+	ADDQ      $32, DI
+	DECQ      CX
+	JNZ       loop
+
+	// Scrub the random bits...
+	VPXOR Y0, Y0, Y0
+	VPXOR Y1, Y1, Y1
+	VPXOR Y2, Y2, Y2
+	VPXOR Y3, Y3, Y3
+	VZEROUPPER
+	RET
@@ -0,0 +1,15 @@
+// noise_generic.go - NewHope noise sampler, portable backend.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build (!amd64 && !arm64) || noasm || newhope_boundcheck
+// +build !amd64,!arm64 noasm newhope_boundcheck
+
+package newhope
+
+func (p *poly) binomial(buf *[noiseBytes]byte) {
+	p.binomialGeneric(buf)
+}
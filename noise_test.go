@@ -0,0 +1,96 @@
+// noise_test.go - NewHope noise sampler tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+)
+
+// binomialRef is the reference implementation's sampler for k = 16.
+func (p *poly) binomialRef(buf []byte) {
+	for i := 0; i < paramN; i++ {
+		t := binary.LittleEndian.Uint32(buf[4*i:])
+		d := uint32(0)
+		for j := uint(0); j < 8; j++ {
+			d += (t >> j) & 0x01010101
+		}
+		a := ((d >> 8) & 0xff) + (d & 0xff)
+		b := (d >> 24) + ((d >> 16) & 0xff)
+		p.coeffs[i] = uint16(a) + paramQ - uint16(b)
+	}
+}
+
+// binomialBits samples one bit at a time, for any k.
+func (p *poly) binomialBits(buf []byte, k uint) {
+	bit := func(i uint) uint16 {
+		return uint16(buf[i/8]>>(i%8)) & 1
+	}
+	for i := range p.coeffs {
+		var a, b uint16
+		for j := uint(0); j < k; j++ {
+			a += bit(2*k*uint(i) + j)
+			b += bit(2*k*uint(i) + k + j)
+		}
+		p.coeffs[i] = a + paramQ - b
+	}
+}
+
+func TestBinomial(t *testing.T) {
+	var buf [paramN * 56 / 4]byte
+	var a, b poly
+
+	for i := 0; i < 64; i++ {
+		switch i {
+		case 0:
+			for j := range buf {
+				buf[j] = 0
+			}
+		case 1:
+			for j := range buf {
+				buf[j] = 0xff
+			}
+		case 2:
+			for j := range buf {
+				buf[j] = 0x0f
+			}
+		default:
+			if _, err := rand.Read(buf[:]); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+		}
+
+		b.binomialRef(buf[:])
+		for _, sampler := range []struct {
+			name string
+			fn   func(*poly)
+		}{
+			{"binomial16", func(p *poly) { p.binomial16(buf[:]) }},
+			{"binomialK", func(p *poly) { p.binomialK(buf[:], 16) }},
+			{"binomial", func(p *poly) {
+				var nbuf [noiseBytes]byte
+				copy(nbuf[:], buf[:])
+				p.binomial(&nbuf)
+			}},
+		} {
+			sampler.fn(&a)
+			if a != b {
+				t.Fatalf("%s mismatch on input %d", sampler.name, i)
+			}
+		}
+
+		for k := uint(1); k <= 56; k++ {
+			a.binomialK(buf[:], k)
+			b.binomialBits(buf[:], k)
+			if a != b {
+				t.Fatalf("binomialK(k = %d) mismatch on input %d", k, i)
+			}
+		}
+	}
+}
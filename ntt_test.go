@@ -0,0 +1,269 @@
+// ntt_test.go - NewHope Number Theoretic Transform tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+
+	"gitlab.com/yawning/newhope.git/internal/negacyclic"
+)
+
+// nttRef is the reference implementation's transform, which poly.ntt() and
+// poly.invNtt() must match, with the scaling and bit reversal.
+func nttRef(a *[paramN]uint16, omega *[paramN / 2]uint16) {
+	var distance uint
+
+	for i := uint(0); i < 10; i += 2 {
+		// Even level.
+		distance = (1 << i)
+		for start := uint(0); start < distance; start++ {
+			jTwiddle := 0
+			for j := start; j < paramN-1; j += 2 * distance {
+				w := uint32(omega[jTwiddle])
+				jTwiddle++
+				tmp := a[j]
+				a[j] = refAdd(tmp, a[j+distance], false)
+				a[j+distance] = refMontgomeryReduce(w * refSub(tmp, a[j+distance]))
+			}
+		}
+
+		// Odd level.
+		distance <<= 1
+		for start := uint(0); start < distance; start++ {
+			jTwiddle := 0
+			for j := start; j < paramN-1; j += 2 * distance {
+				w := uint32(omega[jTwiddle])
+				jTwiddle++
+				tmp := a[j]
+				a[j] = refAdd(tmp, a[j+distance], true)
+				a[j+distance] = refMontgomeryReduce(w * refSub(tmp, a[j+distance]))
+			}
+		}
+	}
+}
+
+// refAdd returns x + y for nttRef, reduced iff reduce is set, or always
+// without lazy reduction.
+func refAdd(x, y uint16, reduce bool) uint16 {
+	switch {
+	case !lazyReduction:
+		return uint16((uint32(x) + uint32(y)) % paramQ)
+	case reduce:
+		return barrettReduce(x + y)
+	}
+	return x + y
+}
+
+// refSub returns x - y for nttRef, offset to keep it positive.
+func refSub(x, y uint16) uint32 {
+	if !lazyReduction {
+		return (uint32(x) + paramQ - uint32(y)%paramQ) % paramQ
+	}
+	return uint32(x) + 3*paramQ - uint32(y)
+}
+
+func refMontgomeryReduce(a uint32) uint16 {
+	if !lazyReduction {
+		return montgomeryReduceStrict(a)
+	}
+	return montgomeryReduce(a)
+}
+
+// The reference implementation's tables, which apply psi and the bit
+// reversal in separate passes, in Montgomery form.
+var (
+	omegasMontgomery     [paramN / 2]uint16 // psi^(2 * bitrev(i))
+	omegasInvMontgomery  [paramN / 2]uint16 // psi^(-2 * bitrev(i))
+	psisBitrevMontgomery [paramN]uint16     // psi^bitrev(i)
+	psisInvMontgomery    [paramN]uint16     // psi^-i * n^-1
+)
+
+func init() {
+	exp := func(x, e uint64) uint64 {
+		r := uint64(1)
+		for x %= paramQ; e > 0; e >>= 1 {
+			if e&1 == 1 {
+				r = r * x % paramQ
+			}
+			x = x * x % paramQ
+		}
+		return r
+	}
+	montgomery := func(x uint64) uint16 {
+		return uint16((x << rlog) % paramQ)
+	}
+
+	// psi is the smallest primitive 2n-th root of unity, and psi^n = -1.
+	psi := uint64(2)
+	for exp(psi, paramN) != paramQ-1 {
+		psi++
+	}
+	psiInv := exp(psi, paramQ-2)
+	nInv := exp(paramN, paramQ-2)
+
+	for i := range omegasMontgomery {
+		e := 2 * uint64(bitrevTable[i]>>1)
+		omegasMontgomery[i] = montgomery(exp(psi, e))
+		omegasInvMontgomery[i] = montgomery(exp(psiInv, e))
+	}
+	for i := range psisBitrevMontgomery {
+		psisBitrevMontgomery[i] = montgomery(exp(psi, uint64(bitrevTable[i])))
+		psisInvMontgomery[i] = montgomery(exp(psiInv, uint64(i)) * nInv % paramQ)
+	}
+}
+
+func (p *poly) bitrev() {
+	for i, v := range p.coeffs {
+		r := bitrevTable[i]
+		if uint16(i) < r {
+			p.coeffs[i] = p.coeffs[r]
+			p.coeffs[r] = v
+		}
+	}
+}
+
+func (p *poly) mulCoefficients(factors *[paramN]uint16) {
+	for i, v := range factors {
+		p.coeffs[i] = refMontgomeryReduce(uint32(p.coeffs[i]) * uint32(v))
+	}
+}
+
+func (p *poly) nttRef() {
+	p.mulCoefficients(&psisBitrevMontgomery)
+	nttRef(&p.coeffs, &omegasMontgomery)
+}
+
+func (p *poly) invNttRef() {
+	p.bitrev()
+	nttRef(&p.coeffs, &omegasInvMontgomery)
+	p.mulCoefficients(&psisInvMontgomery)
+}
+
+func TestNTT(t *testing.T) {
+	var buf [2 * paramN]byte
+	var a, b poly
+
+	// Without lazy reduction, any 16 bit input is allowed, and the output
+	// is fully reduced.
+	inputMax, nttMax, invNttMax := uint16(1<<14-1), uint32(3*paramQ+4), uint32(1<<14)
+	if !lazyReduction {
+		inputMax, nttMax, invNttMax = 0xffff, paramQ, paramQ
+	}
+
+	for i := 0; i < 1024; i++ {
+		switch i {
+		case 0:
+			// Every input at the bound.
+			for j := range a.coeffs {
+				a.coeffs[j] = inputMax
+			}
+		case 1:
+			a.reset()
+		default:
+			if _, err := rand.Read(buf[:]); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+			for j := range a.coeffs {
+				a.coeffs[j] = binary.LittleEndian.Uint16(buf[2*j:]) & inputMax
+			}
+		}
+
+		for _, transform := range []struct {
+			name      string
+			fn, ref   func(*poly)
+			outputMax uint32
+		}{
+			{"ntt", (*poly).ntt, (*poly).nttRef, nttMax},
+			{"invNtt", (*poly).invNtt, (*poly).invNttRef, invNttMax},
+		} {
+			b = a
+			transform.fn(&a)
+			transform.ref(&b)
+			for j := range a.coeffs {
+				if uint32(a.coeffs[j]) >= transform.outputMax {
+					t.Fatalf("%s output out of range: a[%d] = %d", transform.name, j, a.coeffs[j])
+				}
+				if coeffFreeze(a.coeffs[j]) != coeffFreeze(b.coeffs[j]) {
+					t.Fatalf("%s mismatch: a[%d] = %d, expected %d", transform.name, j, a.coeffs[j], b.coeffs[j])
+				}
+			}
+			a = b
+		}
+	}
+}
+
+func TestNTTMultiply(t *testing.T) {
+	var buf [4 * paramN]byte
+	var a, b, c poly
+
+	// Each case sets a and b, the inputs to ntt(), which must be less than
+	// 2^14.
+	set := func(p *poly, fn func(int) uint16) {
+		for j := range p.coeffs {
+			p.coeffs[j] = fn(j)
+		}
+	}
+	random := func(off int, max uint16) func(int) uint16 {
+		return func(j int) uint16 {
+			return binary.LittleEndian.Uint16(buf[off+2*j:]) % max
+		}
+	}
+	constant := func(v uint16) func(int) uint16 {
+		return func(int) uint16 { return v }
+	}
+	monomial := func(k int, v uint16) func(int) uint16 {
+		return func(j int) uint16 {
+			if j == k {
+				return v
+			}
+			return 0
+		}
+	}
+	cases := []struct {
+		name string
+		a, b func(int) uint16
+	}{
+		{"zero", constant(0), constant(1<<14 - 1)},
+		{"one", monomial(0, 1), constant(1<<14 - 1)},
+		{"x^(n-1) * x", monomial(paramN-1, 1), monomial(1, 1)},
+		{"x^(n-1) * x^(n-1)", monomial(paramN-1, paramQ-1), monomial(paramN-1, paramQ-1)},
+		{"all q - 1", constant(paramQ - 1), constant(paramQ - 1)},
+		{"all q", constant(paramQ), constant(paramQ)},
+		{"all 2^14 - 1", constant(1<<14 - 1), constant(1<<14 - 1)},
+		{"alternating", func(j int) uint16 { return uint16(j&1) * (1<<14 - 1) }, constant(paramQ - 1)},
+	}
+
+	for i := 0; i < len(cases)+64; i++ {
+		name, fnA, fnB := "random", random(0, 1<<14), random(2*paramN, 1<<14)
+		ref := negacyclic.Karatsuba
+		if i < len(cases) {
+			name, fnA, fnB, ref = cases[i].name, cases[i].a, cases[i].b, negacyclic.Schoolbook
+		} else if _, err := rand.Read(buf[:]); err != nil {
+			t.Fatalf("rand.Read failed: %v", err)
+		}
+		set(&a, fnA)
+		set(&b, fnB)
+		expected := ref(a.coeffs[:], b.coeffs[:], paramQ)
+
+		// c <- invNtt(ntt(a) o ntt(b)), where ntt() takes its input in bit
+		// reversed order, and invNtt() returns its output in normal order.
+		a.bitrev()
+		b.bitrev()
+		a.ntt()
+		b.ntt()
+		c.pointwise(&a, &b)
+		c.invNtt()
+		for j := range c.coeffs {
+			if coeffFreeze(c.coeffs[j]) != expected[j] {
+				t.Fatalf("%s: c[%d] = %d, expected %d", name, j, coeffFreeze(c.coeffs[j]), expected[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// options.go - NewHope key pair options.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+// KeyOptions are the options of Alice's key pair, which are fixed when it
+// is generated.  Those that Bob needs are carried by her public key, so
+// that both sides of an exchange always agree on them.  The zero value
+// selects the defaults, as does a nil *KeyOptions.
+type KeyOptions struct {
+	// Reconciler is the reconciliation mechanism of a NewHope key pair.
+	Reconciler Reconciler
+
+	// Encoding is the message encoding of a NewHope-Simple key pair.
+	Encoding MessageEncoding
+}
+
+func (o *KeyOptions) reconciler() Reconciler {
+	if o == nil {
+		return ReconcilerD4
+	}
+	return o.Reconciler
+}
+
+func (o *KeyOptions) encoding() MessageEncoding {
+	if o == nil {
+		return EncodingRepetition
+	}
+	return o.Encoding
+}
@@ -10,8 +10,58 @@ package newhope
 const (
 	paramN = 1024
 	paramK = 16 // used in sampler
+
+	// paramQ is the modulus, a prime with q = 1 mod 2n below 2^16, which
+	// for n = 1024 leaves 12289, 18433, 40961, 59393 and 61441.  Every
+	// other modulus dependent constant is derived from it, except for the
+	// NTT tables in precomp.go, which must be regenerated with
+	// `go run ./internal/gentables -q <q> -o precomp.go`.  Only 12289 is
+	// below 2^14, as the lazily reduced arithmetic requires, and the larger
+	// moduli use slower, fully reduced arithmetic instead, without the
+	// assembly backends, and TorSampling is not supported with 40961.
+	// Public keys and ciphertexts grow with paramQBits, and the known answer
+	// tests (including the self-tests) are only valid for the default
+	// parameters.
+	//
+	// paramQ is a compile-time constant, not an option that importers can
+	// select: using another modulus means editing it and paramQBits here,
+	// and regenerating precomp.go, in a modified copy of the package, and
+	// both sides *MUST* be built with the same value.  As n is fixed at
+	// 1024, moduli that are only 1 mod a smaller 2n, such as 7681 and 3329,
+	// can not be used at all.
 	paramQ = 12289
 
+	// paramQBits is the number of bits in an encoded coefficient, the
+	// bit length of q - 1.
+	paramQBits = 14
+
+	// paramD is the number of bits each coefficient of v is compressed to
+	// in NewHope-Simple, between 3 and 6.  Bob's message is 128 bytes
+	// longer for each extra bit, and the rounding error added to each
+	// coefficient is at most q/2^(d+1): 768, 384, 192 and 96 for 3 to 6
+	// bits.  This error adds to the noise that decryption must tolerate,
+	// so each extra bit lowers the decryption failure probability, which
+	// the NewHope-Simple paper only analyses for the default of 3 bits.
+	//
+	// paramD is a compile-time constant, not an option that importers can
+	// select: using another depth means editing it here, in a modified copy
+	// of the package, and both sides *MUST* be built with the same value.
+	paramD = 3
+
 	// SeedBytes is the size of the seed in bytes.
 	SeedBytes = 32
 )
+
+// paramQ must be 1 mod 2n, and paramQBits must be the bit length of q - 1.
+const (
+	_ = uint(paramQ%(2*paramN) - 1)
+	_ = uint(1 - paramQ%(2*paramN))
+	_ = uint(paramQ - 1 - 1<<(paramQBits-1))
+	_ = uint(1<<paramQBits - paramQ)
+	_ = uint(16 - paramQBits)
+)
+
+// lazyReduction is true iff q < 2^14, which leaves enough headroom in 16
+// bits (and in 32 bits, for montgomeryReduce) for the lazily reduced
+// arithmetic.
+const lazyReduction = paramQ < 1<<14
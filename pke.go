@@ -0,0 +1,95 @@
+// pke.go - NewHope-Simple public key encryption.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"context"
+	"io"
+	"runtime"
+)
+
+const (
+	// MessageSize is the length of a message encrypted by Encrypt in bytes.
+	MessageSize = 32
+
+	// CoinsSize is the length of the randomness taken by EncryptWithCoins
+	// in bytes.
+	CoinsSize = SeedBytes
+)
+
+// Encrypt encrypts msg to the NewHope-Simple public key pub, using the given
+// reader, which must return random data.  The ciphertext is in the same
+// format as Bob's NewHope-Simple public key, and KeyExchangeSimpleBob is
+// Encrypt of a random message.
+//
+// This is the underlying CPA secure encryption scheme, which is malleable,
+// and leaks information about the private key to anyone who can tell if
+// Decrypt succeeded on chosen ciphertexts.  Most users want the CCA secure
+// EncapsulateSimple instead.
+//
+// The sender and the recipient *MUST* agree on TorSampling, and the message
+// is encoded with the encoding of pub.
+func Encrypt(rand io.Reader, pub *PublicKeySimpleAlice, msg *[MessageSize]byte) (*PublicKeySimpleBob, error) {
+	if pub == nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	var coins [CoinsSize]byte
+	if err := readRandom(context.Background(), rand, coins[:]); err != nil {
+		return nil, err
+	}
+	defer memwipe(coins[:])
+
+	return EncryptWithCoins(pub, msg, &coins)
+}
+
+// EncryptWithCoins is Encrypt, with the randomness supplied by the caller,
+// so that the ciphertext is a deterministic function of pub, msg and
+// coins.  The coins must be uniformly random and secret, and must never be
+// used to encrypt a different message, as this reveals the difference
+// between the messages.
+func EncryptWithCoins(pub *PublicKeySimpleAlice, msg *[MessageSize]byte, coins *[CoinsSize]byte) (*PublicKeySimpleBob, error) {
+	if pub == nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	var s scratch
+	defer s.release()
+
+	return s.encryptSimple(context.Background(), pub, msg, coins, TorSampling, pub.Encoding)
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt.  Only a ciphertext that
+// does not hold a well formed polynomial is rejected, with
+// ErrInvalidCiphertext, and any other ciphertext decrypts to some message.
+//
+// Unlike KeyExchangeSimpleAlice, the private key is left intact so that it
+// may be used to decrypt further ciphertexts, until it is Reset().
+func Decrypt(priv *PrivateKeySimpleAlice, ct *PublicKeySimpleBob) (*[MessageSize]byte, error) {
+	if priv == nil || priv.key() == nil {
+		return nil, ErrKeyConsumed
+	}
+	if ct == nil {
+		return nil, ErrInvalidCiphertext
+	}
+
+	var s scratch
+	defer s.release()
+
+	msg := new([MessageSize]byte)
+	err := s.decryptSimple(ct, priv.key(), msg, priv.pub.Encoding)
+	runtime.KeepAlive(priv)
+	if err != nil {
+		if err == ErrInvalidPublicKey {
+			err = ErrInvalidCiphertext
+		}
+		return nil, err
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,102 @@
+// pke_test.go - NewHope-Simple public key encryption tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestPKE(t *testing.T) {
+	TorSampling = false
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	for i := 0; i < 64; i++ {
+		var msg [MessageSize]byte
+		var coins [CoinsSize]byte
+		switch i {
+		case 0:
+		case 1:
+			for j := range msg {
+				msg[j] = 0xff
+			}
+		default:
+			if _, err = rand.Read(msg[:]); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+		}
+		if _, err = rand.Read(coins[:]); err != nil {
+			t.Fatalf("rand.Read failed: %v", err)
+		}
+
+		ct, err := Encrypt(rand.Reader, alicePub, &msg)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		decrypted, err := Decrypt(alicePriv, ct)
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if *decrypted != msg {
+			t.Fatalf("Decrypt mismatch")
+		}
+
+		// The ciphertext only depends on the coins, which are what make it
+		// differ from Encrypt's.
+		ct2, err := EncryptWithCoins(alicePub, &msg, &coins)
+		if err != nil {
+			t.Fatalf("EncryptWithCoins failed: %v", err)
+		}
+		ct3, err := EncryptWithCoins(alicePub, &msg, &coins)
+		if err != nil {
+			t.Fatalf("EncryptWithCoins failed: %v", err)
+		}
+		if *ct2 != *ct3 {
+			t.Fatalf("EncryptWithCoins is not deterministic")
+		}
+		if bytes.Equal(ct.Send[:], ct2.Send[:]) {
+			t.Fatalf("Encrypt and EncryptWithCoins ciphertexts match")
+		}
+		if decrypted, err = Decrypt(alicePriv, ct2); err != nil || *decrypted != msg {
+			t.Fatalf("Decrypt of EncryptWithCoins ciphertext failed: %v", err)
+		}
+	}
+}
+
+func TestPKEErrors(t *testing.T) {
+	TorSampling = false
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	var msg [MessageSize]byte
+	ct, err := Encrypt(rand.Reader, alicePub, &msg)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	if _, err = Encrypt(rand.Reader, nil, &msg); err != ErrInvalidPublicKey {
+		t.Fatalf("Encrypt(nil): %v", err)
+	}
+	if _, err = Decrypt(alicePriv, nil); err != ErrInvalidCiphertext {
+		t.Fatalf("Decrypt(nil): %v", err)
+	}
+	bad := *ct
+	setFirstCoeff(bad.Send[:], paramQ)
+	if _, err = Decrypt(alicePriv, &bad); err != ErrInvalidCiphertext {
+		t.Fatalf("Decrypt with a malformed ciphertext: %v", err)
+	}
+	alicePriv.Reset()
+	if _, err = Decrypt(alicePriv, ct); err != ErrKeyConsumed {
+		t.Fatalf("Decrypt with a consumed key: %v", err)
+	}
+}
@@ -8,15 +8,15 @@
 package newhope
 
 import (
+	"context"
 	"encoding/binary"
-
-	"gitlab.com/yawning/chacha20.git"
-	"golang.org/x/crypto/sha3"
+	"errors"
+	"runtime"
 )
 
 const (
 	// PolyBytes is the length of an encoded polynomial in bytes.
-	PolyBytes = 1792
+	PolyBytes = paramN * paramQBits / 8
 
 	shake128Rate = 168 // Stupid that this isn't exposed.
 )
@@ -29,57 +29,67 @@ func (p *poly) reset() {
 	for i := range p.coeffs {
 		p.coeffs[i] = 0
 	}
+	runtime.KeepAlive(p)
 }
 
+// fromBytes unpacks the paramQBits bit coefficients of p from a, least
+// significant bit first.
 func (p *poly) fromBytes(a []byte) {
-	for i := 0; i < paramN/4; i++ {
-		p.coeffs[4*i+0] = uint16(a[7*i+0]) | ((uint16(a[7*i+1]) & 0x3f) << 8)
-		p.coeffs[4*i+1] = (uint16(a[7*i+1]) >> 6) | (uint16(a[7*i+2]) << 2) | ((uint16(a[7*i+3]) & 0x0f) << 10)
+	var t uint32
+	var n uint
 
-		p.coeffs[4*i+2] = (uint16(a[7*i+3]) >> 4) | (uint16(a[7*i+4]) << 4) | ((uint16(a[7*i+5]) & 0x03) << 12)
-		p.coeffs[4*i+3] = (uint16(a[7*i+5]) >> 2) | (uint16(a[7*i+6]) << 6)
+	_ = a[PolyBytes-1]
+	for i := range p.coeffs {
+		for ; n < paramQBits; n += 8 {
+			t |= uint32(a[0]) << n
+			a = a[1:]
+		}
+		p.coeffs[i] = uint16(t & (1<<paramQBits - 1))
+		t >>= paramQBits
+		n -= paramQBits
 	}
 }
 
+// isCanonical returns true iff every coefficient is fully reduced, as is
+// always the case for the output of fromBytes on a well formed encoding.
+func (p *poly) isCanonical() bool {
+	r := uint32(0)
+	for _, v := range p.coeffs {
+		// The top bit of (v - paramQ) is clear iff v >= paramQ.
+		r |= ^(uint32(v) - paramQ)
+	}
+	return r>>31 == 0
+}
+
+// toBytes packs the fully reduced coefficients of p to r, paramQBits bits
+// each, least significant bit first.
 func (p *poly) toBytes(r []byte) {
-	for i := 0; i < paramN/4; i++ {
-		// Make sure that coefficients have only 14 bits.
-		t0 := barrettReduce(p.coeffs[4*i+0])
-		t1 := barrettReduce(p.coeffs[4*i+1])
-		t2 := barrettReduce(p.coeffs[4*i+2])
-		t3 := barrettReduce(p.coeffs[4*i+3])
-
-		// Make sure that coefficients are in [0,q]
-		m := t0 - paramQ
-		c := int16(m)
-		c >>= 15
-		t0 = m ^ ((t0 ^ m) & uint16(c))
-
-		m = t1 - paramQ
-		c = int16(m)
-		c >>= 15
-		t1 = m ^ ((t1 ^ m) & uint16(c))
-
-		m = t2 - paramQ
-		c = int16(m)
-		c >>= 15
-		t2 = m ^ ((t2 ^ m) & uint16(c))
-
-		m = t3 - paramQ
-		c = int16(m)
-		c >>= 15
-		t3 = m ^ ((t3 ^ m) & uint16(c))
-
-		r[7*i+0] = byte(t0 & 0xff)
-		r[7*i+1] = byte(t0>>8) | byte(t1<<6)
-		r[7*i+2] = byte(t1 >> 2)
-		r[7*i+3] = byte(t1>>10) | byte(t2<<4)
-		r[7*i+4] = byte(t2 >> 4)
-		r[7*i+5] = byte(t2>>12) | byte(t3<<2)
-		r[7*i+6] = byte(t3 >> 6)
+	var t uint32
+	var n uint
+
+	_ = r[PolyBytes-1]
+	for _, v := range p.coeffs {
+		t |= uint32(coeffFreeze(v)) << n
+		for n += paramQBits; n >= 8; n -= 8 {
+			r[0] = byte(t)
+			r = r[1:]
+			t >>= 8
+		}
 	}
 }
 
+// uniformBound is the largest multiple of q that is at most 2^16, below
+// which the 16 bit samples of uniform() are accepted, so that they are
+// uniform mod q.
+const uniformBound = (1 << 16) / paramQ * paramQ
+
+// torSamplingSupported is true iff uniform() supports TorSampling with q.
+// Each attempt needs 64 of every 84 samples to be accepted, which almost
+// never happens unless over 3/4 of them are, as is not the case for 40961.
+const torSamplingSupported = uniformBound >= 3<<14
+
+var errTorSamplingModulus = errors.New("newhope: TorSampling is not supported with this modulus")
+
 func (p *poly) discardTo(xbuf []byte) bool {
 	var x [shake128Rate * 16 / 2]uint16
 	for i := range x {
@@ -93,7 +103,7 @@ func (p *poly) discardTo(xbuf []byte) bool {
 	// Check whether we're safe:
 	r := int(0)
 	for i := 1000; i < 1024; i++ {
-		r |= 61444 - int(x[i])
+		r |= uniformBound - 1 - int(x[i])
 	}
 	if r>>31 != 0 {
 		return true
@@ -107,21 +117,21 @@ func (p *poly) discardTo(xbuf []byte) bool {
 	return false
 }
 
-func (p *poly) uniform(seed *[SeedBytes]byte, torSampling bool) {
+func (p *poly) uniform(ctx context.Context, s *sampler, seed *[SeedBytes]byte, torSampling bool) error {
 	if !torSampling {
 		// Reference version, vartime.
 		nBlocks := 14
 		var buf [shake128Rate * 14]byte
 
 		// h and buf are left unscrubbed because the output is public.
-		h := sha3.NewShake128()
+		h := s.shake128()
 		_, _ = h.Write(seed[:])
 		_, _ = h.Read(buf[:])
 
 		for ctr, pos := 0, 0; ctr < paramN; {
 			val := binary.LittleEndian.Uint16(buf[pos:])
 
-			if val < 5*paramQ {
+			if val < uniformBound {
 				p.coeffs[ctr] = val
 				ctr++
 			}
@@ -138,8 +148,12 @@ func (p *poly) uniform(seed *[SeedBytes]byte, torSampling bool) {
 		const nBlocks = 16
 		var buf [shake128Rate * nBlocks]byte
 
+		if !torSamplingSupported {
+			return wrapError(ErrSamplingMismatch, errTorSamplingModulus)
+		}
+
 		// h and buf are left unscrubbed because the output is public.
-		h := sha3.NewShake128()
+		h := s.shake128()
 		_, _ = h.Write(seed[:])
 
 		for {
@@ -147,66 +161,50 @@ func (p *poly) uniform(seed *[SeedBytes]byte, torSampling bool) {
 			if !p.discardTo(buf[:]) {
 				break
 			}
-		}
 
+			// This can take several attempts, so check for cancellation
+			// between each.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
 	}
+
+	return nil
 }
 
-func (p *poly) getNoise(seed *[SeedBytes]byte, nonce byte) {
-	// The `ref` code uses a uint32 vector instead of a byte vector,
-	// but converting between the two in Go is cumbersome.
-	var buf [4 * paramN]byte
-	var n [8]byte
+// montgomeryR2 is 2^36 mod q, which montgomeryReduce() takes to the
+// Montgomery form 2^18 mod q.
+const montgomeryR2 = (1 << (2 * rlog)) % paramQ
 
-	n[0] = nonce
-	stream, err := chacha20.New(seed[:], n[:])
-	if err != nil {
-		panic(err)
-	}
-	stream.KeyStream(buf[:])
-	stream.Reset()
-
-	for i := 0; i < paramN; i++ {
-		t := binary.LittleEndian.Uint32(buf[4*i:])
-		d := uint32(0)
-		for j := uint(0); j < 8; j++ {
-			d += (t >> j) & 0x01010101
+func (p *poly) pointwiseGeneric(a, b *poly) {
+	if !lazyReduction {
+		for i := range p.coeffs {
+			p.coeffs[i] = uint16(uint32(a.coeffs[i]) * uint32(b.coeffs[i]) % paramQ)
 		}
-		a := ((d >> 8) & 0xff) + (d & 0xff)
-		b := (d >> 24) + ((d >> 16) & 0xff)
-		p.coeffs[i] = uint16(a) + paramQ - uint16(b)
+		return
 	}
 
-	// Scrub the random bits...
-	memwipe(buf[:])
-}
-
-func (p *poly) pointwise(a, b *poly) {
 	for i := range p.coeffs {
-		t := montgomeryReduce(3186 * uint32(b.coeffs[i]))               // t is now in Montgomery domain
+		t := montgomeryReduce(montgomeryR2 * uint32(b.coeffs[i]))       // t is now in Montgomery domain
 		p.coeffs[i] = montgomeryReduce(uint32(a.coeffs[i]) * uint32(t)) // p.coeffs[i] is back in normal domain
 	}
 }
 
-func (p *poly) add(a, b *poly) {
-	for i := range p.coeffs {
-		p.coeffs[i] = barrettReduce(a.coeffs[i] + b.coeffs[i])
+// addGeneric sets p to a + b without reducing, which is safe as long as the
+// sum of the bounds of a and b is less than 2^16.  Every caller adds either
+// the output of ntt() (less than 3q + 4) and a value less than 2^14, or up
+// to three values less than 2^14, and every consumer of the result reduces.
+// Without lazy reduction, the sum is fully reduced instead.
+func (p *poly) addGeneric(a, b *poly) {
+	if !lazyReduction {
+		for i := range p.coeffs {
+			p.coeffs[i] = uint16((uint32(a.coeffs[i]) + uint32(b.coeffs[i])) % paramQ)
+		}
+		return
 	}
-}
-
-func (p *poly) ntt() {
-	p.mulCoefficients(&psisBitrevMontgomery)
-	ntt(&p.coeffs, &omegasMontgomery)
-}
 
-func (p *poly) invNtt() {
-	p.bitrev()
-	ntt(&p.coeffs, &omegasInvMontgomery)
-	p.mulCoefficients(&psisInvMontgomery)
-}
-
-func init() {
-	if paramK != 16 {
-		panic("poly.getNoise() only supports k=16")
+	for i := range p.coeffs {
+		p.coeffs[i] = checkedUint16(uint32(a.coeffs[i]) + uint32(b.coeffs[i]))
 	}
 }
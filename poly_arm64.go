@@ -0,0 +1,103 @@
+// poly_arm64.go - NewHope polynomial arithmetic, ARM64 NEON backend.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build arm64 && !noasm && !newhope_boundcheck
+// +build arm64,!noasm,!newhope_boundcheck
+
+package newhope
+
+// The NEON routines produce output identical to that of the portable ones,
+// and NEON is mandatory on arm64, so there is nothing to detect at runtime.
+//
+// Building with the `noasm` tag forces the portable backend, as does the
+// `newhope_boundcheck` tag, since the checks are only done in Go.  The
+// routines are for q = 12289, and any other modulus uses the portable ones.
+
+//go:noescape
+func nttNEON(p *[paramN]uint16, w *[paramN - 1]uint16)
+
+//go:noescape
+func invNttNEON(p, tmp *[paramN]uint16, w *[paramN - 1]uint16)
+
+//go:noescape
+func pointwiseNEON(p, a, b *[paramN]uint16)
+
+//go:noescape
+func addNEON(p, a, b *[paramN]uint16)
+
+//go:noescape
+func binomialNEON(p *[paramN]uint16, buf *[noiseBytes]byte)
+
+//go:noescape
+func compressNEON(r *byte, p *[paramN]uint16)
+
+//go:noescape
+func decompressNEON(p *[paramN]uint16, a *byte)
+
+func (p *poly) ntt() {
+	if paramQ != 12289 {
+		p.nttGeneric()
+		return
+	}
+	nttNEON(&p.coeffs, &nttTwiddlesMontgomery)
+}
+
+func (p *poly) invNtt() {
+	if paramQ != 12289 {
+		p.invNttGeneric()
+		return
+	}
+	var a [paramN]uint16
+	invNttNEON(&p.coeffs, &a, &invNttTwiddlesMontgomery)
+
+	// Scrub the intermediary values.
+	for i := range a {
+		a[i] = 0
+	}
+}
+
+func (p *poly) pointwise(a, b *poly) {
+	if paramQ != 12289 {
+		p.pointwiseGeneric(a, b)
+		return
+	}
+	pointwiseNEON(&p.coeffs, &a.coeffs, &b.coeffs)
+}
+
+func (p *poly) add(a, b *poly) {
+	if paramQ != 12289 {
+		p.addGeneric(a, b)
+		return
+	}
+	addNEON(&p.coeffs, &a.coeffs, &b.coeffs)
+}
+
+func (p *poly) binomial(buf *[noiseBytes]byte) {
+	if paramK != 16 || paramQ != 12289 {
+		p.binomialGeneric(buf)
+		return
+	}
+	binomialNEON(&p.coeffs, buf)
+}
+
+func (p *poly) compress(r []byte) {
+	if paramD != 3 || paramQ != 12289 {
+		p.compressGeneric(r)
+		return
+	}
+	_ = r[compressedBytes-1]
+	compressNEON(&r[0], &p.coeffs)
+}
+
+func (p *poly) decompress(a []byte) {
+	if paramD != 3 || paramQ != 12289 {
+		p.decompressGeneric(a)
+		return
+	}
+	_ = a[compressedBytes-1]
+	decompressNEON(&p.coeffs, &a[0])
+}
@@ -0,0 +1,681 @@
+// poly_arm64.s - NewHope polynomial arithmetic, ARM64 NEON backend.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build arm64 && !noasm && !newhope_boundcheck
+// +build arm64,!noasm,!newhope_boundcheck
+
+#include "textflag.h"
+
+// Every routine mirrors the corresponding portable one in Go, operation for
+// operation, so that the output is identical.  The arithmetic is done on
+// 32 bit lanes (4 coefficients per register), except where noted.
+
+#define PARAM_Q 12289
+#define QINV 12287
+#define N_INV 256
+
+// Register conventions for the transforms:
+//
+//   V24 = qinv, V25 = q, V26 = 2q (ntt) or 3q (invNtt),
+//   V27 = floor(2^28 / q) (ntt) or 5 (invNtt), V28 = 2^18 - 1.
+#define CONSTANTS(c26, c27) \
+	MOVW $QINV, R20;                \
+	VDUP R20, V24.S4;               \
+	MOVW $PARAM_Q, R20;             \
+	VDUP R20, V25.S4;               \
+	MOVW $c26, R20;                 \
+	VDUP R20, V26.S4;               \
+	MOVW $c27, R20;                 \
+	VDUP R20, V27.S4;               \
+	MOVW $0x3ffff, R20;             \
+	VDUP R20, V28.S4
+
+// MONT sets y to montgomeryReduce(x), clobbering x.
+#define MONT(x, y) \
+	VMUL  V24.S4, x.S4, y.S4;       \
+	VAND  V28.B16, y.B16, y.B16;    \
+	VMLA  V25.S4, y.S4, x.S4;       \
+	VUSHR $18, x.S4, y.S4
+
+// CT is ctButterfly(x, y, w), with t as scratch.
+#define CT(x, y, w, t) \
+	VMUL  w.S4, y.S4, t.S4;         \
+	VMUL  V24.S4, t.S4, y.S4;       \
+	VAND  V28.B16, y.B16, y.B16;    \
+	VMLA  V25.S4, y.S4, t.S4;       \
+	VUSHR $18, t.S4, t.S4;          \
+	VADD  V26.S4, x.S4, y.S4;       \
+	VSUB  t.S4, y.S4, y.S4;         \
+	VADD  t.S4, x.S4, x.S4
+
+// GS is gsButterfly(x, y, w), with t as scratch.
+#define GS(x, y, w, t) \
+	VADD V26.S4, x.S4, t.S4;        \
+	VSUB y.S4, t.S4, t.S4;          \
+	VADD y.S4, x.S4, x.S4;          \
+	VMUL w.S4, t.S4, t.S4;          \
+	MONT(t, y)
+
+// BARRETT32 is barrettReduce32(x), with t as scratch.
+#define BARRETT32(x, t) \
+	VMUL  V27.S4, x.S4, t.S4;       \
+	VUSHR $28, t.S4, t.S4;          \
+	VMLS  V25.S4, t.S4, x.S4
+
+// BARRETT16 is barrettReduce(x), with t as scratch.
+#define BARRETT16(x, t) \
+	VMUL  V27.S4, x.S4, t.S4;       \
+	VUSHR $16, t.S4, t.S4;          \
+	VMLS  V25.S4, t.S4, x.S4
+
+// RADIX8 does three levels of the forward transform on V0-V7, with the
+// twiddle factors in V16-V22, and reduces the output.
+#define RADIX8 \
+	CT(V0, V1, V16, V8);            \
+	CT(V2, V3, V16, V9);            \
+	CT(V4, V5, V16, V10);           \
+	CT(V6, V7, V16, V11);           \
+	CT(V0, V2, V17, V8);            \
+	CT(V1, V3, V18, V9);            \
+	CT(V4, V6, V17, V10);           \
+	CT(V5, V7, V18, V11);           \
+	CT(V0, V4, V19, V8);            \
+	CT(V1, V5, V20, V9);            \
+	CT(V2, V6, V21, V10);           \
+	CT(V3, V7, V22, V11);           \
+	BARRETT32(V0, V8);              \
+	BARRETT32(V1, V9);              \
+	BARRETT32(V2, V10);             \
+	BARRETT32(V3, V11);             \
+	BARRETT32(V4, V12);             \
+	BARRETT32(V5, V13);             \
+	BARRETT32(V6, V14);             \
+	BARRETT32(V7, V15)
+
+#define WIDEN8 \
+	VUXTL V0.H4, V0.S4;             \
+	VUXTL V1.H4, V1.S4;             \
+	VUXTL V2.H4, V2.S4;             \
+	VUXTL V3.H4, V3.S4;             \
+	VUXTL V4.H4, V4.S4;             \
+	VUXTL V5.H4, V5.S4;             \
+	VUXTL V6.H4, V6.S4;             \
+	VUXTL V7.H4, V7.S4
+
+#define NARROW8 \
+	VXTN V0.S4, V0.H4;              \
+	VXTN V1.S4, V1.H4;              \
+	VXTN V2.S4, V2.H4;              \
+	VXTN V3.S4, V3.H4;              \
+	VXTN V4.S4, V4.H4;              \
+	VXTN V5.S4, V5.H4;              \
+	VXTN V6.S4, V6.H4;              \
+	VXTN V7.S4, V7.H4
+
+// func nttNEON(p *[paramN]uint16, w *[paramN - 1]uint16)
+TEXT ·nttNEON(SB), NOSPLIT, $0-16
+	MOVD p+0(FP), R0
+	MOVD w+8(FP), R1
+	CONSTANTS(2*PARAM_Q, 21843)
+
+	// Butterflies spanning 1, 2 and 4, which share their twiddle factors,
+	// on 4 blocks of 8 coefficients at a time.  The blocks are transposed
+	// so that each register holds the same coefficient of every block.
+	VLD1  (R1), [V8.H8]
+	VUXTL V8.H4, V9.S4
+	VUXTL2 V8.H8, V10.S4
+	VDUP  V9.S[0], V16.S4
+	VDUP  V9.S[1], V17.S4
+	VDUP  V9.S[2], V18.S4
+	VDUP  V9.S[3], V19.S4
+	VDUP  V10.S[0], V20.S4
+	VDUP  V10.S[1], V21.S4
+	VDUP  V10.S[2], V22.S4
+	ADD   $14, R1
+
+	MOVD R0, R2
+	MOVD R0, R3
+	MOVD $32, R4
+
+ntt_first:
+	VLD4.P 32(R2), [V8.H4, V9.H4, V10.H4, V11.H4]
+	VLD4.P 32(R2), [V12.H4, V13.H4, V14.H4, V15.H4]
+	VUZP1  V12.H4, V8.H4, V0.H4
+	VUZP2  V12.H4, V8.H4, V4.H4
+	VUZP1  V13.H4, V9.H4, V1.H4
+	VUZP2  V13.H4, V9.H4, V5.H4
+	VUZP1  V14.H4, V10.H4, V2.H4
+	VUZP2  V14.H4, V10.H4, V6.H4
+	VUZP1  V15.H4, V11.H4, V3.H4
+	VUZP2  V15.H4, V11.H4, V7.H4
+	WIDEN8
+	RADIX8
+	NARROW8
+	VZIP1  V4.H4, V0.H4, V8.H4
+	VZIP2  V4.H4, V0.H4, V12.H4
+	VZIP1  V5.H4, V1.H4, V9.H4
+	VZIP2  V5.H4, V1.H4, V13.H4
+	VZIP1  V6.H4, V2.H4, V10.H4
+	VZIP2  V6.H4, V2.H4, V14.H4
+	VZIP1  V7.H4, V3.H4, V11.H4
+	VZIP2  V7.H4, V3.H4, V15.H4
+	VST4.P [V8.H4, V9.H4, V10.H4, V11.H4], 32(R3)
+	VST4.P [V12.H4, V13.H4, V14.H4, V15.H4], 32(R3)
+	SUBS   $1, R4
+	BNE    ntt_first
+
+	// Butterflies spanning 8, 16 and 32, and then 64, 128 and 256, on 4
+	// consecutive k at a time, with R5 = 2 * span in bytes, R6 = number of
+	// 4-wide columns (span / 4), and R7 = number of blocks (n / 8 span).
+	MOVD $16, R5
+	MOVD $2, R6
+	MOVD $16, R7
+
+ntt_group:
+	MOVD R0, R8
+	MOVD R1, R9
+
+ntt_column:
+	// Load the twiddle factors of this column.
+	MOVD   R9, R10
+	VLD1.P (R10)(R5), [V16.H4]
+	VLD1.P (R10)(R5), [V17.H4]
+	VLD1.P (R10)(R5), [V18.H4]
+	VLD1.P (R10)(R5), [V19.H4]
+	VLD1.P (R10)(R5), [V20.H4]
+	VLD1.P (R10)(R5), [V21.H4]
+	VLD1   (R10), [V22.H4]
+	VUXTL  V16.H4, V16.S4
+	VUXTL  V17.H4, V17.S4
+	VUXTL  V18.H4, V18.S4
+	VUXTL  V19.H4, V19.S4
+	VUXTL  V20.H4, V20.S4
+	VUXTL  V21.H4, V21.S4
+	VUXTL  V22.H4, V22.S4
+
+	MOVD R8, R2
+	MOVD R8, R3
+	MOVD R7, R4
+
+ntt_block:
+	VLD1.P (R2)(R5), [V0.H4]
+	VLD1.P (R2)(R5), [V1.H4]
+	VLD1.P (R2)(R5), [V2.H4]
+	VLD1.P (R2)(R5), [V3.H4]
+	VLD1.P (R2)(R5), [V4.H4]
+	VLD1.P (R2)(R5), [V5.H4]
+	VLD1.P (R2)(R5), [V6.H4]
+	VLD1.P (R2)(R5), [V7.H4]
+	WIDEN8
+	RADIX8
+	NARROW8
+	VST1.P [V0.H4], (R3)(R5)
+	VST1.P [V1.H4], (R3)(R5)
+	VST1.P [V2.H4], (R3)(R5)
+	VST1.P [V3.H4], (R3)(R5)
+	VST1.P [V4.H4], (R3)(R5)
+	VST1.P [V5.H4], (R3)(R5)
+	VST1.P [V6.H4], (R3)(R5)
+	VST1.P [V7.H4], (R3)(R5)
+	SUBS   $1, R4
+	BNE    ntt_block
+
+	ADD  $8, R8
+	ADD  $8, R9
+	SUBS $1, R6
+	BNE  ntt_column
+
+	// Skip the 7 rows of twiddle factors (7 * span entries), and move on to
+	// the next group, unless this one spanned 64.
+	ADD  R5<<3, R1, R1
+	SUB  R5, R1, R1
+	CMP  $128, R5
+	BEQ  ntt_last
+	MOVD $128, R5
+	MOVD $16, R6
+	MOVD $2, R7
+	B    ntt_group
+
+ntt_last:
+	// Butterflies spanning 512, 8 at a time.
+	MOVD R0, R2
+	ADD  $1024, R0, R3
+	MOVD $64, R4
+
+ntt_last_loop:
+	VLD1   (R2), [V0.H8]
+	VLD1   (R3), [V1.H8]
+	VLD1.P 16(R1), [V2.H8]
+	VUXTL  V0.H4, V4.S4
+	VUXTL2 V0.H8, V5.S4
+	VUXTL  V1.H4, V6.S4
+	VUXTL2 V1.H8, V7.S4
+	VUXTL  V2.H4, V16.S4
+	VUXTL2 V2.H8, V17.S4
+	CT(V4, V6, V16, V8)
+	CT(V5, V7, V17, V9)
+	VXTN   V4.S4, V0.H4
+	VXTN2  V5.S4, V0.H8
+	VXTN   V6.S4, V1.H4
+	VXTN2  V7.S4, V1.H8
+	VST1.P [V0.H8], 16(R2)
+	VST1.P [V1.H8], 16(R3)
+	SUBS   $1, R4
+	BNE    ntt_last_loop
+
+	RET
+
+// func invNttNEON(p, tmp *[paramN]uint16, w *[paramN - 1]uint16)
+TEXT ·invNttNEON(SB), NOSPLIT, $0-24
+	MOVD p+0(FP), R0
+	MOVD tmp+8(FP), R1
+	MOVD w+16(FP), R11
+	CONSTANTS(3*PARAM_Q, 5)
+
+	// Butterflies spanning 2 span and span, for span 256, 64, 16 and 4, on
+	// 4 consecutive k at a time, from p to tmp, and then in place.  R5 =
+	// 2 * span in bytes, R6 = number of 4-wide columns (span / 4), R7 =
+	// number of blocks (n / 4 span), R12 = 10 - level.
+	MOVD R0, R13
+	MOVD $512, R5
+	MOVD $1, R7
+	MOVD $1, R12
+
+inv_group:
+	LSR  $3, R5, R6
+	MOVD R13, R8
+	MOVD R1, R9
+	MOVD R11, R10
+
+inv_column:
+	// Load the twiddle factors of this column.
+	MOVD   R10, R14
+	VLD1.P (R14)(R5), [V16.H4]
+	VLD1.P (R14)(R5), [V17.H4]
+	VLD1   (R14), [V18.H4]
+	VUXTL  V16.H4, V16.S4
+	VUXTL  V17.H4, V17.S4
+	VUXTL  V18.H4, V18.S4
+
+	MOVD R8, R2
+	MOVD R9, R3
+	MOVD $0, R4
+
+inv_block:
+	VLD1.P (R2)(R5), [V0.H4]
+	VLD1.P (R2)(R5), [V1.H4]
+	VLD1.P (R2)(R5), [V2.H4]
+	VLD1.P (R2)(R5), [V3.H4]
+	VUXTL  V0.H4, V0.S4
+	VUXTL  V1.H4, V1.S4
+	VUXTL  V2.H4, V2.S4
+	VUXTL  V3.H4, V3.S4
+
+	GS(V0, V2, V16, V8)
+	GS(V1, V3, V17, V9)
+
+	// invNttReduce(level, block), which is true iff the count of levels
+	// since the last reduction (10 - level for block 0, and the number of
+	// trailing zeros of block plus 1 otherwise) is even.
+	RBIT R4, R15
+	CLZ  R15, R15
+	ADD  $1, R15
+	CMP  $0, R4
+	CSEL EQ, R12, R15, R15
+	TBNZ $0, R15, inv_reduce_second
+
+	BARRETT16(V0, V8)
+	BARRETT16(V1, V9)
+	GS(V0, V1, V18, V8)
+	GS(V2, V3, V18, V9)
+	B inv_store
+
+inv_reduce_second:
+	GS(V0, V1, V18, V8)
+	GS(V2, V3, V18, V9)
+	BARRETT16(V0, V8)
+
+inv_store:
+	VXTN   V0.S4, V0.H4
+	VXTN   V1.S4, V1.H4
+	VXTN   V2.S4, V2.H4
+	VXTN   V3.S4, V3.H4
+	VST1.P [V0.H4], (R3)(R5)
+	VST1.P [V1.H4], (R3)(R5)
+	VST1.P [V2.H4], (R3)(R5)
+	VST1.P [V3.H4], (R3)(R5)
+	ADD    $1, R4
+	CMP    R7, R4
+	BNE    inv_block
+
+	ADD  $8, R8
+	ADD  $8, R9
+	ADD  $8, R10
+	SUBS $1, R6
+	BNE  inv_column
+
+	// Skip the 3 rows of twiddle factors (3 * span entries), and move on to
+	// the next group, unless this one spanned 4.
+	ADD  R5<<1, R11, R11
+	ADD  R5, R11, R11
+	MOVD R1, R13
+	ADD  $2, R12
+	CMP  $8, R5
+	BEQ  inv_last
+	LSR  $2, R5
+	LSL  $2, R7
+	B    inv_group
+
+inv_last:
+	// Butterflies spanning 2 and 1, on 4 blocks at a time, with the
+	// scaling by n^-1 and the bit reversal.  The blocks are transposed so
+	// that each register holds the same coefficient of every block.
+	MOVHU 0(R11), R20
+	VDUP  R20, V16.S4
+	MOVHU 2(R11), R20
+	VDUP  R20, V17.S4
+	MOVHU 4(R11), R20
+	VDUP  R20, V18.S4
+	MOVW  $N_INV, R20
+	VDUP  R20, V30.S4
+
+	// Only the third block of every 4 (and the first for some) needs the
+	// first level reduced, per invNttReduce(1, block), so V29 masks the
+	// lanes to be reduced.
+	VMOVI $0, V29.B16
+	MOVW  $-1, R20
+	VMOV  R20, V29.S[2]
+
+	MOVD R1, R2
+	MOVD $0, R4
+
+inv_last_loop:
+	VLD4.P 32(R2), [V0.H4, V1.H4, V2.H4, V3.H4]
+	VUXTL  V0.H4, V0.S4
+	VUXTL  V1.H4, V1.S4
+	VUXTL  V2.H4, V2.S4
+	VUXTL  V3.H4, V3.S4
+
+	GS(V0, V2, V16, V8)
+	GS(V1, V3, V17, V9)
+
+	// Block 4c needs reducing iff c has an odd number of trailing zeros.
+	RBIT R4, R15
+	CLZ  R15, R15
+	AND  $1, R15
+	NEG  R15, R15
+	VMOV R15, V29.S[0]
+
+	VMUL  V27.S4, V0.S4, V8.S4
+	VUSHR $16, V8.S4, V8.S4
+	VMUL  V25.S4, V8.S4, V8.S4
+	VAND  V29.B16, V8.B16, V8.B16
+	VSUB  V8.S4, V0.S4, V0.S4
+	VMUL  V27.S4, V1.S4, V9.S4
+	VUSHR $16, V9.S4, V9.S4
+	VMUL  V25.S4, V9.S4, V9.S4
+	VAND  V29.B16, V9.B16, V9.B16
+	VSUB  V9.S4, V1.S4, V1.S4
+
+	GS(V0, V1, V18, V8)
+	GS(V2, V3, V18, V9)
+	VMUL V30.S4, V0.S4, V8.S4
+	MONT(V8, V0)
+	VMUL V30.S4, V2.S4, V9.S4
+	MONT(V9, V2)
+
+	// Block 4c + l goes to bitrev(16c + 4l) = bitrev6(c) + 64 bitrev2(l).
+	RBIT R4, R14
+	LSR  $58, R14
+	ADD  R14<<1, R0, R14
+
+	VMOV V0.S[0], R15
+	MOVH R15, 0(R14)
+	VMOV V0.S[1], R15
+	MOVH R15, 256(R14)
+	VMOV V0.S[2], R15
+	MOVH R15, 128(R14)
+	VMOV V0.S[3], R15
+	MOVH R15, 384(R14)
+
+	VMOV V1.S[0], R15
+	MOVH R15, 1024(R14)
+	VMOV V1.S[1], R15
+	MOVH R15, 1280(R14)
+	VMOV V1.S[2], R15
+	MOVH R15, 1152(R14)
+	VMOV V1.S[3], R15
+	MOVH R15, 1408(R14)
+
+	VMOV V2.S[0], R15
+	MOVH R15, 512(R14)
+	VMOV V2.S[1], R15
+	MOVH R15, 768(R14)
+	VMOV V2.S[2], R15
+	MOVH R15, 640(R14)
+	VMOV V2.S[3], R15
+	MOVH R15, 896(R14)
+
+	VMOV V3.S[0], R15
+	MOVH R15, 1536(R14)
+	VMOV V3.S[1], R15
+	MOVH R15, 1792(R14)
+	VMOV V3.S[2], R15
+	MOVH R15, 1664(R14)
+	VMOV V3.S[3], R15
+	MOVH R15, 1920(R14)
+
+	ADD $1, R4
+	CMP $64, R4
+	BNE inv_last_loop
+
+	RET
+
+// func pointwiseNEON(p, a, b *[paramN]uint16)
+TEXT ·pointwiseNEON(SB), NOSPLIT, $0-24
+	MOVD p+0(FP), R0
+	MOVD a+8(FP), R1
+	MOVD b+16(FP), R2
+	CONSTANTS(0, 3186)
+	MOVD $128, R4
+
+pointwise_loop:
+	VLD1.P 16(R1), [V0.H8]
+	VLD1.P 16(R2), [V1.H8]
+	VUXTL  V1.H4, V2.S4
+	VUXTL2 V1.H8, V3.S4
+	VMUL   V27.S4, V2.S4, V2.S4
+	VMUL   V27.S4, V3.S4, V3.S4
+	MONT(V2, V4)
+	MONT(V3, V5)
+	VUXTL  V0.H4, V2.S4
+	VUXTL2 V0.H8, V3.S4
+	VMUL   V4.S4, V2.S4, V2.S4
+	VMUL   V5.S4, V3.S4, V3.S4
+	MONT(V2, V4)
+	MONT(V3, V5)
+	VXTN   V4.S4, V6.H4
+	VXTN2  V5.S4, V6.H8
+	VST1.P [V6.H8], 16(R0)
+	SUBS   $1, R4
+	BNE    pointwise_loop
+
+	RET
+
+// func addNEON(p, a, b *[paramN]uint16)
+TEXT ·addNEON(SB), NOSPLIT, $0-24
+	MOVD p+0(FP), R0
+	MOVD a+8(FP), R1
+	MOVD b+16(FP), R2
+	MOVD $32, R4
+
+add_loop:
+	VLD1.P 64(R1), [V0.H8, V1.H8, V2.H8, V3.H8]
+	VLD1.P 64(R2), [V4.H8, V5.H8, V6.H8, V7.H8]
+	VADD   V4.H8, V0.H8, V0.H8
+	VADD   V5.H8, V1.H8, V1.H8
+	VADD   V6.H8, V2.H8, V2.H8
+	VADD   V7.H8, V3.H8, V3.H8
+	VST1.P [V0.H8, V1.H8, V2.H8, V3.H8], 64(R0)
+	SUBS   $1, R4
+	BNE    add_loop
+
+	RET
+
+// func binomialNEON(p *[paramN]uint16, buf *[noiseBytes]byte)
+TEXT ·binomialNEON(SB), NOSPLIT, $0-16
+	MOVD p+0(FP), R0
+	MOVD buf+8(FP), R1
+	MOVW $0xff, R20
+	VDUP R20, V30.H8
+	MOVW $PARAM_Q, R20
+	VDUP R20, V31.H8
+	MOVD $64, R4
+
+	// The pairwise sums of the per-byte population counts are a and b of
+	// each coefficient, as the low and high bytes of 16 bit lanes.
+binomial_loop:
+	VLD1.P 64(R1), [V0.B16, V1.B16, V2.B16, V3.B16]
+	VCNT   V0.B16, V0.B16
+	VCNT   V1.B16, V1.B16
+	VCNT   V2.B16, V2.B16
+	VCNT   V3.B16, V3.B16
+	VADDP  V1.B16, V0.B16, V4.B16
+	VADDP  V3.B16, V2.B16, V5.B16
+	VAND   V30.B16, V4.B16, V6.B16
+	VAND   V30.B16, V5.B16, V7.B16
+	VUSHR  $8, V4.H8, V4.H8
+	VUSHR  $8, V5.H8, V5.H8
+	VADD   V31.H8, V6.H8, V6.H8
+	VADD   V31.H8, V7.H8, V7.H8
+	VSUB   V4.H8, V6.H8, V6.H8
+	VSUB   V5.H8, V7.H8, V7.H8
+	VST1.P [V6.H8, V7.H8], 32(R0)
+	SUBS   $1, R4
+	BNE    binomial_loop
+
+	// Scrub the random bits...
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+	VEOR V4.B16, V4.B16, V4.B16
+	VEOR V5.B16, V5.B16, V5.B16
+	VEOR V6.B16, V6.B16, V6.B16
+	VEOR V7.B16, V7.B16, V7.B16
+	RET
+
+// func compressNEON(r *byte, p *[paramN]uint16)
+TEXT ·compressNEON(SB), NOSPLIT, $0-16
+	MOVD r+0(FP), R0
+	MOVD p+8(FP), R1
+	MOVW $5, R20
+	VDUP R20, V30.H8
+	MOVW $PARAM_Q, R20
+	VDUP R20, V31.H8
+	MOVW $768, R20
+	VDUP R20, V29.S4
+	MOVW $5461, R20
+	VDUP R20, V28.S4
+	MOVW $7, R20
+	VDUP R20, V27.S4
+
+	// Per-lane shifts to pack 8 3-bit values.
+	MOVD $0x0000000300000000, R20
+	VMOV R20, V26.D[0]
+	MOVD $0x0000000900000006, R20
+	VMOV R20, V26.D[1]
+	MOVD $0x0000000f0000000c, R20
+	VMOV R20, V25.D[0]
+	MOVD $0x0000001500000012, R20
+	VMOV R20, V25.D[1]
+	MOVD $128, R4
+
+compress_loop:
+	VLD1.P 16(R1), [V0.H8]
+
+	// coeffFreeze, on 16 bit lanes: barrettReduce, and then the conditional
+	// subtraction of q as min(x, x - q).
+	VUMULL  V30.H4, V0.H4, V1.S4
+	VUMULL2 V30.H8, V0.H8, V2.S4
+	VSHRN   $16, V1.S4, V3.H4
+	VSHRN2  $16, V2.S4, V3.H8
+	VMLS    V31.H8, V3.H8, V0.H8
+	VSUB    V31.H8, V0.H8, V1.H8
+	VUMIN   V1.H8, V0.H8, V0.H8
+
+	// ((t << 3) + q/2) / q = (t + 768) * 5461 >> 23, for t < q.
+	VUXTL  V0.H4, V1.S4
+	VUXTL2 V0.H8, V2.S4
+	VADD   V29.S4, V1.S4, V1.S4
+	VADD   V29.S4, V2.S4, V2.S4
+	VMUL   V28.S4, V1.S4, V1.S4
+	VMUL   V28.S4, V2.S4, V2.S4
+	VUSHR  $23, V1.S4, V1.S4
+	VUSHR  $23, V2.S4, V2.S4
+	VAND   V27.B16, V1.B16, V1.B16
+	VAND   V27.B16, V2.B16, V2.B16
+
+	VUSHL V26.S4, V1.S4, V1.S4
+	VUSHL V25.S4, V2.S4, V2.S4
+	VORR  V2.B16, V1.B16, V1.B16
+	VADDV V1.S4, V1
+	VMOV  V1.S[0], R5
+	MOVH  R5, 0(R0)
+	LSRW  $16, R5
+	MOVB  R5, 2(R0)
+	ADD   $3, R0
+	SUBS  $1, R4
+	BNE   compress_loop
+
+	RET
+
+// func decompressNEON(p *[paramN]uint16, a *byte)
+TEXT ·decompressNEON(SB), NOSPLIT, $0-16
+	MOVD p+0(FP), R0
+	MOVD a+8(FP), R1
+	MOVW $7, R20
+	VDUP R20, V29.S4
+	MOVW $PARAM_Q, R20
+	VDUP R20, V28.S4
+	MOVW $4, R20
+	VDUP R20, V27.S4
+
+	// Per-lane (right) shifts to unpack 8 3-bit values.
+	MOVD $0xfffffffd00000000, R20
+	VMOV R20, V26.D[0]
+	MOVD $0xfffffff7fffffffa, R20
+	VMOV R20, V26.D[1]
+	MOVD $0xfffffff1fffffff4, R20
+	VMOV R20, V25.D[0]
+	MOVD $0xffffffebffffffee, R20
+	VMOV R20, V25.D[1]
+	MOVD $128, R4
+
+decompress_loop:
+	MOVHU (R1), R5
+	MOVBU 2(R1), R6
+	ADD   $3, R1
+	ORR   R6<<16, R5, R5
+	VDUP  R5, V0.S4
+	VUSHL V26.S4, V0.S4, V1.S4
+	VUSHL V25.S4, V0.S4, V2.S4
+	VAND  V29.B16, V1.B16, V1.B16
+	VAND  V29.B16, V2.B16, V2.B16
+	VMUL  V28.S4, V1.S4, V1.S4
+	VMUL  V28.S4, V2.S4, V2.S4
+	VADD  V27.S4, V1.S4, V1.S4
+	VADD  V27.S4, V2.S4, V2.S4
+	VUSHR $3, V1.S4, V1.S4
+	VUSHR $3, V2.S4, V2.S4
+	VXTN  V1.S4, V3.H4
+	VXTN2 V2.S4, V3.H8
+	VST1.P [V3.H8], 16(R0)
+	SUBS  $1, R4
+	BNE   decompress_loop
+
+	RET
@@ -0,0 +1,137 @@
+// poly_arm64_test.go - NewHope ARM64 NEON backend tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build arm64 && !noasm && !newhope_boundcheck
+// +build arm64,!noasm,!newhope_boundcheck
+
+package newhope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+)
+
+// The NEON routines must match the portable ones exactly, not just modulo
+// q, since the output of ntt() and add() feeds further lazily reduced
+// arithmetic, and the noise must be bit-identical.  This runs under
+// qemu-user, eg:
+//
+//	GOARCH=arm64 go test -exec qemu-aarch64 .
+
+func randPoly(t *testing.T, p *poly, max uint16) {
+	var buf [2 * paramN]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+	for i := range p.coeffs {
+		p.coeffs[i] = binary.LittleEndian.Uint16(buf[2*i:]) % max
+	}
+}
+
+func fillPoly(p *poly, v uint16) {
+	for i := range p.coeffs {
+		p.coeffs[i] = v
+	}
+}
+
+func checkPoly(t *testing.T, name string, a, b *poly) {
+	for i := range a.coeffs {
+		if a.coeffs[i] != b.coeffs[i] {
+			t.Fatalf("%s mismatch: a[%d] = %d, expected %d", name, i, a.coeffs[i], b.coeffs[i])
+		}
+	}
+}
+
+// neonNTTMax is the bound on the output of ntt(), for q = 12289.
+const neonNTTMax = 3*12289 + 4
+
+func TestNEON(t *testing.T) {
+	if paramQ != 12289 {
+		t.Skip("the NEON routines are only used for q = 12289")
+	}
+
+	var a, b, x, y poly
+	var buf [noiseBytes]byte
+	var c, d [compressedBytes]byte
+
+	for i := 0; i < 256; i++ {
+		// Random inputs, except for the first few, which are at the
+		// bounds of each routine's input range.
+		switch i {
+		case 0:
+			fillPoly(&a, 1<<14-1)
+			fillPoly(&x, neonNTTMax-1)
+			fillPoly(&y, 1<<14-1)
+			for j := range buf {
+				buf[j] = 0xff
+			}
+		case 1:
+			a.reset()
+			x.reset()
+			y.reset()
+			for j := range buf {
+				buf[j] = 0
+			}
+		case 2:
+			fillPoly(&a, paramQ)
+			fillPoly(&x, 0xffff)
+			fillPoly(&y, 0xffff)
+			for j := range buf {
+				buf[j] = 0x0f
+			}
+		default:
+			randPoly(t, &a, 1<<14)
+			randPoly(t, &x, neonNTTMax)
+			randPoly(t, &y, 1<<14)
+			if _, err := rand.Read(buf[:]); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+		}
+
+		b = a
+		a.ntt()
+		b.nttGeneric()
+		checkPoly(t, "ntt", &a, &b)
+
+		a, b = y, y
+		a.invNtt()
+		b.invNttGeneric()
+		checkPoly(t, "invNtt", &a, &b)
+
+		a.pointwise(&x, &y)
+		b.pointwiseGeneric(&x, &y)
+		checkPoly(t, "pointwise", &a, &b)
+
+		if i != 2 {
+			a.add(&x, &y)
+			b.addGeneric(&x, &y)
+			checkPoly(t, "add", &a, &b)
+		}
+
+		a.binomial(&buf)
+		b.binomialGeneric(&buf)
+		checkPoly(t, "binomial", &a, &b)
+
+		// compress() takes any 16 bit value, and decompress() any
+		// byte string.
+		if i > 2 {
+			randPoly(t, &x, 0xffff)
+		}
+		x.compress(c[:])
+		x.compressGeneric(d[:])
+		if !bytes.Equal(c[:], d[:]) {
+			t.Fatalf("compress mismatch: %x, expected %x", c, d)
+		}
+
+		copy(c[:], buf[:])
+		a.decompress(c[:])
+		b.decompressGeneric(c[:])
+		checkPoly(t, "decompress", &a, &b)
+	}
+}
@@ -0,0 +1,35 @@
+// poly_generic.go - NewHope polynomial arithmetic, portable backend.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build !arm64 || noasm || newhope_boundcheck
+// +build !arm64 noasm newhope_boundcheck
+
+package newhope
+
+func (p *poly) ntt() {
+	p.nttGeneric()
+}
+
+func (p *poly) invNtt() {
+	p.invNttGeneric()
+}
+
+func (p *poly) pointwise(a, b *poly) {
+	p.pointwiseGeneric(a, b)
+}
+
+func (p *poly) add(a, b *poly) {
+	p.addGeneric(a, b)
+}
+
+func (p *poly) compress(r []byte) {
+	p.compressGeneric(r)
+}
+
+func (p *poly) decompress(a []byte) {
+	p.decompressGeneric(a)
+}
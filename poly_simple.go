@@ -7,7 +7,12 @@
 
 package newhope
 
+// coeffFreeze fully reduces x.
 func coeffFreeze(x uint16) uint16 {
+	if !lazyReduction {
+		return uint16(uint32(x) % paramQ)
+	}
+
 	var c int16
 
 	r := barrettReduce(x)
@@ -21,46 +26,65 @@ func coeffFreeze(x uint16) uint16 {
 
 // Computes abs(x-Q/2)
 func flipAbs(x uint16) uint16 {
-	r := int16(coeffFreeze(x))
+	r := int32(coeffFreeze(x))
 	r = r - paramQ/2
-	m := r >> 15
+	m := r >> 31
 	return uint16((r + m) ^ m)
 }
 
-func (p *poly) compress(r []byte) {
-	var t [8]uint32
+// compressedBytes is the length of a compressed polynomial in bytes.
+const compressedBytes = paramN * paramD / 8
 
-	for i, k := 0, 0; i < paramN; i, k = i+8, k+3 {
-		for j := range t {
-			t[j] = uint32(coeffFreeze(p.coeffs[i+j]))
-			t[j] = (((t[j] << 3) + paramQ/2) / paramQ) & 0x7
-		}
+// paramD must be between 3 and 6.
+const (
+	_ = uint(paramD - 3)
+	_ = uint(6 - paramD)
+)
 
-		r[k] = byte(t[0]) | byte(t[1]<<3) | byte(t[2]<<6)
-		r[k+1] = byte(t[2]>>2) | byte(t[3]<<1) | byte(t[4]<<4) | byte(t[5]<<7)
-		r[k+2] = byte(t[5]>>1) | byte(t[6]<<2) | byte(t[7]<<5)
-	}
+func (p *poly) compressGeneric(r []byte) {
+	p.compressBits(r, paramD)
+}
 
-	for i := range t {
-		t[i] = 0
+func (p *poly) decompressGeneric(a []byte) {
+	p.decompressBits(a, paramD)
+}
+
+// compressBits rounds each coefficient of p to a d bit multiple of q/2^d,
+// and packs them least significant bit first, 8 coefficients to every d
+// bytes of r.
+func (p *poly) compressBits(r []byte, d uint) {
+	var t uint64
+
+	_ = r[paramN*d/8-1]
+	for i := 0; i < paramN; i += 8 {
+		t = 0
+		for j := uint(0); j < 8; j++ {
+			c := uint64(coeffFreeze(p.coeffs[i+int(j)]))
+			c = (((c << d) + paramQ/2) / paramQ) & (1<<d - 1)
+			t |= c << (d * j)
+		}
+		for j := uint(0); j < d; j++ {
+			r[j] = byte(t >> (8 * j))
+		}
+		r = r[d:]
 	}
+
+	t = 0
 }
 
-func (p *poly) decompress(a []byte) {
+// decompressBits is the inverse of compressBits, up to the rounding.
+func (p *poly) decompressBits(a []byte, d uint) {
+	_ = a[paramN*d/8-1]
 	for i := 0; i < paramN; i += 8 {
-		a0, a1, a2 := uint16(a[0]), uint16(a[1]), uint16(a[2])
-		p.coeffs[i+0] = a0 & 7
-		p.coeffs[i+1] = (a0 >> 3) & 7
-		p.coeffs[i+2] = (a0 >> 6) | ((a1 << 2) & 4)
-		p.coeffs[i+3] = (a1 >> 1) & 7
-		p.coeffs[i+4] = (a1 >> 4) & 7
-		p.coeffs[i+5] = (a1 >> 7) | ((a2 << 1) & 6)
-		p.coeffs[i+6] = (a2 >> 2) & 7
-		p.coeffs[i+7] = (a2 >> 5)
-		a = a[3:]
-		for j := 0; j < 8; j++ {
-			p.coeffs[i+j] = uint16((uint32(p.coeffs[i+j])*paramQ + 4) >> 3)
+		var t uint64
+		for j := uint(0); j < d; j++ {
+			t |= uint64(a[j]) << (8 * j)
 		}
+		for j := uint(0); j < 8; j++ {
+			c := uint32(t>>(d*j)) & (1<<d - 1)
+			p.coeffs[i+int(j)] = uint16((c*paramQ + 1<<(d-1)) >> d)
+		}
+		a = a[d:]
 	}
 }
 
@@ -80,20 +104,27 @@ func (p *poly) toMsg(msg []byte) {
 	memwipe(msg[0:32])
 
 	for i := uint(0); i < 256; i++ {
-		t := flipAbs(p.coeffs[i+0])
-		t += flipAbs(p.coeffs[i+256])
-		t += flipAbs(p.coeffs[i+512])
-		t += flipAbs(p.coeffs[i+768])
+		t := uint32(flipAbs(p.coeffs[i+0]))
+		t += uint32(flipAbs(p.coeffs[i+256]))
+		t += uint32(flipAbs(p.coeffs[i+512]))
+		t += uint32(flipAbs(p.coeffs[i+768]))
 
 		//t = (~(t - PARAM_Q));
 		t = (t - paramQ)
-		t >>= 15
+		t >>= 31
 		msg[i>>3] |= byte(t << (i & 7))
 	}
 }
 
 func (p *poly) sub(a, b *poly) {
+	if !lazyReduction {
+		for i := range p.coeffs {
+			p.coeffs[i] = uint16((uint32(a.coeffs[i]) + paramQ - uint32(b.coeffs[i])%paramQ) % paramQ)
+		}
+		return
+	}
+
 	for i := range p.coeffs {
-		p.coeffs[i] = barrettReduce(a.coeffs[i] + 3*paramQ - b.coeffs[i])
+		p.coeffs[i] = barrettReduce(checkedUint16(uint32(a.coeffs[i]) + 3*paramQ - uint32(b.coeffs[i])))
 	}
 }
@@ -0,0 +1,251 @@
+// pool.go - NewHope concurrent batch operations.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Pool generates key pairs and responses concurrently on a fixed set of
+// worker goroutines.  Each worker owns its scratch polynomials and its
+// SHAKE-128/ChaCha20 instances, and reuses them for every operation it
+// performs.  Work is queued on a bounded channel, so batch submission
+// blocks (providing backpressure) when the workers fall behind.
+//
+// All methods are safe for concurrent use.
+type Pool struct {
+	l      sync.RWMutex
+	jobs   chan func(*scratch)
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewPool creates a Pool with the given number of workers and queue depth.
+// If workers is not positive, runtime.GOMAXPROCS(0) workers are used, and
+// if queueDepth is not positive, it defaults to twice the number of
+// workers.
+func NewPool(workers, queueDepth int) *Pool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if queueDepth <= 0 {
+		queueDepth = 2 * workers
+	}
+
+	p := &Pool{
+		jobs: make(chan func(*scratch), queueDepth),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+
+	return p
+}
+
+// Close stops the workers once the queued work is done.  Calling it again
+// has no effect.
+func (p *Pool) Close() {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+
+	var s scratch
+	defer s.release()
+	for job := range p.jobs {
+		job(&s)
+		s.reset()
+	}
+}
+
+// run calls fn(s, i) for i in [0, n) on the workers, and waits for all of
+// them to complete.  It returns the first error encountered, after which
+// the remaining queued calls are skipped.
+func (p *Pool) run(ctx context.Context, n int, fn func(ctx context.Context, s *scratch, i int) error) error {
+	p.l.RLock()
+	defer p.l.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		errOnce   sync.Once
+		firstErr  error
+		completed uint32
+	)
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+submitLoop:
+	for i := 0; i < n; i++ {
+		i := i
+		job := func(s *scratch) {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			if err := fn(ctx, s, i); err != nil {
+				setErr(err)
+				return
+			}
+			atomic.AddUint32(&completed, 1)
+		}
+
+		wg.Add(1)
+		select {
+		case p.jobs <- job:
+		case <-ctx.Done():
+			wg.Done()
+			break submitLoop
+		}
+	}
+	wg.Wait()
+
+	if firstErr == nil && int(completed) != n {
+		// The caller's context was canceled before every job ran.
+		firstErr = parent.Err()
+	}
+
+	return firstErr
+}
+
+// GenerateKeyPairsAlice is the batch equivalent of
+// GenerateKeyPairAliceOptions, with every key pair bound to the given
+// options, which may be nil.  The reader is serialized internally, so it
+// need not be safe for concurrent use.  On failure, all of the private keys
+// that were generated are obliterated.
+func (p *Pool) GenerateKeyPairsAlice(ctx context.Context, rand io.Reader, n int, opts *KeyOptions) ([]*PrivateKeyAlice, []*PublicKeyAlice, error) {
+	privKeys := make([]*PrivateKeyAlice, n)
+	pubKeys := make([]*PublicKeyAlice, n)
+
+	torSampling, rc := TorSampling, opts.reconciler()
+	rand = &lockedReader{r: rand}
+	err := p.run(ctx, n, func(ctx context.Context, s *scratch, i int) (err error) {
+		privKeys[i], pubKeys[i], err = s.generateKeyPairAlice(ctx, rand, torSampling, rc)
+		return
+	})
+	if err != nil {
+		for _, k := range privKeys {
+			if k != nil {
+				k.Reset()
+			}
+		}
+		return nil, nil, err
+	}
+
+	return privKeys, pubKeys, nil
+}
+
+// KeyExchangesBob is the batch equivalent of KeyExchangeBobContext,
+// responding to each of Alice's public keys in turn.  The reader is
+// serialized internally, so it need not be safe for concurrent use.  On
+// failure, all of the shared secrets that were derived are obliterated.
+func (p *Pool) KeyExchangesBob(ctx context.Context, rand io.Reader, alicePks []*PublicKeyAlice) ([]*PublicKeyBob, [][]byte, error) {
+	pubKeys := make([]*PublicKeyBob, len(alicePks))
+	sharedSecrets := make([][]byte, len(alicePks))
+
+	torSampling := TorSampling
+	rand = &lockedReader{r: rand}
+	err := p.run(ctx, len(alicePks), func(ctx context.Context, s *scratch, i int) (err error) {
+		pubKeys[i], sharedSecrets[i], err = s.keyExchangeBob(ctx, rand, alicePks[i], torSampling)
+		return
+	})
+	if err != nil {
+		for _, b := range sharedSecrets {
+			memwipe(b)
+		}
+		return nil, nil, err
+	}
+
+	return pubKeys, sharedSecrets, nil
+}
+
+// GenerateKeyPairsSimpleAlice is the batch equivalent of
+// GenerateKeyPairSimpleAliceOptions, and behaves as GenerateKeyPairsAlice.
+func (p *Pool) GenerateKeyPairsSimpleAlice(ctx context.Context, rand io.Reader, n int, opts *KeyOptions) ([]*PrivateKeySimpleAlice, []*PublicKeySimpleAlice, error) {
+	privKeys := make([]*PrivateKeySimpleAlice, n)
+	pubKeys := make([]*PublicKeySimpleAlice, n)
+
+	torSampling, enc := TorSampling, opts.encoding()
+	rand = &lockedReader{r: rand}
+	err := p.run(ctx, n, func(ctx context.Context, s *scratch, i int) (err error) {
+		privKeys[i], pubKeys[i], err = s.generateKeyPairSimpleAlice(ctx, rand, torSampling, enc)
+		return
+	})
+	if err != nil {
+		for _, k := range privKeys {
+			if k != nil {
+				k.Reset()
+			}
+		}
+		return nil, nil, err
+	}
+
+	return privKeys, pubKeys, nil
+}
+
+// KeyExchangesSimpleBob is the batch equivalent of
+// KeyExchangeSimpleBobContext, and behaves as KeyExchangesBob.
+func (p *Pool) KeyExchangesSimpleBob(ctx context.Context, rand io.Reader, alicePks []*PublicKeySimpleAlice) ([]*PublicKeySimpleBob, [][]byte, error) {
+	pubKeys := make([]*PublicKeySimpleBob, len(alicePks))
+	sharedSecrets := make([][]byte, len(alicePks))
+
+	torSampling := TorSampling
+	rand = &lockedReader{r: rand}
+	err := p.run(ctx, len(alicePks), func(ctx context.Context, s *scratch, i int) (err error) {
+		if alicePks[i] == nil {
+			return ErrInvalidPublicKey
+		}
+		pubKeys[i], sharedSecrets[i], err = s.keyExchangeSimpleBob(ctx, rand, alicePks[i], torSampling, alicePks[i].Encoding)
+		return
+	})
+	if err != nil {
+		for _, b := range sharedSecrets {
+			memwipe(b)
+		}
+		return nil, nil, err
+	}
+
+	return pubKeys, sharedSecrets, nil
+}
+
+// lockedReader serializes reads from a reader that may not be safe for
+// concurrent use.
+type lockedReader struct {
+	sync.Mutex
+	r io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	return l.r.Read(p)
+}
@@ -0,0 +1,85 @@
+// pool_test.go - NewHope concurrent batch operation tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	const n = 64
+
+	TorSampling = false
+	p := NewPool(4, 2)
+	defer p.Close()
+	ctx := context.Background()
+
+	alicePrivs, alicePubs, err := p.GenerateKeyPairsAlice(ctx, rand.Reader, n, nil)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairsAlice failed: %v", err)
+	}
+	bobPubs, bobShareds, err := p.KeyExchangesBob(ctx, rand.Reader, alicePubs)
+	if err != nil {
+		t.Fatalf("KeyExchangesBob failed: %v", err)
+	}
+	for i := range alicePrivs {
+		aliceShared, err := KeyExchangeAlice(bobPubs[i], alicePrivs[i])
+		if err != nil {
+			t.Fatalf("KeyExchangeAlice failed: %v", err)
+		}
+		if !bytes.Equal(aliceShared, bobShareds[i]) {
+			t.Fatalf("shared secrets mismatched (%d)", i)
+		}
+	}
+
+	aliceSimplePrivs, aliceSimplePubs, err := p.GenerateKeyPairsSimpleAlice(ctx, rand.Reader, n, nil)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairsSimpleAlice failed: %v", err)
+	}
+	bobSimplePubs, bobSimpleShareds, err := p.KeyExchangesSimpleBob(ctx, rand.Reader, aliceSimplePubs)
+	if err != nil {
+		t.Fatalf("KeyExchangesSimpleBob failed: %v", err)
+	}
+	for i := range aliceSimplePrivs {
+		aliceShared, err := KeyExchangeSimpleAlice(bobSimplePubs[i], aliceSimplePrivs[i])
+		if err != nil {
+			t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+		}
+		if !bytes.Equal(aliceShared, bobSimpleShareds[i]) {
+			t.Fatalf("simple shared secrets mismatched (%d)", i)
+		}
+	}
+
+	// Canceled before submission.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err = p.GenerateKeyPairsAlice(canceled, rand.Reader, n, nil); err != context.Canceled {
+		t.Fatalf("GenerateKeyPairsAlice with canceled context: %v", err)
+	}
+
+	// Canceled while the workers are reading from a slow reader.
+	r := new(slowReader)
+	blocked, cancel := context.WithCancel(ctx)
+	errCh := make(chan error)
+	go func() {
+		_, _, err := p.GenerateKeyPairsSimpleAlice(blocked, r, n, nil)
+		errCh <- err
+	}()
+	cancel()
+	if err = <-errCh; err != context.Canceled {
+		t.Fatalf("GenerateKeyPairsSimpleAlice with slow reader: %v", err)
+	}
+
+	p.Close()
+	if _, _, err = p.GenerateKeyPairsAlice(ctx, rand.Reader, 1, nil); err != ErrPoolClosed {
+		t.Fatalf("GenerateKeyPairsAlice on closed pool: %v", err)
+	}
+}
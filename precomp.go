@@ -5,283 +5,285 @@
 // Commons "CC0" public domain dedication. See LICENSE or
 // <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
 
+// Code generated by internal/gentables. DO NOT EDIT.
+
 package newhope
 
-var omegasMontgomery = [paramN / 2]uint16{
-	4075, 6974, 7373, 7965, 3262, 5079, 522, 2169, 6364, 1018, 1041, 8775, 2344,
-	11011, 5574, 1973, 4536, 1050, 6844, 3860, 3818, 6118, 2683, 1190, 4789,
-	7822, 7540, 6752, 5456, 4449, 3789, 12142, 11973, 382, 3988, 468, 6843,
-	5339, 6196, 3710, 11316, 1254, 5435, 10930, 3998, 10256, 10367, 3879,
-	11889, 1728, 6137, 4948, 5862, 6136, 3643, 6874, 8724, 654, 10302, 1702,
-	7083, 6760, 56, 3199, 9987, 605, 11785, 8076, 5594, 9260, 6403, 4782,
-	6212, 4624, 9026, 8689, 4080, 11868, 6221, 3602, 975, 8077, 8851, 9445,
-	5681, 3477, 1105, 142, 241, 12231, 1003, 3532, 5009, 1956, 6008, 11404,
-	7377, 2049, 10968, 12097, 7591, 5057, 3445, 4780, 2920, 7048, 3127,
-	8120, 11279, 6821, 11502, 8807, 12138, 2127, 2839, 3957, 431, 1579,
-	6383, 9784, 5874, 677, 3336, 6234, 2766, 1323, 9115, 12237, 2031, 6956,
-	6413, 2281, 3969, 3991, 12133, 9522, 4737, 10996, 4774, 5429, 11871,
-	3772, 453, 5908, 2882, 1805, 2051, 1954, 11713, 3963, 2447, 6142, 8174,
-	3030, 1843, 2361, 12071, 2908, 3529, 3434, 3202, 7796, 2057, 5369,
-	11939, 1512, 6906, 10474, 11026, 49, 10806, 5915, 1489, 9789, 5942,
-	10706, 10431, 7535, 426, 8974, 3757, 10314, 9364, 347, 5868, 9551, 9634,
-	6554, 10596, 9280, 11566, 174, 2948, 2503, 6507, 10723, 11606, 2459, 64,
-	3656, 8455, 5257, 5919, 7856, 1747, 9166, 5486, 9235, 6065, 835, 3570,
-	4240, 11580, 4046, 10970, 9139, 1058, 8210, 11848, 922, 7967, 1958,
-	10211, 1112, 3728, 4049, 11130, 5990, 1404, 325, 948, 11143, 6190, 295,
-	11637, 5766, 8212, 8273, 2919, 8527, 6119, 6992, 8333, 1360, 2555, 6167,
-	1200, 7105, 7991, 3329, 9597, 12121, 5106, 5961, 10695, 10327, 3051,
-	9923, 4896, 9326, 81, 3091, 1000, 7969, 4611, 726, 1853, 12149, 4255,
-	11112, 2768, 10654, 1062, 2294, 3553, 4805, 2747, 4846, 8577, 9154,
-	1170, 2319, 790, 11334, 9275, 9088, 1326, 5086, 9094, 6429, 11077,
-	10643, 3504, 3542, 8668, 9744, 1479, 1, 8246, 7143, 11567, 10984, 4134,
-	5736, 4978, 10938, 5777, 8961, 4591, 5728, 6461, 5023, 9650, 7468, 949,
-	9664, 2975, 11726, 2744, 9283, 10092, 5067, 12171, 2476, 3748, 11336,
-	6522, 827, 9452, 5374, 12159, 7935, 3296, 3949, 9893, 4452, 10908, 2525,
-	3584, 8112, 8011, 10616, 4989, 6958, 11809, 9447, 12280, 1022, 11950,
-	9821, 11745, 5791, 5092, 2089, 9005, 2881, 3289, 2013, 9048, 729, 7901,
-	1260, 5755, 4632, 11955, 2426, 10593, 1428, 4890, 5911, 3932, 9558,
-	8830, 3637, 5542, 145, 5179, 8595, 3707, 10530, 355, 3382, 4231, 9741,
-	1207, 9041, 7012, 1168, 10146, 11224, 4645, 11885, 10911, 10377, 435,
-	7952, 4096, 493, 9908, 6845, 6039, 2422, 2187, 9723, 8643, 9852, 9302,
-	6022, 7278, 1002, 4284, 5088, 1607, 7313, 875, 8509, 9430, 1045, 2481,
-	5012, 7428, 354, 6591, 9377, 11847, 2401, 1067, 7188, 11516, 390, 8511,
-	8456, 7270, 545, 8585, 9611, 12047, 1537, 4143, 4714, 4885, 1017, 5084,
-	1632, 3066, 27, 1440, 8526, 9273, 12046, 11618, 9289, 3400, 9890, 3136,
-	7098, 8758, 11813, 7384, 3985, 11869, 6730, 10745, 10111, 2249, 4048,
-	2884, 11136, 2126, 1630, 9103, 5407, 2686, 9042, 2969, 8311, 9424, 9919,
-	8779, 5332, 10626, 1777, 4654, 10863, 7351, 3636, 9585, 5291, 8374,
-	2166, 4919, 12176, 9140, 12129, 7852, 12286, 4895, 10805, 2780, 5195,
-	2305, 7247, 9644, 4053, 10600, 3364, 3271, 4057, 4414, 9442, 7917, 2174,
-}
+// The tables are for n = 1024, q = 12289 and the Montgomery radix 2^18, and hold
+// powers of psi = 7, the smallest primitive 2n-th root of unity mod q, in
+// Montgomery form.
+
+// precompN is the ring dimension the tables are for, which must be paramN.
+const precompN = 1024
+
+// precompQ is the modulus the tables are for, which must be paramQ.
+const precompQ = 12289
+
+// precompRLog is log2 of the Montgomery radix the tables are for, which
+// must be rlog.
+const precompRLog = 18
+
+// nInvMontgomery is n^-1, in Montgomery form.
+const nInvMontgomery = 256
 
-var omegasInvMontgomery = [paramN / 2]uint16{
-	4075, 5315, 4324, 4916, 10120, 11767, 7210, 9027, 10316, 6715, 1278, 9945,
-	3514, 11248, 11271, 5925, 147, 8500, 7840, 6833, 5537, 4749, 4467, 7500,
-	11099, 9606, 6171, 8471, 8429, 5445, 11239, 7753, 9090, 12233, 5529,
-	5206, 10587, 1987, 11635, 3565, 5415, 8646, 6153, 6427, 7341, 6152,
-	10561, 400, 8410, 1922, 2033, 8291, 1359, 6854, 11035, 973, 8579, 6093,
-	6950, 5446, 11821, 8301, 11907, 316, 52, 3174, 10966, 9523, 6055, 8953,
-	11612, 6415, 2505, 5906, 10710, 11858, 8332, 9450, 10162, 151, 3482,
-	787, 5468, 1010, 4169, 9162, 5241, 9369, 7509, 8844, 7232, 4698, 192,
-	1321, 10240, 4912, 885, 6281, 10333, 7280, 8757, 11286, 58, 12048,
-	12147, 11184, 8812, 6608, 2844, 3438, 4212, 11314, 8687, 6068, 421,
-	8209, 3600, 3263, 7665, 6077, 7507, 5886, 3029, 6695, 4213, 504, 11684,
-	2302, 1962, 1594, 6328, 7183, 168, 2692, 8960, 4298, 5184, 11089, 6122,
-	9734, 10929, 3956, 5297, 6170, 3762, 9370, 4016, 4077, 6523, 652, 11994,
-	6099, 1146, 11341, 11964, 10885, 6299, 1159, 8240, 8561, 11177, 2078,
-	10331, 4322, 11367, 441, 4079, 11231, 3150, 1319, 8243, 709, 8049, 8719,
-	11454, 6224, 3054, 6803, 3123, 10542, 4433, 6370, 7032, 3834, 8633,
-	12225, 9830, 683, 1566, 5782, 9786, 9341, 12115, 723, 3009, 1693, 5735,
-	2655, 2738, 6421, 11942, 2925, 1975, 8532, 3315, 11863, 4754, 1858,
-	1583, 6347, 2500, 10800, 6374, 1483, 12240, 1263, 1815, 5383, 10777,
-	350, 6920, 10232, 4493, 9087, 8855, 8760, 9381, 218, 9928, 10446, 9259,
-	4115, 6147, 9842, 8326, 576, 10335, 10238, 10484, 9407, 6381, 11836,
-	8517, 418, 6860, 7515, 1293, 7552, 2767, 156, 8298, 8320, 10008, 5876,
-	5333, 10258, 10115, 4372, 2847, 7875, 8232, 9018, 8925, 1689, 8236,
-	2645, 5042, 9984, 7094, 9509, 1484, 7394, 3, 4437, 160, 3149, 113, 7370,
-	10123, 3915, 6998, 2704, 8653, 4938, 1426, 7635, 10512, 1663, 6957,
-	3510, 2370, 2865, 3978, 9320, 3247, 9603, 6882, 3186, 10659, 10163,
-	1153, 9405, 8241, 10040, 2178, 1544, 5559, 420, 8304, 4905, 476, 3531,
-	5191, 9153, 2399, 8889, 3000, 671, 243, 3016, 3763, 10849, 12262, 9223,
-	10657, 7205, 11272, 7404, 7575, 8146, 10752, 242, 2678, 3704, 11744,
-	5019, 3833, 3778, 11899, 773, 5101, 11222, 9888, 442, 2912, 5698, 11935,
-	4861, 7277, 9808, 11244, 2859, 3780, 11414, 4976, 10682, 7201, 8005,
-	11287, 5011, 6267, 2987, 2437, 3646, 2566, 10102, 9867, 6250, 5444,
-	2381, 11796, 8193, 4337, 11854, 1912, 1378, 404, 7644, 1065, 2143,
-	11121, 5277, 3248, 11082, 2548, 8058, 8907, 11934, 1759, 8582, 3694,
-	7110, 12144, 6747, 8652, 3459, 2731, 8357, 6378, 7399, 10861, 1696,
-	9863, 334, 7657, 6534, 11029, 4388, 11560, 3241, 10276, 9000, 9408,
-	3284, 10200, 7197, 6498, 544, 2468, 339, 11267, 9, 2842, 480, 5331,
-	7300, 1673, 4278, 4177, 8705, 9764, 1381, 7837, 2396, 8340, 8993, 4354,
-	130, 6915, 2837, 11462, 5767, 953, 8541, 9813, 118, 7222, 2197, 3006,
-	9545, 563, 9314, 2625, 11340, 4821, 2639, 7266, 5828, 6561, 7698, 3328,
-	6512, 1351, 7311, 6553, 8155, 1305, 722, 5146, 4043, 12288, 10810, 2545,
-	3621, 8747, 8785, 1646, 1212, 5860, 3195, 7203, 10963, 3201, 3014, 955,
-	11499, 9970, 11119, 3135, 3712, 7443, 9542, 7484, 8736, 9995, 11227,
-	1635, 9521, 1177, 8034, 140, 10436, 11563, 7678, 4320, 11289, 9198,
-	12208, 2963, 7393, 2366, 9238,
+// nttTwiddlesMontgomery are the twiddle factors of poly.ntt().
+var nttTwiddlesMontgomery = [paramN - 1]uint16{
+	6974, 7373, 7965, 3262, 522, 5079, 2169, 6364, 2344, 1041, 5574, 1018,
+	11011, 8775, 1973, 4536, 4789, 3818, 5456, 6844, 7540, 2683, 3789, 1050,
+	7822, 6118, 4449, 3860, 6752, 1190, 12142, 11973, 11889, 11316, 8724,
+	6843, 5862, 3998, 7083, 3988, 6137, 5435, 10302, 6196, 3643, 10367, 56,
+	382, 1728, 1254, 654, 5339, 6136, 10256, 6760, 468, 4948, 10930, 1702,
+	3710, 6874, 3879, 3199, 9987, 7377, 975, 12138, 6212, 2920, 241, 5874,
+	5594, 7591, 5681, 431, 4080, 11279, 5009, 2766, 11785, 10968, 8851,
+	2839, 9026, 3127, 1003, 3336, 6403, 3445, 1105, 6383, 6221, 11502, 6008,
+	9115, 605, 2049, 8077, 2127, 4624, 7048, 12231, 677, 9260, 5057, 3477,
+	1579, 11868, 6821, 1956, 1323, 8076, 12097, 9445, 3957, 8689, 8120,
+	3532, 6234, 4782, 4780, 142, 9784, 3602, 8807, 11404, 12237, 2031, 2948,
+	3202, 3728, 2882, 6065, 10431, 6119, 4737, 8455, 11026, 6190, 8174,
+	1058, 5868, 7991, 3969, 11606, 11939, 1404, 11713, 11580, 3757, 2555,
+	11871, 1747, 1489, 8212, 12071, 7967, 10596, 5106, 6413, 6507, 2057,
+	11130, 2051, 3570, 426, 8333, 4774, 5919, 10806, 11637, 1843, 11848,
+	9634, 9597, 12133, 64, 6906, 948, 2447, 10970, 9364, 1200, 453, 5486,
+	5942, 2919, 3529, 10211, 11566, 10695, 6956, 2503, 7796, 4049, 1805,
+	835, 7535, 6992, 10996, 5257, 49, 295, 3030, 8210, 9551, 3329, 3991,
+	2459, 1512, 325, 3963, 4046, 10314, 6167, 3772, 9166, 9789, 8273, 2908,
+	1958, 9280, 5961, 2281, 10723, 5369, 5990, 1954, 4240, 8974, 1360, 5429,
+	7856, 5915, 5766, 2361, 922, 6554, 12121, 9522, 3656, 10474, 11143,
+	6142, 9139, 347, 7105, 5908, 9235, 10706, 8527, 3434, 1112, 174, 10327,
+	3051, 1207, 10092, 9273, 9094, 9430, 5092, 10626, 1062, 6039, 10908,
+	2249, 4978, 7270, 4890, 4895, 4611, 10911, 9452, 8758, 1479, 11847,
+	7901, 8374, 1170, 7278, 11809, 2686, 9650, 4885, 5179, 10600, 81, 10146,
+	3748, 3400, 3504, 7428, 3289, 7351, 2747, 8643, 8011, 2126, 4591, 12047,
+	8830, 2305, 4255, 4096, 3296, 11869, 11567, 11516, 11955, 9140, 9275,
+	1607, 11950, 9424, 2975, 3066, 355, 4414, 4896, 7012, 12171, 11618,
+	11077, 2481, 9005, 4654, 3553, 2187, 3584, 2884, 5777, 8585, 3932, 2780,
+	1853, 435, 12159, 7384, 8246, 1067, 5755, 4919, 790, 4284, 12280, 2969,
+	949, 5084, 3707, 3271, 1000, 4645, 6522, 3136, 8668, 6591, 9048, 9585,
+	8577, 9302, 4989, 9103, 6461, 4143, 5542, 9644, 2768, 9908, 9893, 10745,
+	4134, 8511, 10593, 7852, 1326, 875, 11745, 8779, 2744, 1440, 4231, 7917,
+	9923, 9041, 5067, 12046, 6429, 1045, 2089, 1777, 2294, 2422, 2525, 4048,
+	10938, 545, 5911, 10805, 726, 10377, 5374, 11813, 1, 2401, 1260, 2166,
+	2319, 1002, 9447, 9042, 7468, 1017, 8595, 3364, 3091, 11224, 11336,
+	9890, 3542, 354, 2013, 3636, 4846, 9852, 10616, 1630, 5728, 1537, 3637,
+	7247, 11112, 493, 3949, 6730, 10984, 390, 2426, 12129, 9088, 7313, 9821,
+	9919, 11726, 27, 3382, 9442, 9326, 1168, 2476, 9289, 10643, 5012, 2881,
+	10863, 4805, 9723, 8112, 11136, 8961, 9611, 9558, 5195, 12149, 7952,
+	7935, 3985, 7143, 7188, 4632, 12176, 11334, 5088, 1022, 8311, 9664,
+	1632, 10530, 4057, 7969, 11885, 827, 7098, 9744, 9377, 729, 5291, 9154,
+	6022, 6958, 5407, 5023, 4714, 145, 4053, 10654, 6845, 4452, 10111, 5736,
+	8456, 1428, 12286, 5086, 8509, 5791, 5332, 9283, 8526, 9741, 2174, 3947,
+	9068, 1928, 8449, 8464, 9199, 8347, 3466, 10077, 2213, 10125, 4565,
+	2483, 11066, 1518, 648, 7174, 7434, 7885, 5406, 6825, 2622, 5588, 3454,
+	9489, 10268, 11572, 1734, 11232, 9652, 5966, 9687, 7681, 7699, 8581,
+	2643, 6617, 4719, 10029, 12150, 5478, 10353, 3448, 9195, 8151, 6151,
+	6463, 9462, 8945, 8190, 8062, 1790, 1687, 8929, 7406, 6513, 11912, 6105,
+	4209, 9617, 4251, 11675, 6781, 466, 10545, 567, 3205, 9577, 2291, 1658,
+	7508, 11511, 11034, 12239, 9839, 2840, 3981, 10734, 9828, 2301, 2148,
+	6940, 8257, 11345, 2900, 6921, 7326, 2593, 4167, 7559, 1721, 10595,
+	3017, 365, 5596, 3846, 4119, 5207, 9363, 4094, 3982, 10783, 12229, 9349,
+	3408, 7235, 10423, 6878, 5219, 9951, 8328, 2535, 1325, 3480, 10763,
+	11249, 10485, 9916, 6613, 4523, 425, 8536, 438, 9173, 7073, 2485, 11164,
+	6320, 2455, 9694, 8024, 12217, 8761, 11463, 8682, 7592, 3338, 3805,
+	2110, 5078, 3042, 1590, 4176, 8000, 11041, 293, 2068, 3020, 512, 510,
+	412, 7899, 6092, 3572, 2982, 10939, 7584, 2946, 9175, 7171, 7287, 682,
+	8840, 3045, 1737, 11379, 4566, 2532, 1178, 8566, 1908, 7469, 9600, 3418,
+	7725, 9855, 3624, 5530, 612, 5410, 7021, 12226, 9202, 8494, 10669, 6643,
+	5993, 11010, 11063, 1371, 5734, 10608, 3654, 7000, 11197, 7937, 7954,
+	8787, 448, 9663, 6505, 11520, 11475, 9270, 11826, 1891, 6636, 5650,
+	6492, 10883, 4840, 3669, 7735, 10345, 3056, 2276, 923, 8360, 4103, 4423,
+	7814, 1927, 8400, 6063, 2151, 7087, 3171, 7911, 6680, 7806, 1535, 1481,
+	11124, 4360, 4727, 10421, 6780, 417, 8144, 5808, 1945, 9282, 125, 6125,
+	5189, 8481, 10032, 8, 392, 6919, 7228, 10080, 2360, 5039, 1131, 6263,
+	11951, 8016, 11825, 1842, 4235, 10891, 5232, 10588, 2674, 8136, 5416,
+	7315, 2054, 2334, 3765, 150, 7350, 3769, 346, 4665, 7383, 5386, 5845,
+	3758, 12096, 2832, 3589, 3815, 2600, 4510, 12077, 1901, 7126, 5082,
+	3238, 11194, 7790, 751, 12221, 8957, 8778, 7, 343, 4518, 180, 8820,
+	2065, 2873, 5598, 3944, 8921, 7014, 11883, 4684, 8314, 1849, 4578, 3120,
+	5412, 7119, 4739, 11009, 11014, 11259, 10975, 9348, 3359, 4834, 3375,
+	5618, 4924, 7785, 506, 216, 10584, 2478, 10821, 1802, 2275, 874, 5959,
+	9344, 3163, 7519, 12050, 578, 3744, 11410, 6085, 3229, 10753, 10759,
+	11053, 881, 6302, 1573, 3343, 4050, 1826, 3451, 9342, 3065, 2717, 10243,
+	10347, 3154, 7078, 2730, 10880, 4693, 8755, 11169, 6565, 2171, 8067,
+	2035, 1403, 7302, 1417, 7988, 10453, 8348, 3515, 189, 9261, 11385, 4860,
+	4649, 6599, 3837, 3678, 8176, 7376, 5043, 1327, 3578, 3276, 767, 716,
+	10506, 10945, 7878, 5063, 2307, 2442, 9057, 1389, 6616, 4670, 7628,
+	5102, 4218, 10058, 1282, 1373, 5832, 3121, 5461, 9520, 11787, 12269,
+	11309, 1136, 6508, 11667, 6389, 5836, 3317, 2776, 845, 4538, 1160, 7684,
+	7846, 3495, 11498, 10397, 5604, 4238, 11038, 146, 7154, 6454, 9021,
+	11914, 6203, 9011, 11424, 6771, 12265, 11113, 3821, 2894, 6627, 5209,
+	9461, 8896, 5789, 1014, 530, 1392, 6763, 11873, 4194, 8882, 5103, 4267,
+	170, 8330, 2633, 6127, 5287, 994, 11839, 2528, 982, 11251, 10583, 2429,
+	8420, 7043, 1015, 579, 3793, 1522, 844, 4489, 11048, 636, 6586, 3200,
+	9332, 2575, 3285, 1208, 10036, 204, 9996, 10533, 12268, 11260, 11024,
+	11749, 10407, 6094, 3670, 7784, 457, 10104, 3536, 1218, 10526, 11925,
+	6742, 10844, 2929,
 }
 
-var psisBitrevMontgomery = [paramN]uint16{
-	4075, 6974, 7373, 7965, 3262, 5079, 522, 2169, 6364, 1018, 1041, 8775, 2344,
-	11011, 5574, 1973, 4536, 1050, 6844, 3860, 3818, 6118, 2683, 1190, 4789,
-	7822, 7540, 6752, 5456, 4449, 3789, 12142, 11973, 382, 3988, 468, 6843,
-	5339, 6196, 3710, 11316, 1254, 5435, 10930, 3998, 10256, 10367, 3879,
-	11889, 1728, 6137, 4948, 5862, 6136, 3643, 6874, 8724, 654, 10302, 1702,
-	7083, 6760, 56, 3199, 9987, 605, 11785, 8076, 5594, 9260, 6403, 4782,
-	6212, 4624, 9026, 8689, 4080, 11868, 6221, 3602, 975, 8077, 8851, 9445,
-	5681, 3477, 1105, 142, 241, 12231, 1003, 3532, 5009, 1956, 6008, 11404,
-	7377, 2049, 10968, 12097, 7591, 5057, 3445, 4780, 2920, 7048, 3127,
-	8120, 11279, 6821, 11502, 8807, 12138, 2127, 2839, 3957, 431, 1579,
-	6383, 9784, 5874, 677, 3336, 6234, 2766, 1323, 9115, 12237, 2031, 6956,
-	6413, 2281, 3969, 3991, 12133, 9522, 4737, 10996, 4774, 5429, 11871,
-	3772, 453, 5908, 2882, 1805, 2051, 1954, 11713, 3963, 2447, 6142, 8174,
-	3030, 1843, 2361, 12071, 2908, 3529, 3434, 3202, 7796, 2057, 5369,
-	11939, 1512, 6906, 10474, 11026, 49, 10806, 5915, 1489, 9789, 5942,
-	10706, 10431, 7535, 426, 8974, 3757, 10314, 9364, 347, 5868, 9551, 9634,
-	6554, 10596, 9280, 11566, 174, 2948, 2503, 6507, 10723, 11606, 2459, 64,
-	3656, 8455, 5257, 5919, 7856, 1747, 9166, 5486, 9235, 6065, 835, 3570,
-	4240, 11580, 4046, 10970, 9139, 1058, 8210, 11848, 922, 7967, 1958,
-	10211, 1112, 3728, 4049, 11130, 5990, 1404, 325, 948, 11143, 6190, 295,
-	11637, 5766, 8212, 8273, 2919, 8527, 6119, 6992, 8333, 1360, 2555, 6167,
-	1200, 7105, 7991, 3329, 9597, 12121, 5106, 5961, 10695, 10327, 3051,
-	9923, 4896, 9326, 81, 3091, 1000, 7969, 4611, 726, 1853, 12149, 4255,
-	11112, 2768, 10654, 1062, 2294, 3553, 4805, 2747, 4846, 8577, 9154,
-	1170, 2319, 790, 11334, 9275, 9088, 1326, 5086, 9094, 6429, 11077,
-	10643, 3504, 3542, 8668, 9744, 1479, 1, 8246, 7143, 11567, 10984, 4134,
-	5736, 4978, 10938, 5777, 8961, 4591, 5728, 6461, 5023, 9650, 7468, 949,
-	9664, 2975, 11726, 2744, 9283, 10092, 5067, 12171, 2476, 3748, 11336,
-	6522, 827, 9452, 5374, 12159, 7935, 3296, 3949, 9893, 4452, 10908, 2525,
-	3584, 8112, 8011, 10616, 4989, 6958, 11809, 9447, 12280, 1022, 11950,
-	9821, 11745, 5791, 5092, 2089, 9005, 2881, 3289, 2013, 9048, 729, 7901,
-	1260, 5755, 4632, 11955, 2426, 10593, 1428, 4890, 5911, 3932, 9558,
-	8830, 3637, 5542, 145, 5179, 8595, 3707, 10530, 355, 3382, 4231, 9741,
-	1207, 9041, 7012, 1168, 10146, 11224, 4645, 11885, 10911, 10377, 435,
-	7952, 4096, 493, 9908, 6845, 6039, 2422, 2187, 9723, 8643, 9852, 9302,
-	6022, 7278, 1002, 4284, 5088, 1607, 7313, 875, 8509, 9430, 1045, 2481,
-	5012, 7428, 354, 6591, 9377, 11847, 2401, 1067, 7188, 11516, 390, 8511,
-	8456, 7270, 545, 8585, 9611, 12047, 1537, 4143, 4714, 4885, 1017, 5084,
-	1632, 3066, 27, 1440, 8526, 9273, 12046, 11618, 9289, 3400, 9890, 3136,
-	7098, 8758, 11813, 7384, 3985, 11869, 6730, 10745, 10111, 2249, 4048,
-	2884, 11136, 2126, 1630, 9103, 5407, 2686, 9042, 2969, 8311, 9424, 9919,
-	8779, 5332, 10626, 1777, 4654, 10863, 7351, 3636, 9585, 5291, 8374,
-	2166, 4919, 12176, 9140, 12129, 7852, 12286, 4895, 10805, 2780, 5195,
-	2305, 7247, 9644, 4053, 10600, 3364, 3271, 4057, 4414, 9442, 7917, 2174,
-	3947, 11951, 2455, 6599, 10545, 10975, 3654, 2894, 7681, 7126, 7287,
-	12269, 4119, 3343, 2151, 1522, 7174, 7350, 11041, 2442, 2148, 5959,
-	6492, 8330, 8945, 5598, 3624, 10397, 1325, 6565, 1945, 11260, 10077,
-	2674, 3338, 3276, 11034, 506, 6505, 1392, 5478, 8778, 1178, 2776, 3408,
-	10347, 11124, 2575, 9489, 12096, 6092, 10058, 4167, 6085, 923, 11251,
-	11912, 4578, 10669, 11914, 425, 10453, 392, 10104, 8464, 4235, 8761,
-	7376, 2291, 3375, 7954, 8896, 6617, 7790, 1737, 11667, 3982, 9342, 6680,
-	636, 6825, 7383, 512, 4670, 2900, 12050, 7735, 994, 1687, 11883, 7021,
-	146, 10485, 1403, 5189, 6094, 2483, 2054, 3042, 10945, 3981, 10821,
-	11826, 8882, 8151, 180, 9600, 7684, 5219, 10880, 6780, 204, 11232, 2600,
-	7584, 3121, 3017, 11053, 7814, 7043, 4251, 4739, 11063, 6771, 7073,
-	9261, 2360, 11925, 1928, 11825, 8024, 3678, 3205, 3359, 11197, 5209,
-	8581, 3238, 8840, 1136, 9363, 1826, 3171, 4489, 7885, 346, 2068, 1389,
-	8257, 3163, 4840, 6127, 8062, 8921, 612, 4238, 10763, 8067, 125, 11749,
-	10125, 5416, 2110, 716, 9839, 10584, 11475, 11873, 3448, 343, 1908,
-	4538, 10423, 7078, 4727, 1208, 11572, 3589, 2982, 1373, 1721, 10753,
-	4103, 2429, 4209, 5412, 5993, 9011, 438, 3515, 7228, 1218, 8347, 5232,
-	8682, 1327, 7508, 4924, 448, 1014, 10029, 12221, 4566, 5836, 12229,
-	2717, 1535, 3200, 5588, 5845, 412, 5102, 7326, 3744, 3056, 2528, 7406,
-	8314, 9202, 6454, 6613, 1417, 10032, 7784, 1518, 3765, 4176, 5063, 9828,
-	2275, 6636, 4267, 6463, 2065, 7725, 3495, 8328, 8755, 8144, 10533, 5966,
-	12077, 9175, 9520, 5596, 6302, 8400, 579, 6781, 11014, 5734, 11113,
-	11164, 4860, 1131, 10844, 9068, 8016, 9694, 3837, 567, 9348, 7000, 6627,
-	7699, 5082, 682, 11309, 5207, 4050, 7087, 844, 7434, 3769, 293, 9057,
-	6940, 9344, 10883, 2633, 8190, 3944, 5530, 5604, 3480, 2171, 9282,
-	11024, 2213, 8136, 3805, 767, 12239, 216, 11520, 6763, 10353, 7, 8566,
-	845, 7235, 3154, 4360, 3285, 10268, 2832, 3572, 1282, 7559, 3229, 8360,
-	10583, 6105, 3120, 6643, 6203, 8536, 8348, 6919, 3536, 9199, 10891,
-	11463, 5043, 1658, 5618, 8787, 5789, 4719, 751, 11379, 6389, 10783,
-	3065, 7806, 6586, 2622, 5386, 510, 7628, 6921, 578, 10345, 11839, 8929,
-	4684, 12226, 7154, 9916, 7302, 8481, 3670, 11066, 2334, 1590, 7878,
-	10734, 1802, 1891, 5103, 6151, 8820, 3418, 7846, 9951, 4693, 417, 9996,
-	9652, 4510, 2946, 5461, 365, 881, 1927, 1015, 11675, 11009, 1371, 12265,
-	2485, 11385, 5039, 6742, 8449, 1842, 12217, 8176, 9577, 4834, 7937,
-	9461, 2643, 11194, 3045, 6508, 4094, 3451, 7911, 11048, 5406, 4665,
-	3020, 6616, 11345, 7519, 3669, 5287, 1790, 7014, 5410, 11038, 11249,
-	2035, 6125, 10407, 4565, 7315, 5078, 10506, 2840, 2478, 9270, 4194,
-	9195, 4518, 7469, 1160, 6878, 2730, 10421, 10036, 1734, 3815, 10939,
-	5832, 10595, 10759, 4423, 8420, 9617, 7119, 11010, 11424, 9173, 189,
-	10080, 10526, 3466, 10588, 7592, 3578, 11511, 7785, 9663, 530, 12150,
-	8957, 2532, 3317, 9349, 10243, 1481, 9332, 3454, 3758, 7899, 4218, 2593,
-	11410, 2276, 982, 6513, 1849, 8494, 9021, 4523, 7988, 8, 457, 648, 150,
-	8000, 2307, 2301, 874, 5650, 170, 9462, 2873, 9855, 11498, 2535, 11169,
-	5808, 12268, 9687, 1901, 7171, 11787, 3846, 1573, 6063, 3793, 466,
-	11259, 10608, 3821, 6320, 4649, 6263, 2929,
+// invNttTwiddlesMontgomery are the twiddle factors of poly.invNtt().
+var invNttTwiddlesMontgomery = [paramN - 1]uint16{
+	9360, 1445, 5547, 364, 1763, 11071, 8753, 2185, 11832, 4505, 8619, 6195,
+	1882, 540, 1265, 1029, 21, 1756, 2293, 12085, 2253, 11081, 9004, 9714,
+	2957, 9089, 5703, 11653, 1241, 7800, 11445, 10767, 8496, 11710, 11274,
+	5246, 3869, 9860, 1706, 1038, 11307, 9761, 450, 11295, 7002, 6162, 9656,
+	3959, 12119, 8022, 7186, 3407, 8095, 416, 5526, 10897, 11759, 11275,
+	6500, 3393, 2828, 7080, 5662, 9395, 8468, 1176, 24, 5518, 865, 3278,
+	6086, 375, 3268, 5835, 5135, 12143, 1251, 8051, 6685, 1892, 791, 8794,
+	4443, 4605, 11129, 7751, 11444, 9513, 8972, 6453, 5900, 622, 5781,
+	11153, 980, 20, 502, 2769, 6828, 9168, 6457, 10916, 11007, 2231, 8071,
+	7187, 4661, 7619, 5673, 10900, 3232, 9847, 9982, 7226, 4411, 1344, 1783,
+	11573, 11522, 9013, 8711, 10962, 7246, 4913, 4113, 8611, 8452, 5690,
+	7640, 7429, 904, 3028, 12100, 8774, 3941, 1836, 4301, 10872, 4987,
+	10886, 10254, 4222, 10118, 5724, 1120, 3534, 7596, 1409, 9559, 5211,
+	9135, 1942, 2046, 9572, 9224, 2947, 8838, 10463, 8239, 8946, 10716,
+	5987, 11408, 1236, 1530, 1536, 9060, 6204, 879, 8545, 11711, 239, 4770,
+	9126, 2945, 6330, 11415, 10014, 10487, 1468, 9811, 1705, 12073, 11783,
+	4504, 7365, 6671, 8914, 7455, 8930, 2941, 1314, 1030, 1275, 1280, 7550,
+	5170, 6877, 9169, 7711, 10440, 3975, 7605, 406, 5275, 3368, 8345, 6691,
+	9416, 10224, 3469, 12109, 7771, 11946, 12282, 3511, 3332, 68, 11538,
+	4499, 1095, 9051, 7207, 5163, 10388, 212, 7779, 9689, 8474, 8700, 9457,
+	193, 8531, 6444, 6903, 4906, 7624, 11943, 8520, 4939, 12139, 8524, 9955,
+	10235, 4974, 6873, 4153, 9615, 1701, 7057, 1398, 8054, 10447, 464, 4273,
+	338, 6026, 11158, 7250, 9929, 2209, 5061, 5370, 11897, 12281, 2257,
+	3808, 7100, 6164, 12164, 3007, 10344, 6481, 4145, 11872, 5509, 1868,
+	7562, 7929, 1165, 10808, 10754, 4483, 5609, 4378, 9118, 5202, 10138,
+	6226, 3889, 10362, 4475, 7866, 8186, 3929, 11366, 10013, 9233, 1944,
+	4554, 8620, 7449, 1406, 5797, 6639, 5653, 10398, 463, 3019, 814, 769,
+	5784, 2626, 11841, 3502, 4335, 4352, 1092, 5289, 8635, 1681, 6555,
+	10918, 1226, 1279, 6296, 5646, 1620, 3795, 3087, 63, 5268, 6879, 11677,
+	6759, 8665, 2434, 4564, 8871, 2689, 4820, 10381, 3723, 11111, 9757,
+	7723, 910, 10552, 9244, 3449, 11607, 5002, 5118, 3114, 9343, 4705, 1350,
+	9307, 8717, 6197, 4390, 11877, 11779, 11777, 9269, 10221, 11996, 1248,
+	4289, 8113, 10699, 9247, 7211, 10179, 8484, 8951, 4697, 3607, 826, 3528,
+	72, 4265, 2595, 9834, 5969, 1125, 9804, 5216, 3116, 11851, 3753, 11864,
+	7766, 5676, 2373, 1804, 1040, 1526, 8809, 10964, 9754, 3961, 2338, 7070,
+	5411, 1866, 5054, 8881, 2940, 60, 1506, 8307, 8195, 2926, 7082, 8170,
+	8443, 6693, 11924, 9272, 1694, 10568, 4730, 8122, 9696, 4963, 5368,
+	9389, 944, 4032, 5349, 10141, 9988, 2461, 1555, 8308, 9449, 2450, 50,
+	1255, 778, 4781, 10631, 9998, 2712, 9084, 11722, 1744, 11823, 5508, 614,
+	8038, 2672, 8080, 6184, 377, 5776, 4883, 3360, 10602, 10499, 4227, 4099,
+	3344, 2827, 5826, 6138, 4138, 3094, 8841, 1936, 6811, 139, 2260, 7570,
+	5672, 9646, 3708, 4590, 4608, 2602, 6323, 2637, 1057, 10555, 717, 2021,
+	2800, 8835, 6701, 9667, 5464, 6883, 4404, 4855, 5115, 11641, 10771,
+	1223, 9806, 7724, 2164, 10076, 2212, 8823, 3942, 3090, 3825, 3840,
+	10361, 3221, 8342, 10115, 2548, 3763, 3006, 6957, 6498, 3780, 7203, 3,
+	10861, 3833, 6553, 2178, 7837, 5444, 1635, 8236, 12144, 7575, 7266,
+	6882, 5331, 6267, 3135, 6998, 11560, 2912, 2545, 5191, 11462, 404, 4320,
+	8232, 1759, 10657, 2625, 3978, 11267, 7201, 955, 113, 7657, 5101, 5146,
+	8304, 4354, 4337, 140, 7094, 2731, 2678, 3328, 1153, 4177, 2566, 7484,
+	1426, 9408, 7277, 1646, 3000, 9813, 11121, 2963, 2847, 8907, 12262, 563,
+	2370, 2468, 4976, 3201, 160, 9863, 11899, 1305, 5559, 8340, 11796, 1177,
+	5042, 8652, 10752, 6561, 10659, 1673, 2437, 7443, 8653, 10276, 11935,
+	8747, 2399, 953, 1065, 9198, 8925, 3694, 11272, 4821, 3247, 2842, 11287,
+	9970, 10123, 11029, 9888, 12288, 476, 6915, 1912, 11563, 1484, 6378,
+	11744, 1351, 8241, 9764, 9867, 9995, 10512, 10200, 11244, 5860, 243,
+	7222, 3248, 2366, 4372, 8058, 10849, 9545, 3510, 544, 11414, 10963,
+	4437, 1696, 3778, 8155, 1544, 2396, 2381, 9521, 2645, 6747, 8146, 5828,
+	3186, 7300, 2987, 3712, 2704, 3241, 5698, 3621, 9153, 5767, 7644, 11289,
+	9018, 8582, 7205, 11340, 9320, 9, 8005, 11499, 7370, 6534, 11222, 4043,
+	4905, 130, 11854, 10436, 9509, 8357, 3704, 6512, 9405, 8705, 10102,
+	8736, 7635, 3284, 9808, 1212, 671, 118, 5277, 7393, 7875, 11934, 9223,
+	9314, 2865, 339, 10682, 3014, 3149, 334, 773, 722, 420, 8993, 8193,
+	8034, 9984, 3459, 242, 7698, 10163, 4278, 3646, 9542, 4938, 9000, 4861,
+	8785, 8889, 8541, 2143, 12208, 1689, 7110, 7404, 2639, 9603, 480, 5011,
+	11119, 3915, 4388, 442, 10810, 3531, 2837, 1378, 7678, 7394, 7399, 5019,
+	7311, 10040, 1381, 6250, 11227, 1663, 7197, 2859, 3195, 3016, 2197,
+	11082, 9238, 1962, 12115, 11177, 8855, 3762, 1583, 3054, 6381, 5184,
+	11942, 3150, 6147, 1146, 1815, 8633, 2767, 168, 5735, 11367, 9928, 6523,
+	6374, 4433, 6860, 10929, 3315, 8049, 10335, 6299, 6920, 1566, 10008,
+	6328, 3009, 10331, 9381, 4016, 2500, 3123, 8517, 6122, 1975, 8243, 8326,
+	11964, 10777, 9830, 8298, 8960, 2738, 4079, 9259, 11994, 12240, 7032,
+	1293, 5297, 4754, 11454, 10484, 8240, 4493, 9786, 5333, 1594, 723, 2078,
+	8760, 9370, 6347, 6803, 11836, 11089, 2925, 1319, 9842, 11341, 5383,
+	12225, 156, 2692, 2655, 441, 10446, 652, 1483, 6370, 7515, 3956, 11863,
+	8719, 10238, 1159, 10232, 5782, 5876, 7183, 1693, 4322, 218, 4077,
+	10800, 10542, 418, 9734, 8532, 709, 576, 10885, 350, 683, 8320, 4298,
+	6421, 11231, 4115, 6099, 1263, 3834, 7552, 6170, 1858, 6224, 9407, 8561,
+	9087, 9341, 10258, 52, 885, 3482, 8687, 2505, 12147, 7509, 7507, 6055,
+	8757, 4169, 3600, 8332, 2844, 192, 4213, 10966, 10333, 5468, 421, 10710,
+	8812, 7232, 3029, 11612, 58, 5241, 7665, 10162, 4212, 10240, 11684,
+	3174, 6281, 787, 6068, 5906, 11184, 8844, 5886, 8953, 11286, 9162, 3263,
+	9450, 3438, 1321, 504, 9523, 7280, 1010, 8209, 11858, 6608, 4698, 6695,
+	6415, 12048, 9369, 6077, 151, 11314, 4912, 2302, 9090, 8410, 5415, 8579,
+	10587, 1359, 7341, 11821, 5529, 2033, 6153, 6950, 11635, 11035, 10561,
+	11907, 12233, 1922, 8646, 6093, 1987, 6854, 6152, 8301, 5206, 8291,
+	6427, 5446, 3565, 973, 400, 316, 147, 11099, 5537, 8429, 7840, 6171,
+	4467, 11239, 8500, 9606, 4749, 5445, 6833, 8471, 7500, 7753, 10316,
+	3514, 1278, 11271, 6715, 11248, 9945, 5925, 10120, 7210, 11767, 9027,
+	4324, 4916, 9954,
 }
 
-var psisInvMontgomery = [paramN]uint16{
-	256, 10570, 1510, 7238, 1034, 7170, 6291, 7921, 11665, 3422, 4000, 2327,
-	2088, 5565, 795, 10647, 1521, 5484, 2539, 7385, 1055, 7173, 8047, 11683,
-	1669, 1994, 3796, 5809, 4341, 9398, 11876, 12230, 10525, 12037, 12253,
-	3506, 4012, 9351, 4847, 2448, 7372, 9831, 3160, 2207, 5582, 2553, 7387,
-	6322, 9681, 1383, 10731, 1533, 219, 5298, 4268, 7632, 6357, 9686, 8406,
-	4712, 9451, 10128, 4958, 5975, 11387, 8649, 11769, 6948, 11526, 12180,
-	1740, 10782, 6807, 2728, 7412, 4570, 4164, 4106, 11120, 12122, 8754,
-	11784, 3439, 5758, 11356, 6889, 9762, 11928, 1704, 1999, 10819, 12079,
-	12259, 7018, 11536, 1648, 1991, 2040, 2047, 2048, 10826, 12080, 8748,
-	8272, 8204, 1172, 1923, 7297, 2798, 7422, 6327, 4415, 7653, 6360, 11442,
-	12168, 7005, 8023, 9924, 8440, 8228, 2931, 7441, 1063, 3663, 5790, 9605,
-	10150, 1450, 8985, 11817, 10466, 10273, 12001, 3470, 7518, 1074, 1909,
-	7295, 9820, 4914, 702, 5367, 7789, 8135, 9940, 1420, 3714, 11064, 12114,
-	12264, 1752, 5517, 9566, 11900, 1700, 3754, 5803, 829, 1874, 7290, 2797,
-	10933, 5073, 7747, 8129, 6428, 6185, 11417, 1631, 233, 5300, 9535,
-	10140, 11982, 8734, 8270, 2937, 10953, 8587, 8249, 2934, 9197, 4825,
-	5956, 4362, 9401, 1343, 3703, 529, 10609, 12049, 6988, 6265, 895, 3639,
-	4031, 4087, 4095, 585, 10617, 8539, 4731, 4187, 9376, 3095, 9220, 10095,
-	10220, 1460, 10742, 12068, 1724, 5513, 11321, 6884, 2739, 5658, 6075,
-	4379, 11159, 10372, 8504, 4726, 9453, 3106, 7466, 11600, 10435, 8513,
-	9994, 8450, 9985, 3182, 10988, 8592, 2983, 9204, 4826, 2445, 5616, 6069,
-	867, 3635, 5786, 11360, 5134, 2489, 10889, 12089, 1727, 7269, 2794,
-	9177, 1311, 5454, 9557, 6632, 2703, 9164, 10087, 1441, 3717, 531, 3587,
-	2268, 324, 5313, 759, 1864, 5533, 2546, 7386, 9833, 8427, 4715, 11207,
-	1601, 7251, 4547, 11183, 12131, 1733, 10781, 10318, 1474, 10744, 5046,
-	4232, 11138, 10369, 6748, 964, 7160, 4534, 7670, 8118, 8182, 4680,
-	11202, 6867, 981, 8918, 1274, 182, 26, 7026, 8026, 11680, 12202, 10521,
-	1503, 7237, 4545, 5916, 9623, 8397, 11733, 10454, 3249, 9242, 6587, 941,
-	1890, 270, 10572, 6777, 9746, 6659, 6218, 6155, 6146, 878, 1881, 7291,
-	11575, 12187, 1741, 7271, 8061, 11685, 6936, 4502, 9421, 4857, 4205,
-	7623, 1089, 10689, 1527, 8996, 10063, 11971, 10488, 6765, 2722, 3900,
-	9335, 11867, 6962, 11528, 5158, 4248, 4118, 5855, 2592, 5637, 6072,
-	2623, 7397, 8079, 9932, 4930, 5971, 853, 3633, 519, 8852, 11798, 3441,
-	11025, 1575, 225, 8810, 11792, 12218, 3501, 9278, 3081, 9218, 4828,
-	7712, 8124, 11694, 12204, 3499, 4011, 573, 3593, 5780, 7848, 9899,
-	10192, 1456, 208, 7052, 2763, 7417, 11593, 10434, 12024, 8740, 11782,
-	10461, 3250, 5731, 7841, 9898, 1414, 202, 3540, 7528, 2831, 2160, 10842,
-	5060, 4234, 4116, 588, 84, 12, 7024, 2759, 9172, 6577, 11473, 1639,
-	9012, 3043, 7457, 6332, 11438, 1634, 1989, 9062, 11828, 8712, 11778,
-	12216, 10523, 6770, 9745, 10170, 4964, 9487, 6622, 946, 8913, 6540,
-	6201, 4397, 9406, 8366, 9973, 8447, 8229, 11709, 8695, 10020, 3187,
-	5722, 2573, 10901, 6824, 4486, 4152, 9371, 8361, 2950, 2177, 311, 1800,
-	9035, 8313, 11721, 3430, 490, 70, 10, 1757, 251, 3547, 7529, 11609,
-	3414, 7510, 4584, 4166, 9373, 1339, 5458, 7802, 11648, 1664, 7260, 9815,
-	10180, 6721, 9738, 10169, 8475, 8233, 9954, 1422, 8981, 1283, 5450,
-	11312, 1616, 3742, 11068, 10359, 4991, 713, 3613, 9294, 8350, 4704, 672,
-	96, 7036, 9783, 11931, 3460, 5761, 823, 10651, 12055, 10500, 1500, 5481,
-	783, 3623, 11051, 8601, 8251, 8201, 11705, 10450, 5004, 4226, 7626,
-	2845, 2162, 3820, 7568, 9859, 3164, 452, 10598, 1514, 5483, 6050, 6131,
-	4387, 7649, 8115, 6426, 918, 8909, 8295, 1185, 5436, 11310, 8638, 1234,
-	5443, 11311, 5127, 2488, 2111, 10835, 5059, 7745, 2862, 3920, 560, 80,
-	1767, 2008, 3798, 11076, 6849, 2734, 10924, 12094, 8750, 1250, 10712,
-	6797, 971, 7161, 1023, 8924, 4786, 7706, 4612, 4170, 7618, 6355, 4419,
-	5898, 11376, 10403, 10264, 6733, 4473, 639, 5358, 2521, 9138, 3061,
-	5704, 4326, 618, 5355, 765, 5376, 768, 7132, 4530, 9425, 3102, 9221,
-	6584, 11474, 10417, 10266, 12000, 6981, 6264, 4406, 2385, 7363, 4563,
-	4163, 7617, 9866, 3165, 9230, 11852, 10471, 5007, 5982, 11388, 5138,
-	734, 3616, 11050, 12112, 6997, 11533, 12181, 10518, 12036, 3475, 2252,
-	7344, 9827, 4915, 9480, 6621, 4457, 7659, 9872, 6677, 4465, 4149, 7615,
-	4599, 657, 3605, 515, 10607, 6782, 4480, 640, 1847, 3775, 5806, 2585,
-	5636, 9583, 1369, 10729, 8555, 10000, 11962, 5220, 7768, 8132, 8184,
-	9947, 1421, 203, 29, 8782, 11788, 1684, 10774, 10317, 4985, 9490, 8378,
-	4708, 11206, 5112, 5997, 7879, 11659, 12199, 8765, 10030, 4944, 5973,
-	6120, 6141, 6144, 7900, 11662, 1666, 238, 34, 3516, 5769, 9602, 8394,
-	9977, 6692, 956, 10670, 6791, 9748, 11926, 8726, 11780, 5194, 742, 106,
-	8793, 10034, 3189, 10989, 5081, 4237, 5872, 4350, 2377, 10873, 6820,
-	6241, 11425, 10410, 10265, 3222, 5727, 9596, 4882, 2453, 2106, 3812,
-	11078, 12116, 5242, 4260, 11142, 8614, 11764, 12214, 5256, 4262, 4120,
-	11122, 5100, 11262, 5120, 2487, 5622, 9581, 8391, 8221, 2930, 10952,
-	12098, 6995, 6266, 9673, 4893, 699, 3611, 4027, 5842, 11368, 1624, 232,
-	8811, 8281, 1183, 169, 8802, 3013, 2186, 5579, 797, 3625, 4029, 11109,
-	1587, 7249, 11569, 8675, 6506, 2685, 10917, 12093, 12261, 12285, 1755,
-	7273, 1039, 1904, 272, 3550, 9285, 3082, 5707, 6082, 4380, 7648, 11626,
-	5172, 4250, 9385, 8363, 8217, 4685, 5936, 848, 8899, 6538, 934, 1889,
-	3781, 9318, 10109, 10222, 6727, 961, 5404, 772, 5377, 9546, 8386, 1198,
-	8949, 3034, 2189, 7335, 4559, 5918, 2601, 10905, 5069, 9502, 3113, 7467,
-	8089, 11689, 5181, 9518, 8382, 2953, 3933, 4073, 4093, 7607, 8109, 2914,
-	5683, 4323, 11151, 1593, 10761, 6804, 972, 3650, 2277, 5592, 4310, 7638,
-	9869, 4921, 703, 1856, 9043, 4803, 9464, 1352, 8971, 11815, 5199, 7765,
-	6376, 4422, 7654, 2849, 407, 8836, 6529, 7955, 2892, 9191, 1313, 10721,
-	12065, 12257, 1751, 9028, 8312, 2943, 2176, 3822, 546, 78, 8789, 11789,
-	10462, 12028, 6985, 4509, 9422, 1346, 5459, 4291, 613, 10621, 6784,
-	9747, 3148, 7472, 2823, 5670, 810, 7138, 8042, 4660, 7688, 6365, 6176,
-	6149, 2634, 5643, 9584, 10147, 11983, 5223, 9524, 11894, 10477, 8519,
-	1217, 3685, 2282, 326, 10580, 3267, 7489, 4581, 2410, 5611, 11335, 6886,
-	8006, 8166, 11700, 3427, 11023, 8597, 10006, 3185, 455, 65, 5276, 7776,
-	4622, 5927, 7869, 9902, 11948, 5218, 2501, 5624, 2559, 10899, 1557,
-	1978, 10816, 10323, 8497, 4725, 675, 1852, 10798, 12076, 10503, 3256,
-	9243, 3076, 2195, 10847, 12083, 10504, 12034, 10497,
+// bitrevTable maps each index to its log2(n) bit reversal.
+var bitrevTable = [paramN]uint16{
+	0, 512, 256, 768, 128, 640, 384, 896, 64, 576, 320, 832, 192, 704, 448,
+	960, 32, 544, 288, 800, 160, 672, 416, 928, 96, 608, 352, 864, 224, 736,
+	480, 992, 16, 528, 272, 784, 144, 656, 400, 912, 80, 592, 336, 848, 208,
+	720, 464, 976, 48, 560, 304, 816, 176, 688, 432, 944, 112, 624, 368,
+	880, 240, 752, 496, 1008, 8, 520, 264, 776, 136, 648, 392, 904, 72, 584,
+	328, 840, 200, 712, 456, 968, 40, 552, 296, 808, 168, 680, 424, 936,
+	104, 616, 360, 872, 232, 744, 488, 1000, 24, 536, 280, 792, 152, 664,
+	408, 920, 88, 600, 344, 856, 216, 728, 472, 984, 56, 568, 312, 824, 184,
+	696, 440, 952, 120, 632, 376, 888, 248, 760, 504, 1016, 4, 516, 260,
+	772, 132, 644, 388, 900, 68, 580, 324, 836, 196, 708, 452, 964, 36, 548,
+	292, 804, 164, 676, 420, 932, 100, 612, 356, 868, 228, 740, 484, 996,
+	20, 532, 276, 788, 148, 660, 404, 916, 84, 596, 340, 852, 212, 724, 468,
+	980, 52, 564, 308, 820, 180, 692, 436, 948, 116, 628, 372, 884, 244,
+	756, 500, 1012, 12, 524, 268, 780, 140, 652, 396, 908, 76, 588, 332,
+	844, 204, 716, 460, 972, 44, 556, 300, 812, 172, 684, 428, 940, 108,
+	620, 364, 876, 236, 748, 492, 1004, 28, 540, 284, 796, 156, 668, 412,
+	924, 92, 604, 348, 860, 220, 732, 476, 988, 60, 572, 316, 828, 188, 700,
+	444, 956, 124, 636, 380, 892, 252, 764, 508, 1020, 2, 514, 258, 770,
+	130, 642, 386, 898, 66, 578, 322, 834, 194, 706, 450, 962, 34, 546, 290,
+	802, 162, 674, 418, 930, 98, 610, 354, 866, 226, 738, 482, 994, 18, 530,
+	274, 786, 146, 658, 402, 914, 82, 594, 338, 850, 210, 722, 466, 978, 50,
+	562, 306, 818, 178, 690, 434, 946, 114, 626, 370, 882, 242, 754, 498,
+	1010, 10, 522, 266, 778, 138, 650, 394, 906, 74, 586, 330, 842, 202,
+	714, 458, 970, 42, 554, 298, 810, 170, 682, 426, 938, 106, 618, 362,
+	874, 234, 746, 490, 1002, 26, 538, 282, 794, 154, 666, 410, 922, 90,
+	602, 346, 858, 218, 730, 474, 986, 58, 570, 314, 826, 186, 698, 442,
+	954, 122, 634, 378, 890, 250, 762, 506, 1018, 6, 518, 262, 774, 134,
+	646, 390, 902, 70, 582, 326, 838, 198, 710, 454, 966, 38, 550, 294, 806,
+	166, 678, 422, 934, 102, 614, 358, 870, 230, 742, 486, 998, 22, 534,
+	278, 790, 150, 662, 406, 918, 86, 598, 342, 854, 214, 726, 470, 982, 54,
+	566, 310, 822, 182, 694, 438, 950, 118, 630, 374, 886, 246, 758, 502,
+	1014, 14, 526, 270, 782, 142, 654, 398, 910, 78, 590, 334, 846, 206,
+	718, 462, 974, 46, 558, 302, 814, 174, 686, 430, 942, 110, 622, 366,
+	878, 238, 750, 494, 1006, 30, 542, 286, 798, 158, 670, 414, 926, 94,
+	606, 350, 862, 222, 734, 478, 990, 62, 574, 318, 830, 190, 702, 446,
+	958, 126, 638, 382, 894, 254, 766, 510, 1022, 1, 513, 257, 769, 129,
+	641, 385, 897, 65, 577, 321, 833, 193, 705, 449, 961, 33, 545, 289, 801,
+	161, 673, 417, 929, 97, 609, 353, 865, 225, 737, 481, 993, 17, 529, 273,
+	785, 145, 657, 401, 913, 81, 593, 337, 849, 209, 721, 465, 977, 49, 561,
+	305, 817, 177, 689, 433, 945, 113, 625, 369, 881, 241, 753, 497, 1009,
+	9, 521, 265, 777, 137, 649, 393, 905, 73, 585, 329, 841, 201, 713, 457,
+	969, 41, 553, 297, 809, 169, 681, 425, 937, 105, 617, 361, 873, 233,
+	745, 489, 1001, 25, 537, 281, 793, 153, 665, 409, 921, 89, 601, 345,
+	857, 217, 729, 473, 985, 57, 569, 313, 825, 185, 697, 441, 953, 121,
+	633, 377, 889, 249, 761, 505, 1017, 5, 517, 261, 773, 133, 645, 389,
+	901, 69, 581, 325, 837, 197, 709, 453, 965, 37, 549, 293, 805, 165, 677,
+	421, 933, 101, 613, 357, 869, 229, 741, 485, 997, 21, 533, 277, 789,
+	149, 661, 405, 917, 85, 597, 341, 853, 213, 725, 469, 981, 53, 565, 309,
+	821, 181, 693, 437, 949, 117, 629, 373, 885, 245, 757, 501, 1013, 13,
+	525, 269, 781, 141, 653, 397, 909, 77, 589, 333, 845, 205, 717, 461,
+	973, 45, 557, 301, 813, 173, 685, 429, 941, 109, 621, 365, 877, 237,
+	749, 493, 1005, 29, 541, 285, 797, 157, 669, 413, 925, 93, 605, 349,
+	861, 221, 733, 477, 989, 61, 573, 317, 829, 189, 701, 445, 957, 125,
+	637, 381, 893, 253, 765, 509, 1021, 3, 515, 259, 771, 131, 643, 387,
+	899, 67, 579, 323, 835, 195, 707, 451, 963, 35, 547, 291, 803, 163, 675,
+	419, 931, 99, 611, 355, 867, 227, 739, 483, 995, 19, 531, 275, 787, 147,
+	659, 403, 915, 83, 595, 339, 851, 211, 723, 467, 979, 51, 563, 307, 819,
+	179, 691, 435, 947, 115, 627, 371, 883, 243, 755, 499, 1011, 11, 523,
+	267, 779, 139, 651, 395, 907, 75, 587, 331, 843, 203, 715, 459, 971, 43,
+	555, 299, 811, 171, 683, 427, 939, 107, 619, 363, 875, 235, 747, 491,
+	1003, 27, 539, 283, 795, 155, 667, 411, 923, 91, 603, 347, 859, 219,
+	731, 475, 987, 59, 571, 315, 827, 187, 699, 443, 955, 123, 635, 379,
+	891, 251, 763, 507, 1019, 7, 519, 263, 775, 135, 647, 391, 903, 71, 583,
+	327, 839, 199, 711, 455, 967, 39, 551, 295, 807, 167, 679, 423, 935,
+	103, 615, 359, 871, 231, 743, 487, 999, 23, 535, 279, 791, 151, 663,
+	407, 919, 87, 599, 343, 855, 215, 727, 471, 983, 55, 567, 311, 823, 183,
+	695, 439, 951, 119, 631, 375, 887, 247, 759, 503, 1015, 15, 527, 271,
+	783, 143, 655, 399, 911, 79, 591, 335, 847, 207, 719, 463, 975, 47, 559,
+	303, 815, 175, 687, 431, 943, 111, 623, 367, 879, 239, 751, 495, 1007,
+	31, 543, 287, 799, 159, 671, 415, 927, 95, 607, 351, 863, 223, 735, 479,
+	991, 63, 575, 319, 831, 191, 703, 447, 959, 127, 639, 383, 895, 255,
+	767, 511, 1023,
 }
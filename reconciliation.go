@@ -0,0 +1,219 @@
+// reconciliation.go - NewHope reconciliation mechanisms.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+// Reconciler is a reconciliation mechanism, which Bob uses to derive a key
+// from v, along with a hint that lets Alice derive the same key from her
+// approximation v' of v.  Bob's public key holds the encoded hint.
+type Reconciler int
+
+const (
+	// ReconcilerD4 is the four dimensional reconciliation of
+	// newhope-20151209, based on the D~4 lattice, which derives a 256 bit
+	// key from 2 bit hints for every coefficient.  It is the reconciliation
+	// of the NewHope paper, and the only one that is compatible with other
+	// implementations.
+	ReconcilerD4 Reconciler = iota
+
+	// ReconcilerPeikert is Peikert's one dimensional reconciliation, with
+	// the randomized doubling for odd q, which derives a 1024 bit key from
+	// a 1 bit hint for every coefficient.  It tolerates errors of between
+	// q/8 and q/4 per coefficient, depending on v, and with the NewHope
+	// noise, roughly one exchange in 2000 fails, which makes it unsuitable
+	// for default use.  It is provided for experimentation only.
+	ReconcilerPeikert
+
+	// ReconcilerDing is Ding's signal function, applied to 2v so that the
+	// error is even, which derives a 1024 bit key from a 1 bit hint for
+	// every coefficient.  The key bits are slightly biased, and it tolerates
+	// the same errors as ReconcilerPeikert, so it is just as unsuitable for
+	// default use.
+	ReconcilerDing
+)
+
+// maxKeyBytes is the length of the longest key derived by a Reconciler, in
+// bytes.
+const maxKeyBytes = paramN / 8
+
+// RecBytes returns the length of the reconciliation data in bytes, which
+// is at most the RecBytes constant, or 0 if r is not a known Reconciler.
+// Bob's public key is PolyBytes + RecBytes() bytes long on the wire, see
+// PublicKeyBob.Bytes().
+func (r Reconciler) RecBytes() int {
+	switch r {
+	case ReconcilerD4:
+		return RecBytes
+	case ReconcilerPeikert, ReconcilerDing:
+		return paramN / 8
+	}
+	return 0
+}
+
+func (r Reconciler) valid() bool {
+	return r == ReconcilerD4 || r == ReconcilerPeikert || r == ReconcilerDing
+}
+
+// keyBytes returns the length of the key in bytes.
+func (r Reconciler) keyBytes() int {
+	if r == ReconcilerD4 {
+		return SharedSecretSize
+	}
+	return paramN / 8
+}
+
+// helpRec sets c to the hint for v.
+func (r Reconciler) helpRec(s *sampler, c, v *poly, seed *[SeedBytes]byte, nonce byte) error {
+	switch r {
+	case ReconcilerPeikert:
+		return c.helpRecPeikert(s, v, seed, nonce)
+	case ReconcilerDing:
+		c.helpRecDing(v)
+		return nil
+	default:
+		return c.helpRec(s, v, seed, nonce)
+	}
+}
+
+// rec sets the first keyBytes() bytes of key to the key derived from v and
+// the hint c.
+func (r Reconciler) rec(key []byte, v, c *poly) {
+	switch r {
+	case ReconcilerPeikert:
+		recPeikert(key, v, c)
+	case ReconcilerDing:
+		recDing(key, v, c)
+	default:
+		var k [SharedSecretSize]byte
+		rec(&k, v, c)
+		copy(key, k[:])
+		memwipe(k[:])
+	}
+}
+
+// encodeHint writes the hint c to b, which is RecBytes long, zero padded.
+func (r Reconciler) encodeHint(b []byte, c *poly) {
+	if r == ReconcilerD4 {
+		for i := 0; i < paramN/4; i++ {
+			b[i] = byte(c.coeffs[4*i]) | byte(c.coeffs[4*i+1]<<2) | byte(c.coeffs[4*i+2]<<4) | byte(c.coeffs[4*i+3]<<6)
+		}
+		return
+	}
+
+	for i := range b[:RecBytes] {
+		b[i] = 0
+	}
+	for i := 0; i < paramN; i++ {
+		b[i>>3] |= byte(c.coeffs[i]&1) << (uint(i) & 7)
+	}
+}
+
+// decodeHint sets c to the hint in b, which is RecBytes long, and returns
+// false iff the padding is not zero.
+func (r Reconciler) decodeHint(c *poly, b []byte) bool {
+	if r == ReconcilerD4 {
+		for i := 0; i < paramN/4; i++ {
+			c.coeffs[4*i+0] = uint16(b[i]) & 0x03
+			c.coeffs[4*i+1] = uint16(b[i]>>2) & 0x03
+			c.coeffs[4*i+2] = uint16(b[i]>>4) & 0x03
+			c.coeffs[4*i+3] = uint16(b[i] >> 6)
+		}
+		return true
+	}
+
+	for i := 0; i < paramN; i++ {
+		c.coeffs[i] = uint16(b[i>>3]>>(uint(i)&7)) & 1
+	}
+	var pad byte
+	for _, v := range b[r.RecBytes():RecBytes] {
+		pad |= v
+	}
+	return pad == 0
+}
+
+// geq returns 1 if x >= y, and 0 otherwise, for |x - y| < 2^31.
+func geq(x, y int32) int32 {
+	return ((x - y) >> 31) + 1
+}
+
+// helpRecPeikert sets c to the hint for v.  Each coefficient is doubled to
+// x = 2v - e mod 2q, with e drawn from {-1, 0, 0, 1}, so that x is uniform
+// when v is.  The key bit is round(x/q) mod 2, and the hint is the low bit
+// of the quarter of [0, 2q) that x lies in, floor(2x/q) mod 2, which is
+// independent of the key bit.
+func (c *poly) helpRecPeikert(s *sampler, v *poly, seed *[SeedBytes]byte, nonce byte) error {
+	var rand [paramN / 4]byte
+	var n [8]byte
+
+	n[7] = nonce
+
+	stream, err := s.chacha20(seed[:], n[:])
+	if err != nil {
+		return err
+	}
+	stream.KeyStream(rand[:])
+	stream.Reset()
+	defer memwipe(rand[:])
+
+	for i := range c.coeffs {
+		r := int32(rand[i>>2] >> (2 * (uint(i) & 3)))
+		x := 2*int32(coeffFreeze(v.coeffs[i])) - (r & 1) + (r >> 1 & 1)
+		x += 2 * paramQ & (x >> 31)
+		t := geq(2*x, paramQ) + geq(2*x, 2*paramQ) + geq(2*x, 3*paramQ)
+		c.coeffs[i] = uint16(t & 1)
+	}
+
+	return nil
+}
+
+// recPeikert sets key to the key derived from v and the hint c.  Knowing
+// the parity h of the quarter that x lies in leaves two quarters, 1 apart
+// in the key and q apart, and the key bit is 1 iff y = 2v + hq/2 mod 2q is
+// nearer to the quarter with key bit 1, that is 3q/4 <= y < 7q/4.  The
+// key bit is correct as long as |v - v'| < q/8, or up to q/4 when x lies
+// in the middle of its quarter.
+func recPeikert(key []byte, v, c *poly) {
+	memwipe(key[:maxKeyBytes])
+
+	for i := range c.coeffs {
+		// Computed as Y = 2y mod 4q, to keep hq/2 whole.
+		y := 4*int32(coeffFreeze(v.coeffs[i])) + paramQ*int32(c.coeffs[i]&1)
+		y -= 4 * paramQ & -geq(y, 4*paramQ)
+		k := geq(2*y, 3*paramQ) - geq(2*y, 7*paramQ)
+		key[i>>3] |= byte(k) << (uint(i) & 7)
+	}
+}
+
+// helpRecDing sets c to the signal of 2v: 0 if 2v mod q lies in
+// [-floor(q/4), round(q/4)], and 1 otherwise.  The key bit is the parity of
+// w = 2v + signal(q - 1)/2, taken in (-q/2, q/2), where adding (q - 1)/2
+// moves 2v into [-q/4, q/4].
+func (c *poly) helpRecDing(v *poly) {
+	for i := range c.coeffs {
+		x := 2 * int32(coeffFreeze(v.coeffs[i]))
+		x -= paramQ & -geq(x, paramQ)
+		sig := geq(x, paramQ/4+1) - geq(x, paramQ-paramQ/4)
+		c.coeffs[i] = uint16(sig)
+	}
+}
+
+// recDing sets key to the key derived from v and the signal c.  As the
+// error in 2v' is even, w' has the same parity as w as long as it doesn't
+// leave (-q/2, q/2), which holds for |v - v'| < q/8.
+func recDing(key []byte, v, c *poly) {
+	memwipe(key[:maxKeyBytes])
+
+	for i := range c.coeffs {
+		w := 2*int32(coeffFreeze(v.coeffs[i])) + (paramQ-1)/2*int32(c.coeffs[i]&1)
+		w -= paramQ & -geq(w, paramQ)
+		w -= paramQ & -geq(w, paramQ)
+
+		// The parity of w - q, for w in [(q + 1)/2, q), is flipped.
+		k := w&1 ^ geq(w, (paramQ+1)/2)
+		key[i>>3] |= byte(k) << (uint(i) & 7)
+	}
+}
@@ -0,0 +1,183 @@
+// reconciliation_test.go - NewHope reconciliation mechanism tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+)
+
+var reconcilers = []Reconciler{ReconcilerD4, ReconcilerPeikert, ReconcilerDing}
+
+func TestReconcilerTolerance(t *testing.T) {
+	// The one dimensional reconcilers always agree on the key when every
+	// coefficient of v' is within q/8 of v.
+	const maxErr = paramQ/8 - 1
+
+	var s sampler
+	var seed [SeedBytes]byte
+	var buf [4 * paramN]byte
+	var v, vp, c poly
+	var key, keyp [maxKeyBytes]byte
+	for _, rc := range []Reconciler{ReconcilerPeikert, ReconcilerDing} {
+		for i := 0; i < 64; i++ {
+			if _, err := rand.Read(seed[:]); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+			if _, err := rand.Read(buf[:]); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+			for j := range v.coeffs {
+				x := int(binary.LittleEndian.Uint16(buf[4*j:]))
+				e := int(binary.LittleEndian.Uint16(buf[4*j+2:]))%(2*maxErr+1) - maxErr
+				switch i {
+				case 0:
+					e = maxErr
+				case 1:
+					e = -maxErr
+				}
+				v.coeffs[j] = uint16(x % paramQ)
+				vp.coeffs[j] = uint16((x%paramQ + e + paramQ) % paramQ)
+			}
+
+			if err := rc.helpRec(&s, &c, &v, &seed, 3); err != nil {
+				t.Fatalf("helpRec failed: %v", err)
+			}
+			rc.rec(key[:], &v, &c)
+			rc.rec(keyp[:], &vp, &c)
+			if key != keyp {
+				t.Fatalf("reconciler %d: key mismatch", rc)
+			}
+		}
+	}
+}
+
+func TestReconcilerExchange(t *testing.T) {
+	TorSampling = false
+	for _, rc := range reconcilers {
+		opts := &KeyOptions{Reconciler: rc}
+		rand := testReader("newhope reconciler exchange")
+		for i := 0; i < 16; i++ {
+			alicePriv, alicePub, err := GenerateKeyPairAliceOptions(context.Background(), rand, opts)
+			if err != nil {
+				t.Fatalf("GenerateKeyPairAliceOptions failed: %v", err)
+			}
+			if alicePub.Reconciler != rc {
+				t.Fatalf("reconciler %d: public key has reconciler %d", rc, alicePub.Reconciler)
+			}
+			bobPub, bobShared, err := KeyExchangeBob(rand, alicePub)
+			if err != nil {
+				t.Fatalf("KeyExchangeBob failed: %v", err)
+			}
+
+			// Alice only receives the bytes that Bob needs to send.
+			b := bobPub.Bytes()
+			if len(b) != PolyBytes+rc.RecBytes() {
+				t.Fatalf("reconciler %d: Bob's public key is %d bytes", rc, len(b))
+			}
+			received := new(PublicKeyBob)
+			copy(received.Send[:], b)
+			if received.Send != bobPub.Send {
+				t.Fatalf("reconciler %d: padding not zero", rc)
+			}
+			aliceShared, err := KeyExchangeAlice(received, alicePriv)
+			if err != nil {
+				t.Fatalf("KeyExchangeAlice failed: %v", err)
+			}
+			if !bytes.Equal(aliceShared, bobShared) {
+				t.Fatalf("reconciler %d: shared secrets mismatched", rc)
+			}
+		}
+	}
+}
+
+func TestReconcilerFailureRate(t *testing.T) {
+	// The one dimensional reconcilers fail roughly once in 2000 exchanges,
+	// which this measures.  ReconcilerD4 fails with probability 2^-60, and
+	// is left to the other tests.
+	n := 10000
+	if testing.Short() {
+		n = 1000
+	}
+
+	var s scratch
+	defer s.release()
+	ctx := context.Background()
+	rand := testReader("newhope reconciler failure rate")
+	for _, rc := range []Reconciler{ReconcilerPeikert, ReconcilerDing} {
+		var alicePriv *PrivateKeyAlice
+		var alicePub *PublicKeyAlice
+		failures := 0
+		for i := 0; i < n; i++ {
+			// Bob's noise dominates the error, so a new key pair every so
+			// often suffices, and makes this a lot faster.
+			if i%16 == 0 {
+				if alicePriv != nil {
+					alicePriv.Reset()
+				}
+				var err error
+				if alicePriv, alicePub, err = s.generateKeyPairAlice(ctx, rand, false, rc); err != nil {
+					t.Fatalf("generateKeyPairAlice failed: %v", err)
+				}
+			}
+			bobPub, bobShared, err := s.keyExchangeBob(ctx, rand, alicePub, false)
+			if err != nil {
+				t.Fatalf("keyExchangeBob failed: %v", err)
+			}
+			aliceShared, err := s.keyExchangeAlice(bobPub, alicePriv.key(), rc)
+			if err != nil {
+				t.Fatalf("keyExchangeAlice failed: %v", err)
+			}
+			if !bytes.Equal(aliceShared[:], bobShared) {
+				failures++
+			}
+		}
+		alicePriv.Reset()
+		t.Logf("reconciler %d: %d/%d exchanges failed", rc, failures, n)
+
+		// Allow for four times the expected number of failures.
+		if max := 4 * n / 2000; failures > max {
+			t.Fatalf("reconciler %d: %d/%d exchanges failed, expected at most %d", rc, failures, n, max)
+		}
+	}
+}
+
+func TestReconcilerErrors(t *testing.T) {
+	TorSampling = false
+	opts := &KeyOptions{Reconciler: ReconcilerPeikert}
+	alicePriv, alicePub, err := GenerateKeyPairAliceOptions(context.Background(), rand.Reader, opts)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairAliceOptions failed: %v", err)
+	}
+	bobPub, _, err := KeyExchangeBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeBob failed: %v", err)
+	}
+
+	// Nonzero padding is rejected, with the one dimensional reconcilers.
+	bad := *bobPub
+	bad.Send[SendBSize-1] = 1
+	if _, err = KeyExchangeAlice(&bad, alicePriv); err != ErrInvalidPublicKey {
+		t.Fatalf("KeyExchangeAlice with nonzero padding: %v", err)
+	}
+
+	invalid := ReconcilerDing + 1
+	if _, _, err = GenerateKeyPairAliceOptions(context.Background(), rand.Reader, &KeyOptions{Reconciler: invalid}); err != ErrInvalidReconciler {
+		t.Fatalf("GenerateKeyPairAliceOptions with an invalid reconciler: %v", err)
+	}
+	alicePub.Reconciler = invalid
+	if _, _, err = KeyExchangeBob(rand.Reader, alicePub); err != ErrInvalidReconciler {
+		t.Fatalf("KeyExchangeBob with an invalid reconciler: %v", err)
+	}
+	if n := invalid.RecBytes(); n != 0 {
+		t.Fatalf("RecBytes of an invalid reconciler: %d", n)
+	}
+}
@@ -1,4 +1,4 @@
-// poly.go - NewHope reductions.
+// reduce.go - NewHope reductions.
 //
 // To the extent possible under law, Yawning Angel has waived all copyright
 // and related or neighboring rights to newhope, using the Creative
@@ -10,23 +10,80 @@ package newhope
 // Incomplete-reduction routines; for details on allowed input ranges
 // and produced output ranges, see the description in the paper:
 // https://cryptojedi.org/papers/#newhope
+//
+// The ranges below are checked over every input by reduce_test.go, and
+// TestBounds checks that the polynomial arithmetic stays within them.  They
+// are for q < 2^14, and the larger moduli use the fully reducing
+// montgomeryReduceStrict(), and % paramQ, instead.
 
 const (
-	qinv = 12287 // -inverse_mod(p,2^18)
+	qinv = (1<<rlog - qinvNewton%(1<<rlog)) % (1 << rlog) // -inverse_mod(p,2^18)
 	rlog = 18
 )
 
+// qinvNewton is q^-1 mod 2^32, by Newton's iteration, which doubles the
+// number of correct low bits with each step, starting from q itself, which
+// is its own inverse mod 8.
+const (
+	qinvMod     = 1 << 32
+	qinvNewton1 = (paramQ*(2-paramQ*paramQ)%qinvMod + qinvMod) % qinvMod
+	qinvNewton2 = (qinvNewton1*(2-paramQ*qinvNewton1)%qinvMod + qinvMod) % qinvMod
+	qinvNewton3 = (qinvNewton2*(2-paramQ*qinvNewton2)%qinvMod + qinvMod) % qinvMod
+	qinvNewton  = (qinvNewton3*(2-paramQ*qinvNewton3)%qinvMod + qinvMod) % qinvMod
+)
+
+// Barrett multipliers, floor(2^16 / q) and floor(2^28 / q).
+const (
+	barrettMul   = (1 << 16) / paramQ
+	barrett32Mul = (1 << 28) / paramQ
+)
+
+// montgomeryLimit is the bound on the input to montgomeryReduce, past which
+// the intermediate sum overflows.  It is only positive for q < 2^14.
+const montgomeryLimit = (1 << 32) - ((1<<rlog)-1)*paramQ
+
+// montgomeryReduce returns a 2^-18 mod q, reduced to at most
+// (a + (2^18 - 1)q) / 2^18, for a < montgomeryLimit.
 func montgomeryReduce(a uint32) uint16 {
+	if boundCheck && int64(a) >= montgomeryLimit {
+		panic("newhope: montgomeryReduce input out of range")
+	}
 	u := a * qinv
 	u &= ((1 << rlog) - 1)
 	u *= paramQ
-	a = (a + u) >> 18
+	a = (a + u) >> rlog
 	return uint16(a)
 }
 
+// montgomeryReduceStrict returns a 2^-18 mod q, fully reduced, for any a.
+func montgomeryReduceStrict(a uint32) uint16 {
+	u := (uint64(a) * qinv) & ((1 << rlog) - 1)
+	return uint16(((uint64(a) + u*paramQ) >> rlog) % paramQ)
+}
+
+// barrettReduce reduces a to less than 2^14 - 4.
 func barrettReduce(a uint16) uint16 {
-	u := (uint32(a) * 5) >> 16
+	u := (uint32(a) * barrettMul) >> 16
 	u *= paramQ
 	a -= uint16(u)
 	return a
 }
+
+// barrettReduce32 reduces a < 2^17 to less than q + 4.
+func barrettReduce32(a uint32) uint16 {
+	if boundCheck && a >= 1<<17 {
+		panic("newhope: barrettReduce32 input out of range")
+	}
+	u := (a * barrett32Mul) >> 28
+	u *= paramQ
+	return uint16(a - u)
+}
+
+// checkedUint16 truncates a lazily reduced value to 16 bits, which is
+// always lossless.  With bound checking enabled, it panics if it is not.
+func checkedUint16(a uint32) uint16 {
+	if boundCheck && a > 0xffff {
+		panic("newhope: lazily reduced value out of range")
+	}
+	return uint16(a)
+}
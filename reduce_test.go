@@ -0,0 +1,124 @@
+// reduce_test.go - NewHope reduction tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import "testing"
+
+// The routines below are checked over every input they can be given, and
+// the bounds on their outputs are the ones assumed by TestBounds.
+
+// montgomeryMax returns the bound on montgomeryReduce(a) for a <= max, which
+// follows from a + u < max + 2^rlog q for the u it adds.
+func montgomeryMax(max uint64) uint64 {
+	return (max + ((1<<rlog)-1)*paramQ) >> rlog
+}
+
+const (
+	// barrettMax is the bound on the output of barrettReduce.
+	barrettMax = 1<<14 - 5
+
+	// barrett32Max is the bound on the output of barrettReduce32.
+	barrett32Max = paramQ + 3
+
+	// fMax and gMax are the largest inputs to f and g, which are passed
+	// 8v + 4r, and 16q + 8v - q(2c0 + c1) respectively for 16 bit v, and
+	// 2 bit c0 and c1.
+	fMax = 8*0xffff + 4
+	gMax = 16*paramQ + 8*0xffff
+)
+
+// 2^rlog mod q, to check that montgomeryReduce(a) 2^rlog = a (mod q)
+// without reducing a, which is tracked as r.
+const rModQ = (1 << rlog) % paramQ
+
+func TestMontgomeryReduce(t *testing.T) {
+	if !lazyReduction {
+		t.Skip("montgomeryReduce is only used for q < 2^14")
+	}
+
+	step := uint32(1)
+	if testing.Short() {
+		step = 4099
+	}
+	for a, r := uint32(0), uint32(0); int64(a) < montgomeryLimit; a += step {
+		v := montgomeryReduce(a)
+		if uint64(v) > montgomeryMax(uint64(a)) || uint32(v)*rModQ%paramQ != r {
+			t.Fatalf("montgomeryReduce(%d) = %d", a, v)
+		}
+		if r += step % paramQ; r >= paramQ {
+			r -= paramQ
+		}
+	}
+}
+
+func TestMontgomeryReduceStrict(t *testing.T) {
+	const step = 65521
+	for a, r := uint64(0), uint64(0); a <= 0xffffffff; a += step {
+		if v := montgomeryReduceStrict(uint32(a)); v >= paramQ || uint64(v)*rModQ%paramQ != r {
+			t.Fatalf("montgomeryReduceStrict(%d) = %d", a, v)
+		}
+		r = (r + step) % paramQ
+	}
+	if v := montgomeryReduceStrict(0xffffffff); uint64(v)*rModQ%paramQ != 0xffffffff%paramQ {
+		t.Fatalf("montgomeryReduceStrict(2^32 - 1) = %d", v)
+	}
+}
+
+func TestBarrettReduce(t *testing.T) {
+	if !lazyReduction {
+		t.Skip("the Barrett reductions are only used for q < 2^14")
+	}
+
+	for a := uint32(0); a <= 0xffff; a++ {
+		if v := barrettReduce(uint16(a)); v > barrettMax || uint32(v)%paramQ != a%paramQ {
+			t.Fatalf("barrettReduce(%d) = %d", a, v)
+		}
+	}
+	for a := uint32(0); a < 1<<17; a++ {
+		if v := barrettReduce32(a); v > barrett32Max || uint32(v)%paramQ != a%paramQ {
+			t.Fatalf("barrettReduce32(%d) = %d", a, v)
+		}
+	}
+}
+
+func TestCoeffFreeze(t *testing.T) {
+	for a := 0; a <= 0xffff; a++ {
+		if v := coeffFreeze(uint16(a)); int(v) != a%paramQ {
+			t.Fatalf("coeffFreeze(%d) = %d", a, v)
+		}
+
+		expected := a%paramQ - paramQ/2
+		if expected < 0 {
+			expected = -expected
+		}
+		if v := flipAbs(uint16(a)); int(v) != expected {
+			t.Fatalf("flipAbs(%d) = %d, expected %d", a, v, expected)
+		}
+	}
+}
+
+func TestErrorCorrection(t *testing.T) {
+	for x := int32(0); x <= fMax; x++ {
+		// v0 = round(x / 2q), and v1 = round((x - q) / 2q), rounding
+		// halves up.
+		var v0, v1 int32
+		k := f(&v0, &v1, x)
+		q := x / paramQ
+		if v0 != (q+1)/2 || v1 != q/2 || k != abs(x-2*paramQ*v0) {
+			t.Fatalf("f(%d) = %d, v0 = %d, v1 = %d", x, k, v0, v1)
+		}
+	}
+
+	for x := int32(0); x <= gMax; x++ {
+		// g(x) is the distance from x to the nearest multiple of 8q.
+		q := x / (4 * paramQ)
+		if k := g(x); k != abs(8*paramQ*((q+1)/2)-x) {
+			t.Fatalf("g(%d) = %d", x, k)
+		}
+	}
+}
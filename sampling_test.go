@@ -0,0 +1,232 @@
+// sampling_test.go - NewHope sampler conformance tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// The samplers are checked against their distributions with chi-square and
+// Kolmogorov-Smirnov tests.  Every test uses fixed seeds, so a given build
+// either always passes or always fails, and the false positive rate is the
+// chance that a correct sampler fails for a given choice of seeds.  It is
+// about 1e-6 for each test.
+//
+// The empirical histograms are printed with:
+//
+//	go test -run TestSampling -args -histograms
+var dumpHistograms = flag.Bool("histograms", false, "print the sampler histograms")
+
+const (
+	// zCritical is the upper 1e-6 quantile of the standard normal
+	// distribution.
+	zCritical = 4.753
+
+	// ksCritical is sqrt(-ln(1e-6 / 2) / 2), the 1e-6 critical value of
+	// the Kolmogorov-Smirnov statistic, scaled by sqrt(n).
+	ksCritical = 2.699
+)
+
+// histogram counts the samples in each of a set of bins, with the expected
+// probability of each bin.
+type histogram struct {
+	name     string
+	min      int
+	counts   []uint64
+	expected []float64
+}
+
+func newHistogram(name string, min int, expected []float64) *histogram {
+	return &histogram{
+		name:     name,
+		min:      min,
+		counts:   make([]uint64, len(expected)),
+		expected: expected,
+	}
+}
+
+func (h *histogram) add(v int) {
+	h.counts[v-h.min]++
+}
+
+func (h *histogram) total() uint64 {
+	var n uint64
+	for _, c := range h.counts {
+		n += c
+	}
+	return n
+}
+
+// chiSquare returns the chi-square statistic and its critical value, after
+// merging bins with less than 5 expected samples into their neighbours.
+func (h *histogram) chiSquare() (float64, float64) {
+	n := float64(h.total())
+
+	var stat, observed, expected float64
+	var bins int
+	for i, p := range h.expected {
+		observed += float64(h.counts[i])
+		expected += p * n
+		if expected < 5 && i < len(h.expected)-1 {
+			continue
+		}
+		stat += (observed - expected) * (observed - expected) / expected
+		observed, expected = 0, 0
+		bins++
+	}
+
+	// The Wilson-Hilferty approximation of the critical value.
+	df := float64(bins - 1)
+	c := 2 / (9 * df)
+	return stat, df * math.Pow(1-c+zCritical*math.Sqrt(c), 3)
+}
+
+// ks returns the Kolmogorov-Smirnov statistic, scaled by sqrt(n).  As the
+// distributions are discrete, the test is conservative.
+func (h *histogram) ks() float64 {
+	n := float64(h.total())
+
+	var d, observed, expected float64
+	for i, p := range h.expected {
+		observed += float64(h.counts[i]) / n
+		expected += p
+		d = math.Max(d, math.Abs(observed-expected))
+	}
+	return d * math.Sqrt(n)
+}
+
+func (h *histogram) check(t *testing.T) {
+	if *dumpHistograms {
+		fmt.Printf("# %s: %d samples\n# value\tcount\texpected\n", h.name, h.total())
+		n := float64(h.total())
+		for i, c := range h.counts {
+			fmt.Printf("%d\t%d\t%.1f\n", h.min+i, c, h.expected[i]*n)
+		}
+		fmt.Println()
+	}
+
+	if stat, critical := h.chiSquare(); stat > critical {
+		t.Errorf("%s: chi-square statistic %.1f exceeds %.1f", h.name, stat, critical)
+	}
+	if d := h.ks(); d > ksCritical {
+		t.Errorf("%s: Kolmogorov-Smirnov statistic %.3f exceeds %.3f", h.name, d, ksCritical)
+	}
+}
+
+// uniformProbabilities returns the probabilities of a uniform distribution
+// over n values.
+func uniformProbabilities(n int) []float64 {
+	p := make([]float64, n)
+	for i := range p {
+		p[i] = 1 / float64(n)
+	}
+	return p
+}
+
+func TestSamplingNoise(t *testing.T) {
+	var s sampler
+	var seed [SeedBytes]byte
+	var p poly
+
+	// Pr[X = j] = (2k choose k + j) / 2^2k.
+	expected := make([]float64, 2*paramK+1)
+	for i := range expected {
+		c := new(big.Float).SetInt(new(big.Int).Binomial(2*paramK, int64(i)))
+		expected[i], _ = c.SetMantExp(c, -2*paramK).Float64()
+	}
+	h := newHistogram("getNoise", -paramK, expected)
+
+	for i := 0; i < 256; i++ {
+		seed[0] = byte(i)
+		if err := p.getNoise(&s, &seed, byte(i)); err != nil {
+			t.Fatalf("getNoise failed: %v", err)
+		}
+		for _, v := range p.coeffs {
+			h.add(int(v) - paramQ)
+		}
+	}
+	h.check(t)
+}
+
+func TestSamplingUniform(t *testing.T) {
+	var s sampler
+	var seed [SeedBytes]byte
+	var p poly
+
+	for _, torSampling := range []bool{false, true} {
+		// uniform() outputs values less than uniformBound (5q), which must
+		// be uniform mod q, and over the whole range.
+		name := fmt.Sprintf("uniform (TorSampling = %v)", torSampling)
+		if torSampling && !torSamplingSupported {
+			err := p.uniform(context.Background(), &s, &seed, torSampling)
+			if !errors.Is(err, ErrSamplingMismatch) {
+				t.Fatalf("%s: unsupported modulus: %v", name, err)
+			}
+			continue
+		}
+		hq := newHistogram(name+" mod q", 0, uniformProbabilities(paramQ))
+		hBound := newHistogram(name, 0, uniformProbabilities(uniformBound))
+
+		for i := 0; i < 256; i++ {
+			seed[0] = byte(i)
+			if err := p.uniform(context.Background(), &s, &seed, torSampling); err != nil {
+				t.Fatalf("uniform failed: %v", err)
+			}
+			for _, v := range p.coeffs {
+				if v >= uniformBound {
+					t.Fatalf("%s: output %d out of range", name, v)
+				}
+				hq.add(int(v % paramQ))
+				hBound.add(int(v))
+			}
+		}
+		hq.check(t)
+		hBound.check(t)
+	}
+}
+
+func TestSamplingHelpRec(t *testing.T) {
+	var s sampler
+	var seed [SeedBytes]byte
+	var v, c poly
+
+	// With every coefficient of v at q/16, the output of helpRec() is the
+	// random bit, in the last quarter of the coefficients.
+	for i := range v.coeffs {
+		v.coeffs[i] = paramQ / 16
+	}
+	bits := newHistogram("helpRec bit", 0, uniformProbabilities(2))
+	pairs := newHistogram("helpRec bit pairs", 0, uniformProbabilities(4))
+
+	for i := 0; i < 1024; i++ {
+		seed[0], seed[1] = byte(i), byte(i>>8)
+		if err := c.helpRec(&s, &v, &seed, 3); err != nil {
+			t.Fatalf("helpRec failed: %v", err)
+		}
+		for j, r := range c.coeffs {
+			if (j < 768 && r != 0) || r > 1 {
+				t.Fatalf("helpRec: c[%d] = %d, expected a single bit", j, r)
+			}
+		}
+		r := c.coeffs[768:]
+		for j := range r {
+			bits.add(int(r[j]))
+			if j&1 == 1 {
+				pairs.add(int(r[j-1]<<1 | r[j]))
+			}
+		}
+	}
+	bits.check(t)
+	pairs.check(t)
+}
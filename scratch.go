@@ -0,0 +1,101 @@
+// scratch.go - NewHope reusable working state.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"gitlab.com/yawning/chacha20.git"
+	"golang.org/x/crypto/sha3"
+)
+
+// sampler holds the SHAKE-128 and ChaCha20 instances used for sampling, so
+// that they can be reused across calls instead of being reallocated.  The
+// zero value is ready to use.
+type sampler struct {
+	shake  sha3.ShakeHash
+	stream *chacha20.Cipher
+}
+
+func (s *sampler) shake128() sha3.ShakeHash {
+	if s.shake == nil {
+		s.shake = sha3.NewShake128()
+	} else {
+		s.shake.Reset()
+	}
+	return s.shake
+}
+
+func (s *sampler) chacha20(key, nonce []byte) (*chacha20.Cipher, error) {
+	var err error
+	if s.stream == nil {
+		s.stream, err = chacha20.New(key, nonce)
+	} else {
+		err = s.stream.ReKey(key, nonce)
+	}
+	if err != nil {
+		return nil, wrapError(ErrSamplingMismatch, err)
+	}
+	return s.stream, nil
+}
+
+// scratchPolys is the number of polynomials needed by the most demanding
+// operation (KeyExchangeBob).
+const scratchPolys = 8
+
+// scratch is the working state of a key generation or exchange.  Pool
+// workers each own one, and reuse it across operations.
+type scratch struct {
+	sampler
+	p      [scratchPolys]poly
+	locked *lockedPolys
+
+	// selfTest is set for the scratch used by the self-tests, which skips
+	// checking their result.
+	selfTest bool
+}
+
+// polys returns the scratch polynomials, which are in locked memory if
+// secure memory is enabled, or was when they were last allocated.  As every
+// operation starts here, it also fails here if the self-tests have.
+func (s *scratch) polys() ([]poly, error) {
+	if !s.selfTest {
+		if err := checkSelfTests(); err != nil {
+			return nil, err
+		}
+	}
+	if s.locked == nil && SecureMemoryEnabled() {
+		l, err := allocLocked(scratchPolys)
+		if err != nil {
+			return nil, err
+		}
+		s.locked = l
+	}
+	if s.locked != nil {
+		return s.locked.p, nil
+	}
+	return s.p[:], nil
+}
+
+// reset clears the polynomials, which may contain sensitive intermediary
+// values.  The sampler instances are scrubbed after each use.
+func (s *scratch) reset() {
+	for i := range s.p {
+		s.p[i].reset()
+	}
+	if s.locked != nil {
+		s.locked.wipe()
+	}
+}
+
+// release clears the polynomials, and frees any locked memory.
+func (s *scratch) release() {
+	s.reset()
+	if s.locked != nil {
+		_ = s.locked.destroy()
+		s.locked = nil
+	}
+}
@@ -0,0 +1,296 @@
+// selftest.go - NewHope self-tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"runtime"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// SelfTests enables the power-on self-tests, which are known answer tests
+// of the samplers, the NTT, the reconcilers, the message encodings, and of
+// both key exchanges.  They are run once, before the first operation after
+// SelfTests is set (or by RunSelfTests()), and every operation fails if
+// they do.
+var SelfTests = false
+
+// PairwiseConsistency enables checking each new NewHope-Simple key pair
+// with a trial exchange, before it is returned.  Every operation fails once
+// a check does.
+var PairwiseConsistency = false
+
+var errPairwiseConsistency = errors.New("newhope: pairwise consistency check failed")
+
+var (
+	selfTestOnce sync.Once
+	selfTestLock sync.Mutex
+	selfTestErr  error
+)
+
+// RunSelfTests runs the power-on self-tests if they have not been run yet,
+// regardless of SelfTests, and returns the latched error state.
+func RunSelfTests() error {
+	selfTestOnce.Do(func() {
+		s := scratch{selfTest: true}
+		defer s.release()
+
+		if err := s.runSelfTests(); err != nil {
+			latchFailure(err)
+		}
+	})
+
+	return selfTestError()
+}
+
+// checkSelfTests returns an error iff the module is in the error state,
+// running the self-tests first if they are enabled.
+func checkSelfTests() error {
+	if SelfTests {
+		return RunSelfTests()
+	}
+	return selfTestError()
+}
+
+func selfTestError() error {
+	selfTestLock.Lock()
+	defer selfTestLock.Unlock()
+
+	return selfTestErr
+}
+
+// latchFailure puts the module into the error state, which can not be left,
+// and returns the resulting error.
+func latchFailure(err error) error {
+	selfTestLock.Lock()
+	defer selfTestLock.Unlock()
+
+	if selfTestErr == nil {
+		selfTestErr = wrapError(ErrSelfTestFailed, err)
+	}
+	return selfTestErr
+}
+
+// selfTestDigests are the SHA3-256 digests of the output of each known
+// answer test.
+var selfTestDigests = map[string]string{
+	"uniform":            "0c621888521912de6aacd0362cfd5de7813a3df232c9b0388182e1718cfe28a0",
+	"getNoise":           "55750524bc695ce708474df12dfc3be86d0cfaa7325faf4e73c0995c15a5107b",
+	"NTT":                "3ea85b82aad5389afb3db1669c0a01c22a700473c58d0f9bbda3a6cd88db6ab1",
+	"reconciliation":     "192dc6c81b0a3c5ebb26e9af294bfc59f3b950f92b97eb7d52a1ea4833d795e9",
+	"1-D reconciliation": "aef7536e05b6123ef2de57661aab0c24f68278970c55e32d0b1cadb71bed3469",
+	"message encoding":   "7016ee6cfb8ecdc6dc76ee8acca3e6808087e8692fb5ae49b2798885c1d834e6",
+	"NewHope":            "4e32753556b3e2a94684795fc446cc2e6ea3923016ebcb142dcbac4a1ce74116",
+	"NewHope-Simple":     "c01080460d8085cfb120a0b0e82369dc66dd96a69398b4353a5903f85771ef8d",
+}
+
+// runSelfTests runs the known answer tests.  Every input is derived from a
+// fixed SHAKE-128 stream, which also stands in for the entropy source.
+func (s *scratch) runSelfTests() error {
+	ctx := context.Background()
+	var p [3]poly
+	a, b, c := &p[0], &p[1], &p[2]
+	defer func() {
+		for i := range p {
+			p[i].reset()
+		}
+	}()
+
+	for _, kat := range []struct {
+		name string
+		fn   func(rand io.Reader, h hash.Hash) error
+	}{
+		{"uniform", func(rand io.Reader, h hash.Hash) error {
+			var seed [SeedBytes]byte
+			_, _ = io.ReadFull(rand, seed[:])
+			for _, torSampling := range []bool{false, true} {
+				if err := a.uniform(ctx, &s.sampler, &seed, torSampling); err != nil {
+					return err
+				}
+				writeSelfTestPoly(h, a)
+			}
+			return nil
+		}},
+		{"getNoise", func(rand io.Reader, h hash.Hash) error {
+			var seed [SeedBytes]byte
+			_, _ = io.ReadFull(rand, seed[:])
+			if err := a.getNoise(&s.sampler, &seed, 0); err != nil {
+				return err
+			}
+			writeSelfTestPoly(h, a)
+			return nil
+		}},
+		{"NTT", func(rand io.Reader, h hash.Hash) error {
+			var seed [SeedBytes]byte
+			_, _ = io.ReadFull(rand, seed[:])
+			if err := a.getNoise(&s.sampler, &seed, 0); err != nil {
+				return err
+			}
+			if err := b.getNoise(&s.sampler, &seed, 1); err != nil {
+				return err
+			}
+			a.ntt()
+			b.ntt()
+			writeSelfTestPoly(h, a)
+			c.pointwise(a, b)
+			c.invNtt()
+			writeSelfTestPoly(h, c)
+			return nil
+		}},
+		{"reconciliation", func(rand io.Reader, h hash.Hash) error {
+			var seed [SeedBytes]byte
+			var key [SharedSecretSize]byte
+			_, _ = io.ReadFull(rand, seed[:])
+			if err := a.uniform(ctx, &s.sampler, &seed, false); err != nil {
+				return err
+			}
+			for i, v := range a.coeffs {
+				a.coeffs[i] = barrettReduce(v)
+			}
+			if err := b.helpRec(&s.sampler, a, &seed, 3); err != nil {
+				return err
+			}
+			writeSelfTestPoly(h, b)
+			rec(&key, a, b)
+			_, _ = h.Write(key[:])
+			return nil
+		}},
+		{"1-D reconciliation", func(rand io.Reader, h hash.Hash) error {
+			var seed [SeedBytes]byte
+			var key [maxKeyBytes]byte
+			_, _ = io.ReadFull(rand, seed[:])
+			if err := a.uniform(ctx, &s.sampler, &seed, false); err != nil {
+				return err
+			}
+			for _, rc := range []Reconciler{ReconcilerPeikert, ReconcilerDing} {
+				if err := rc.helpRec(&s.sampler, b, a, &seed, 3); err != nil {
+					return err
+				}
+				writeSelfTestPoly(h, b)
+				rc.rec(key[:], a, b)
+				_, _ = h.Write(key[:])
+			}
+			return nil
+		}},
+		{"message encoding", func(rand io.Reader, h hash.Hash) error {
+			var seed [SeedBytes]byte
+			var msg [SharedSecretSize]byte
+			_, _ = io.ReadFull(rand, seed[:])
+			_, _ = io.ReadFull(rand, msg[:])
+			if err := b.getNoise(&s.sampler, &seed, 0); err != nil {
+				return err
+			}
+			for _, enc := range []MessageEncoding{EncodingRepetition, EncodingReedMuller} {
+				a.encodeMsg(msg[:], enc)
+				writeSelfTestPoly(h, a)
+				a.add(a, b)
+				a.decodeMsg(msg[:], enc)
+				_, _ = h.Write(msg[:])
+			}
+			return nil
+		}},
+		{"NewHope", func(rand io.Reader, h hash.Hash) error {
+			alicePriv, alicePub, err := s.generateKeyPairAlice(ctx, rand, false, ReconcilerD4)
+			if err != nil {
+				return err
+			}
+			defer alicePriv.Reset()
+			bobPub, bobShared, err := s.keyExchangeBob(ctx, rand, alicePub, false)
+			if err != nil {
+				return err
+			}
+			aliceShared, err := s.keyExchangeAlice(bobPub, alicePriv.key(), ReconcilerD4)
+			if err != nil {
+				return err
+			}
+			if subtle.ConstantTimeCompare(aliceShared[:], bobShared) != 1 {
+				return errors.New("newhope: NewHope shared secrets mismatched")
+			}
+			_, _ = h.Write(alicePub.Send[:])
+			_, _ = h.Write(bobPub.Send[:])
+			_, _ = h.Write(bobShared)
+			return nil
+		}},
+		{"NewHope-Simple", func(rand io.Reader, h hash.Hash) error {
+			alicePriv, alicePub, err := s.generateKeyPairSimpleAlice(ctx, rand, false, EncodingRepetition)
+			if err != nil {
+				return err
+			}
+			defer alicePriv.Reset()
+			bobPub, bobShared, err := s.keyExchangeSimpleBob(ctx, rand, alicePub, false, EncodingRepetition)
+			if err != nil {
+				return err
+			}
+			aliceShared, err := s.keyExchangeSimpleAlice(bobPub, alicePriv.key(), EncodingRepetition)
+			if err != nil {
+				return err
+			}
+			if subtle.ConstantTimeCompare(aliceShared[:], bobShared) != 1 {
+				return errors.New("newhope: NewHope-Simple shared secrets mismatched")
+			}
+			_, _ = h.Write(alicePub.Send[:])
+			_, _ = h.Write(bobPub.Send[:])
+			_, _ = h.Write(bobShared)
+			return nil
+		}},
+	} {
+		rand := sha3.NewShake128()
+		_, _ = rand.Write([]byte("newhope self-test: " + kat.name))
+		h := sha3.New256()
+		if err := kat.fn(rand, h); err != nil {
+			return err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != selfTestDigests[kat.name] {
+			return errors.New("newhope: " + kat.name + " known answer test mismatch")
+		}
+	}
+
+	return nil
+}
+
+// writeSelfTestPoly writes the fully reduced coefficients of p to h, as the
+// backends are free to reduce their output to different extents.
+func writeSelfTestPoly(h hash.Hash, p *poly) {
+	var b [2]byte
+	for _, v := range p.coeffs {
+		binary.LittleEndian.PutUint16(b[:], coeffFreeze(v))
+		_, _ = h.Write(b[:])
+	}
+}
+
+// pairwiseTest checks a new NewHope-Simple key pair with a trial exchange,
+// and latches the error state if it fails.  Bob's side of the exchange is
+// derived from the public key, as its output is discarded.
+func (s *scratch) pairwiseTest(privKey *PrivateKeySimpleAlice, pubKey *PublicKeySimpleAlice, torSampling bool) error {
+	rand := sha3.NewShake128()
+	_, _ = rand.Write(pubKey.Send[:])
+
+	bobPub, bobShared, err := s.keyExchangeSimpleBob(context.Background(), rand, pubKey, torSampling, pubKey.Encoding)
+	if err != nil {
+		return err
+	}
+	aliceShared, err := s.keyExchangeSimpleAlice(bobPub, privKey.key(), pubKey.Encoding)
+	runtime.KeepAlive(privKey)
+	if err != nil {
+		return err
+	}
+	defer memwipe(aliceShared[:])
+	if subtle.ConstantTimeCompare(aliceShared[:], bobShared) != 1 {
+		return latchFailure(errPairwiseConsistency)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// selftest_test.go - NewHope self-test tests.
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to newhope, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package newhope
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// resetSelfTests leaves the error state, and disables the self-tests.
+func resetSelfTests() {
+	SelfTests = false
+	PairwiseConsistency = false
+	selfTestOnce = sync.Once{}
+	selfTestErr = nil
+}
+
+func TestSelfTests(t *testing.T) {
+	skipKAT(t)
+	defer resetSelfTests()
+	resetSelfTests()
+
+	TorSampling = false
+	SelfTests = true
+	PairwiseConsistency = true
+	if err := RunSelfTests(); err != nil {
+		t.Fatalf("RunSelfTests failed: %v", err)
+	}
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+	bobPub, bobShared, err := KeyExchangeSimpleBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleBob failed: %v", err)
+	}
+	aliceShared, err := KeyExchangeSimpleAlice(bobPub, alicePriv)
+	if err != nil {
+		t.Fatalf("KeyExchangeSimpleAlice failed: %v", err)
+	}
+	if string(aliceShared) != string(bobShared) {
+		t.Fatalf("shared secrets mismatched")
+	}
+}
+
+func TestSelfTestFailure(t *testing.T) {
+	defer resetSelfTests()
+	resetSelfTests()
+
+	TorSampling = false
+	alicePriv, alicePub, err := GenerateKeyPairAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairAlice failed: %v", err)
+	}
+	bobPub, _, err := KeyExchangeBob(rand.Reader, alicePub)
+	if err != nil {
+		t.Fatalf("KeyExchangeBob failed: %v", err)
+	}
+
+	// A failed known answer test latches the error state, which outlasts
+	// the cause of the failure.
+	digest := selfTestDigests["NTT"]
+	selfTestDigests["NTT"] = "0" + digest[1:]
+	SelfTests = true
+	_, _, err = GenerateKeyPairAlice(rand.Reader)
+	selfTestDigests["NTT"] = digest
+	if !errors.Is(err, ErrSelfTestFailed) {
+		t.Fatalf("GenerateKeyPairAlice with a failed self-test: %v", err)
+	}
+	SelfTests = false
+	if _, err = KeyExchangeAlice(bobPub, alicePriv); !errors.Is(err, ErrSelfTestFailed) {
+		t.Fatalf("KeyExchangeAlice with a failed self-test: %v", err)
+	}
+	if err = RunSelfTests(); !errors.Is(err, ErrSelfTestFailed) {
+		t.Fatalf("RunSelfTests after a failure: %v", err)
+	}
+}
+
+func TestPairwiseConsistency(t *testing.T) {
+	defer resetSelfTests()
+	resetSelfTests()
+
+	TorSampling = false
+	alicePriv, alicePub, err := GenerateKeyPairSimpleAlice(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairSimpleAlice failed: %v", err)
+	}
+
+	var s scratch
+	defer s.release()
+	if err = s.pairwiseTest(alicePriv, alicePub, false); err != nil {
+		t.Fatalf("pairwiseTest failed: %v", err)
+	}
+
+	// A mismatched key pair fails, and latches the error state.
+	alicePriv.sk.reset()
+	if err = s.pairwiseTest(alicePriv, alicePub, false); !errors.Is(err, ErrSelfTestFailed) || !errors.Is(err, errPairwiseConsistency) {
+		t.Fatalf("pairwiseTest with a mismatched key pair: %v", err)
+	}
+	if _, _, err = GenerateKeyPairSimpleAlice(rand.Reader); !errors.Is(err, ErrSelfTestFailed) {
+		t.Fatalf("GenerateKeyPairSimpleAlice after a failure: %v", err)
+	}
+}